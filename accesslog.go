@@ -0,0 +1,164 @@
+package iudex
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// AccessLogField is an attribute of access log records, named after the OTel semantic conventions
+type AccessLogField string
+
+// Access log record fields
+const (
+	AccessLogMethod        AccessLogField = AccessLogField(semconv.HTTPRequestMethodKey)
+	AccessLogRoute         AccessLogField = AccessLogField(semconv.HTTPRouteKey)
+	AccessLogPath          AccessLogField = AccessLogField(semconv.URLPathKey)
+	AccessLogQuery         AccessLogField = AccessLogField(semconv.URLQueryKey)
+	AccessLogStatus        AccessLogField = AccessLogField(semconv.HTTPResponseStatusCodeKey)
+	AccessLogLatency       AccessLogField = "http.server.request.duration" // seconds
+	AccessLogRequestBytes  AccessLogField = AccessLogField(semconv.HTTPRequestBodySizeKey)
+	AccessLogResponseBytes AccessLogField = AccessLogField(semconv.HTTPResponseBodySizeKey)
+	AccessLogClientAddress AccessLogField = AccessLogField(semconv.ClientAddressKey)
+	AccessLogUserAgent     AccessLogField = AccessLogField(semconv.UserAgentOriginalKey)
+)
+
+// DefaultAccessLogFields are the fields recorded unless WithAccessLogFields is used. The query
+// string, user agent, and client address can hold personal data and are left out.
+var DefaultAccessLogFields = []AccessLogField{
+	AccessLogMethod,
+	AccessLogRoute,
+	AccessLogPath,
+	AccessLogStatus,
+	AccessLogLatency,
+	AccessLogRequestBytes,
+	AccessLogResponseBytes,
+}
+
+// AccessLogOption configures HTTPAccessLogMiddleware
+type AccessLogOption func(*accessLogConfig)
+
+type accessLogConfig struct {
+	fields map[AccessLogField]bool
+	body   *bodyCapture
+}
+
+// WithAccessLogFields selects the fields of access log records, replacing DefaultAccessLogFields
+func WithAccessLogFields(fields ...AccessLogField) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.fields = map[AccessLogField]bool{}
+		for _, field := range fields {
+			c.fields[field] = true
+		}
+	}
+}
+
+// WithAccessLogBody records up to maxBytes of the request and response bodies in the
+// http.request.body and http.response.body attributes, scrubbed and filtered by content type
+// with the BodyCaptureConfig defaults. Bodies are not recorded by default.
+func WithAccessLogBody(maxBytes int) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.body = newBodyCapture(BodyCaptureConfig{MaxBytes: maxBytes})
+	}
+}
+
+// HTTPAccessLogMiddleware emits one log record per request to handler, with the method, route,
+// status, latency, and body sizes as attributes. Records of 5xx responses have error severity
+// and records of 4xx responses warn severity. Wrap it with HTTPMiddleware so records are
+// correlated with the request span: iudex.HTTPMiddleware(iudex.HTTPAccessLogMiddleware(mux)).
+// The route is the http.ServeMux pattern that matched the request, if any.
+func HTTPAccessLogMiddleware(handler http.Handler, opts ...AccessLogOption) http.Handler {
+	config := accessLogConfig{fields: map[AccessLogField]bool{}}
+	for _, field := range DefaultAccessLogFields {
+		config.fields[field] = true
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	logger := GetLoggerProvider().Logger(tracerName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &requestBodyRecorder{ReadCloser: r.Body}
+		rw := &responseRecorder{ResponseWriter: w}
+		if config.body != nil {
+			body.capture = &limitedBuffer{max: config.body.config.MaxBytes}
+			rw.capture = &limitedBuffer{max: config.body.config.MaxBytes}
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+
+		// The mux sets r.Pattern on the request it is given, so r itself is passed on
+		handler.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		record := otellog.Record{}
+		record.SetTimestamp(start)
+		switch {
+		case status >= http.StatusInternalServerError:
+			record.SetSeverity(otellog.SeverityError)
+			record.SetSeverityText("ERROR")
+		case status >= http.StatusBadRequest:
+			record.SetSeverity(otellog.SeverityWarn)
+			record.SetSeverityText("WARN")
+		default:
+			record.SetSeverity(otellog.SeverityInfo)
+			record.SetSeverityText("INFO")
+		}
+		route := muxRoute(r.Pattern)
+		target := route
+		if target == "" {
+			target = r.URL.Path
+		}
+		record.SetBody(otellog.StringValue(r.Method + " " + target + " " + strconv.Itoa(status)))
+
+		add := func(field AccessLogField, value otellog.Value) {
+			if config.fields[field] {
+				record.AddAttributes(otellog.KeyValue{Key: string(field), Value: value})
+			}
+		}
+		add(AccessLogMethod, otellog.StringValue(r.Method))
+		if route != "" {
+			add(AccessLogRoute, otellog.StringValue(route))
+		}
+		add(AccessLogPath, otellog.StringValue(r.URL.Path))
+		if r.URL.RawQuery != "" {
+			add(AccessLogQuery, otellog.StringValue(r.URL.RawQuery))
+		}
+		add(AccessLogStatus, otellog.IntValue(status))
+		add(AccessLogLatency, otellog.Float64Value(time.Since(start).Seconds()))
+		add(AccessLogRequestBytes, otellog.Int64Value(body.n))
+		add(AccessLogResponseBytes, otellog.Int64Value(rw.n))
+		add(AccessLogClientAddress, otellog.StringValue(r.RemoteAddr))
+		if ua := r.UserAgent(); ua != "" {
+			add(AccessLogUserAgent, otellog.StringValue(ua))
+		}
+		if config.body != nil {
+			if body.capture.Len() > 0 && config.body.allowed(r.Header.Get("Content-Type")) {
+				record.AddAttributes(otellog.String(string(HTTPRequestBodyKey), config.body.scrub(body.capture.Bytes())))
+			}
+			if rw.capture.Len() > 0 && config.body.allowed(w.Header().Get("Content-Type")) {
+				record.AddAttributes(otellog.String(string(HTTPResponseBodyKey), config.body.scrub(rw.capture.Bytes())))
+			}
+		}
+
+		logger.Emit(r.Context(), record)
+	})
+}
+
+// muxRoute returns the path of an http.ServeMux pattern such as "GET example.com/users/{id}"
+func muxRoute(pattern string) string {
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		return pattern[i:]
+	}
+	return ""
+}
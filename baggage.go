@@ -0,0 +1,118 @@
+package iudex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Baggage keys copied onto spans and log records by default
+const (
+	UserIDKey    = attribute.Key("user.id")
+	SessionIDKey = attribute.Key("session.id")
+	TenantIDKey  = attribute.Key("tenant.id")
+)
+
+// DefaultBaggageKeys are the baggage members copied onto spans and log records unless WithBaggageKeys is used
+var DefaultBaggageKeys = []string{
+	string(UserIDKey), string(UserHashKey), string(UserEmailKey), string(UserRolesKey),
+	string(SessionIDKey), string(TenantIDKey), string(RequestIDKey),
+}
+
+// SetAttribute adds key=value to the baggage in ctx and to the current span. When key is one of
+// the configured baggage keys, every span and log record created from the returned context, in
+// this service and downstream, carries it as an attribute. An invalid key is reported through
+// the OTel error handler and ctx is returned unchanged.
+func SetAttribute(ctx context.Context, key, value string) context.Context {
+	member, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		otel.Handle(err)
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		otel.Handle(err)
+		return ctx
+	}
+	oteltrace.SpanFromContext(ctx).SetAttributes(attribute.String(key, value))
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// SetUserID attributes all telemetry created from the returned context to the user
+func SetUserID(ctx context.Context, id string) context.Context {
+	return SetAttribute(ctx, string(UserIDKey), id)
+}
+
+// SetSessionID attributes all telemetry created from the returned context to the session
+func SetSessionID(ctx context.Context, id string) context.Context {
+	return SetAttribute(ctx, string(SessionIDKey), id)
+}
+
+// SetTenantID attributes all telemetry created from the returned context to the tenant
+func SetTenantID(ctx context.Context, id string) context.Context {
+	return SetAttribute(ctx, string(TenantIDKey), id)
+}
+
+// baggageSpanProcessor copies baggage members onto spans as they start
+type baggageSpanProcessor struct {
+	keys []string
+}
+
+// NewBaggageSpanProcessor creates a span processor that sets the baggage members with the given keys as span attributes
+func NewBaggageSpanProcessor(keys ...string) trace.SpanProcessor {
+	return &baggageSpanProcessor{keys: keys}
+}
+
+func (p *baggageSpanProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	bag := baggage.FromContext(parent)
+	for _, key := range p.keys {
+		if member := bag.Member(key); member.Key() != "" {
+			s.SetAttributes(attribute.String(key, member.Value()))
+		}
+	}
+}
+
+func (p *baggageSpanProcessor) OnEnd(trace.ReadOnlySpan) {}
+
+func (p *baggageSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *baggageSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// baggageLogProcessor copies baggage members onto log records in place.
+// It must be registered before the exporting processor.
+type baggageLogProcessor struct {
+	keys []string
+}
+
+// NewBaggageLogProcessor creates a log processor that adds the baggage members with the given keys as log attributes
+func NewBaggageLogProcessor(keys ...string) log.Processor {
+	return &baggageLogProcessor{keys: keys}
+}
+
+func (p *baggageLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	bag := baggage.FromContext(ctx)
+	for _, key := range p.keys {
+		if member := bag.Member(key); member.Key() != "" {
+			record.AddAttributes(otellog.String(key, member.Value()))
+		}
+	}
+	return nil
+}
+
+func (p *baggageLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *baggageLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package iudex
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchConfig tunes a batch processor. Zero values keep the OpenTelemetry defaults,
+// except the span batch timeout which defaults to one second.
+type BatchConfig struct {
+	// Timeout is the longest a record waits before its batch is exported
+	Timeout time.Duration
+	// MaxQueueSize is the number of records buffered before new ones are dropped
+	MaxQueueSize int
+	// MaxExportBatchSize is the largest number of records sent in one export
+	MaxExportBatchSize int
+	// ExportTimeout bounds how long a single export may take
+	ExportTimeout time.Duration
+}
+
+// spanBatchOptions converts the config into span batch processor options
+func spanBatchOptions(batch *BatchConfig) []trace.BatchSpanProcessorOption {
+	opts := []trace.BatchSpanProcessorOption{trace.WithBatchTimeout(time.Second)}
+	if batch == nil {
+		return opts
+	}
+	if batch.Timeout > 0 {
+		opts = append(opts, trace.WithBatchTimeout(batch.Timeout))
+	}
+	if batch.MaxQueueSize > 0 {
+		opts = append(opts, trace.WithMaxQueueSize(batch.MaxQueueSize))
+	}
+	if batch.MaxExportBatchSize > 0 {
+		opts = append(opts, trace.WithMaxExportBatchSize(batch.MaxExportBatchSize))
+	}
+	if batch.ExportTimeout > 0 {
+		opts = append(opts, trace.WithExportTimeout(batch.ExportTimeout))
+	}
+	return opts
+}
+
+// logBatchOptions converts the config into log batch processor options
+func logBatchOptions(batch *BatchConfig) []log.BatchProcessorOption {
+	opts := []log.BatchProcessorOption{}
+	if batch == nil {
+		return opts
+	}
+	if batch.Timeout > 0 {
+		opts = append(opts, log.WithExportInterval(batch.Timeout))
+	}
+	if batch.MaxQueueSize > 0 {
+		opts = append(opts, log.WithMaxQueueSize(batch.MaxQueueSize))
+	}
+	if batch.MaxExportBatchSize > 0 {
+		opts = append(opts, log.WithExportMaxBatchSize(batch.MaxExportBatchSize))
+	}
+	if batch.ExportTimeout > 0 {
+		opts = append(opts, log.WithExportTimeout(batch.ExportTimeout))
+	}
+	return opts
+}
@@ -0,0 +1,326 @@
+package iudex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Captured body attributes. The .truncated attributes report whether a body was cut at MaxBytes.
+const (
+	HTTPRequestBodyKey  = attribute.Key("http.request.body")
+	HTTPResponseBodyKey = attribute.Key("http.response.body")
+)
+
+// DefaultBodyCaptureMaxBytes is how much of each body is captured unless BodyCaptureConfig.MaxBytes is set
+const DefaultBodyCaptureMaxBytes = 4096
+
+// DefaultBodyCaptureContentTypes are the media types captured unless BodyCaptureConfig.ContentTypes is set
+var DefaultBodyCaptureContentTypes = []string{
+	"application/json",
+	"application/x-www-form-urlencoded",
+	"application/xml",
+	"text/",
+}
+
+// DefaultSensitiveBodyFields are the JSON and form fields scrubbed unless BodyCaptureConfig.SensitiveFields is set
+var DefaultSensitiveBodyFields = []string{"password", "passwd", "secret", "token", "api_key", "apikey", "authorization", "cookie"}
+
+// BodyCaptureConfig configures HTTPBodyCaptureMiddleware and HTTPBodyCaptureTransport.
+// Nil slices and a zero MaxBytes use the defaults.
+type BodyCaptureConfig struct {
+	// MaxBytes is how much of each body is captured. Longer bodies are truncated.
+	MaxBytes int
+	// ContentTypes are the media types captured. An entry ending in "/" matches every subtype.
+	ContentTypes []string
+	// SensitiveFields are the JSON and form fields whose values are replaced. Any field whose
+	// name contains one of them, ignoring case, is scrubbed.
+	SensitiveFields []string
+	// RedactionRules are applied to the whole body, after SensitiveFields. The defaults are
+	// RedactEmails, RedactCreditCards, and RedactBearerTokens.
+	RedactionRules []RedactionRule
+	// Log emits the bodies as debug log records correlated with the span, instead of as span attributes
+	Log bool
+}
+
+// bodyCapture captures, filters, and scrubs HTTP bodies
+type bodyCapture struct {
+	config       BodyCaptureConfig
+	quotedFields *regexp.Regexp
+	bareFields   *regexp.Regexp
+}
+
+func newBodyCapture(config BodyCaptureConfig) *bodyCapture {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultBodyCaptureMaxBytes
+	}
+	if config.ContentTypes == nil {
+		config.ContentTypes = DefaultBodyCaptureContentTypes
+	}
+	if config.SensitiveFields == nil {
+		config.SensitiveFields = DefaultSensitiveBodyFields
+	}
+	if config.RedactionRules == nil {
+		config.RedactionRules = []RedactionRule{RedactEmails, RedactCreditCards, RedactBearerTokens}
+	}
+
+	c := &bodyCapture{config: config}
+	if len(config.SensitiveFields) > 0 {
+		names := make([]string, len(config.SensitiveFields))
+		for i, field := range config.SensitiveFields {
+			names[i] = regexp.QuoteMeta(field)
+		}
+		// The field name, optionally quoted, followed by a JSON colon or a form equals sign
+		field := `(?i)("?[\w.\-]*(?:` + strings.Join(names, "|") + `)[\w.\-]*"?\s*[:=]\s*)`
+		// A value cut off by truncation has no closing quote
+		c.quotedFields = regexp.MustCompile(field + `"(?:[^"\\]|\\.)*(?:"|$)`)
+		c.bareFields = regexp.MustCompile(field + `[^"&,}\]\s]+`)
+	}
+	return c
+}
+
+// allowed reports whether bodies with the given Content-Type header are captured
+func (c *bodyCapture) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.config.ContentTypes {
+		allowed = strings.ToLower(allowed)
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrub replaces sensitive field values and redacts the body
+func (c *bodyCapture) scrub(body []byte) string {
+	s := strings.ToValidUTF8(string(body), "�")
+	if c.quotedFields != nil {
+		s = c.quotedFields.ReplaceAllString(s, `${1}"`+redactedValue+`"`)
+		s = c.bareFields.ReplaceAllString(s, `${1}`+redactedValue)
+	}
+	return redact(c.config.RedactionRules, s)
+}
+
+// peek reads up to MaxBytes of body and returns them with a body that replays them
+func (c *bodyCapture) peek(body io.ReadCloser) ([]byte, bool, io.ReadCloser) {
+	buf := make([]byte, c.config.MaxBytes+1)
+	n, err := io.ReadFull(body, buf)
+	buf = buf[:n]
+
+	var rest io.Reader = body
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		rest = bytes.NewReader(nil)
+	case err != nil:
+		// The reader of the body gets the error after the bytes read so far
+		rest = &errReader{err: err}
+	}
+	replay := &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), rest), Closer: body}
+	return buf[:min(n, c.config.MaxBytes)], n > c.config.MaxBytes, replay
+}
+
+// record adds a scrubbed body to the span in ctx, or emits it as a log record
+func (c *bodyCapture) record(ctx context.Context, key attribute.Key, body []byte, truncated bool) {
+	if len(body) == 0 {
+		return
+	}
+	text := c.scrub(body)
+	truncatedKey := key + ".truncated"
+
+	if !c.config.Log {
+		oteltrace.SpanFromContext(ctx).SetAttributes(key.String(text), truncatedKey.Bool(truncated))
+		return
+	}
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityDebug)
+	record.SetSeverityText("DEBUG")
+	record.SetBody(otellog.StringValue(string(key)))
+	record.AddAttributes(otellog.String(string(key), text), otellog.Bool(string(truncatedKey), truncated))
+	GetLoggerProvider().Logger(tracerName).Emit(ctx, record)
+}
+
+// HTTPBodyCaptureMiddleware records truncated, scrubbed request and response bodies on the
+// request span, for debugging. Only bodies with an allowed content type are captured. Wrap it
+// with HTTPMiddleware so the span exists: iudex.HTTPMiddleware(iudex.HTTPBodyCaptureMiddleware(mux, config)).
+// The start of the request body is read before handler runs.
+func HTTPBodyCaptureMiddleware(handler http.Handler, config BodyCaptureConfig) http.Handler {
+	capture := newBodyCapture(config)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody && capture.allowed(r.Header.Get("Content-Type")) {
+			body, truncated, replay := capture.peek(r.Body)
+			r.Body = replay
+			capture.record(r.Context(), HTTPRequestBodyKey, body, truncated)
+		}
+
+		rw := &responseRecorder{ResponseWriter: w, capture: &limitedBuffer{max: capture.config.MaxBytes}}
+		handler.ServeHTTP(rw, r)
+
+		if capture.allowed(w.Header().Get("Content-Type")) {
+			capture.record(r.Context(), HTTPResponseBodyKey, rw.capture.Bytes(), rw.n > int64(capture.config.MaxBytes))
+		}
+	})
+}
+
+// HTTPBodyCaptureTransport records truncated, scrubbed request and response bodies on the
+// client span, for debugging. Wrap it with HTTPTransport so the span exists:
+// iudex.HTTPTransport(iudex.HTTPBodyCaptureTransport(nil, config)). A nil rt wraps
+// http.DefaultTransport. The start of the response body is read before RoundTrip returns.
+func HTTPBodyCaptureTransport(rt http.RoundTripper, config BodyCaptureConfig) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &bodyCaptureTransport{rt: rt, capture: newBodyCapture(config)}
+}
+
+type bodyCaptureTransport struct {
+	rt      http.RoundTripper
+	capture *bodyCapture
+}
+
+func (t *bodyCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if req.Body != nil && req.Body != http.NoBody && t.capture.allowed(req.Header.Get("Content-Type")) {
+		// RoundTrippers must not modify the request, so the body is replaced on a copy
+		body, truncated, replay := t.capture.peek(req.Body)
+		req = req.Clone(ctx)
+		req.Body = replay
+		t.capture.record(ctx, HTTPRequestBodyKey, body, truncated)
+	}
+
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Body != nil && resp.Body != http.NoBody && t.capture.allowed(resp.Header.Get("Content-Type")) {
+		body, truncated, replay := t.capture.peek(resp.Body)
+		resp.Body = replay
+		t.capture.record(ctx, HTTPResponseBodyKey, body, truncated)
+	}
+	return resp, nil
+}
+
+// replayBody reads the bytes peeked from a body followed by the rest of it
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader fails every read with err
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// requestBodyRecorder counts, and optionally captures, a request body as the handler reads it
+type requestBodyRecorder struct {
+	io.ReadCloser
+	n       int64
+	capture *limitedBuffer
+}
+
+func (b *requestBodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if b.capture != nil {
+		b.capture.Write(p[:n])
+	}
+	return n, err
+}
+
+// responseRecorder records the status and counts, and optionally captures, the response body
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	n       int64
+	capture *limitedBuffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	// Informational responses precede the final status
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	if w.capture != nil {
+		w.capture.Write(p[:n])
+	}
+	return n, err
+}
+
+// Flush supports streaming handlers that type assert http.Flusher
+func (w *responseRecorder) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack supports handlers that take over the connection, e.g. websocket upgrades
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Push supports HTTP/2 server push
+func (w *responseRecorder) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom keeps the sendfile optimization of the underlying writer when nothing is captured
+func (w *responseRecorder) ReadFrom(r io.Reader) (int64, error) {
+	readerFrom, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok || w.capture != nil {
+		// Hide ReadFrom from io.Copy, which would call it again
+		return io.Copy(struct{ io.Writer }{w}, r)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := readerFrom.ReadFrom(r)
+	w.n += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
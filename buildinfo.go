@@ -0,0 +1,47 @@
+package iudex
+
+import (
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Resource attributes read from the build info stamped by go build
+const (
+	VCSRevisionKey  = attribute.Key("vcs.revision")
+	VCSTimeKey      = attribute.Key("vcs.time")
+	VCSModifiedKey  = attribute.Key("vcs.modified")
+	GoModulePathKey = attribute.Key("go.module.path")
+)
+
+// buildInfoAttributes describes the binary: service.version from the version of the main module,
+// its module path, and, unless a commit is configured, the VCS revision it was built from. Builds
+// with go run or outside a repository carry no version or revision.
+func buildInfoAttributes(config InstrumentationConfig) []attribute.KeyValue {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	if version := info.Main.Version; version != "" && version != "(devel)" {
+		attrs = append(attrs, semconv.ServiceVersion(version))
+	}
+	if info.Main.Path != "" {
+		attrs = append(attrs, GoModulePathKey.String(info.Main.Path))
+	}
+	if config.GitCommit != nil {
+		return attrs
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			attrs = append(attrs, VCSRevisionKey.String(setting.Value), attribute.String("git.commit", setting.Value))
+		case "vcs.time":
+			attrs = append(attrs, VCSTimeKey.String(setting.Value))
+		case "vcs.modified":
+			attrs = append(attrs, VCSModifiedKey.Bool(setting.Value == "true"))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,211 @@
+package iudex
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Cardinality guard defaults
+const (
+	defaultCardinalityWindow = time.Minute
+	defaultCardinalityBudget = 1000
+	maxCardinalityOffenders  = 100
+)
+
+// Values normalized by the cardinality guard, since the last start of the process
+var (
+	normalizedSpanNames     atomic.Int64
+	normalizedAttributeKeys atomic.Int64
+	cardinalityGuards       atomic.Pointer[cardinalityGuard]
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	digitsPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// CardinalityGuardConfig bounds the distinct span names and attribute keys exported within a
+// window. Once a budget is spent, names and keys not seen before in the window are normalized,
+// replacing UUIDs with {uuid} and numbers with {n}, e.g. "GET /users/42" becomes "GET /users/{n}".
+// Zero values default to a one minute window and budgets of 1000.
+type CardinalityGuardConfig struct {
+	Window        time.Duration
+	SpanNames     int
+	AttributeKeys int
+}
+
+// NormalizeCardinality replaces UUIDs in s with {uuid} and numbers with {n}
+func NormalizeCardinality(s string) string {
+	s = uuidPattern.ReplaceAllLiteralString(s, "{uuid}")
+	return digitsPattern.ReplaceAllLiteralString(s, "{n}")
+}
+
+// cardinalityGuard tracks the distinct values of the current window
+type cardinalityGuard struct {
+	config CardinalityGuardConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	names       map[string]struct{}
+	keys        map[string]struct{}
+	offenders   map[string]struct{}
+	previous    []string
+}
+
+func newCardinalityGuard(config CardinalityGuardConfig) *cardinalityGuard {
+	if config.Window <= 0 {
+		config.Window = defaultCardinalityWindow
+	}
+	if config.SpanNames <= 0 {
+		config.SpanNames = defaultCardinalityBudget
+	}
+	if config.AttributeKeys <= 0 {
+		config.AttributeKeys = defaultCardinalityBudget
+	}
+	return &cardinalityGuard{config: config, now: time.Now}
+}
+
+// spanName returns name, or its normalized form when it is new and the budget is spent
+func (g *cardinalityGuard) spanName(name string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rotate()
+	normalized, ok := g.admit(g.names, g.config.SpanNames, name, "span name")
+	if ok {
+		normalizedSpanNames.Add(1)
+	}
+	return normalized
+}
+
+// attributes returns attrs with new keys normalized once the budget is spent. Keys that normalize
+// to the same key keep the first value.
+func (g *cardinalityGuard) attributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rotate()
+	var guarded []attribute.KeyValue
+	for i, attr := range attrs {
+		key, ok := g.admit(g.keys, g.config.AttributeKeys, string(attr.Key), "attribute key")
+		if !ok {
+			if guarded != nil {
+				guarded = append(guarded, attr)
+			}
+			continue
+		}
+		normalizedAttributeKeys.Add(1)
+		if guarded == nil {
+			guarded = append(make([]attribute.KeyValue, 0, len(attrs)), attrs[:i]...)
+		}
+		if !slices.ContainsFunc(guarded, func(kv attribute.KeyValue) bool { return kv.Key == attribute.Key(key) }) {
+			guarded = append(guarded, attribute.KeyValue{Key: attribute.Key(key), Value: attr.Value})
+		}
+	}
+	if guarded == nil {
+		return attrs
+	}
+	return guarded
+}
+
+// admit records value in seen while the budget lasts. Past it, unseen values are normalized and
+// it reports whether value changed.
+func (g *cardinalityGuard) admit(seen map[string]struct{}, budget int, value, kind string) (string, bool) {
+	if _, ok := seen[value]; ok {
+		return value, false
+	}
+	if len(seen) < budget {
+		seen[value] = struct{}{}
+		return value, false
+	}
+	normalized := NormalizeCardinality(value)
+	if normalized == value {
+		return value, false
+	}
+	offender := kind + " " + normalized
+	if _, ok := g.offenders[offender]; !ok && len(g.offenders) < maxCardinalityOffenders {
+		g.offenders[offender] = struct{}{}
+		otel.Handle(fmt.Errorf("%s cardinality exceeds %d per %s, normalizing %q to %q", kind, budget, g.config.Window, value, normalized))
+	}
+	return normalized, true
+}
+
+// rotate starts a new window once the current one is over
+func (g *cardinalityGuard) rotate() {
+	now := g.now()
+	if g.names != nil && now.Sub(g.windowStart) < g.config.Window {
+		return
+	}
+	if g.offenders != nil {
+		g.previous = g.offenderList()
+	}
+	g.windowStart = now
+	g.names = map[string]struct{}{}
+	g.keys = map[string]struct{}{}
+	g.offenders = map[string]struct{}{}
+}
+
+// offenderList returns the normalized values of the current window, sorted
+func (g *cardinalityGuard) offenderList() []string {
+	offenders := make([]string, 0, len(g.offenders))
+	for offender := range g.offenders {
+		offenders = append(offenders, offender)
+	}
+	slices.Sort(offenders)
+	return offenders
+}
+
+// recentOffenders returns the normalized values of the current and previous windows
+func (g *cardinalityGuard) recentOffenders() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	offenders := append(g.offenderList(), g.previous...)
+	slices.Sort(offenders)
+	return slices.Compact(offenders)
+}
+
+// cardinalityGuardSpanProcessor normalizes high cardinality span names and attribute keys before
+// handing spans to the next processor
+type cardinalityGuardSpanProcessor struct {
+	trace.SpanProcessor
+	guard *cardinalityGuard
+}
+
+// NewCardinalityGuardSpanProcessor wraps next so the span names and attribute keys it sees stay
+// within the budgets of config. Normalized values are counted in Stats, which lists the offending
+// patterns of the last two windows.
+func NewCardinalityGuardSpanProcessor(next trace.SpanProcessor, config CardinalityGuardConfig) trace.SpanProcessor {
+	guard := newCardinalityGuard(config)
+	cardinalityGuards.Store(guard)
+	return &cardinalityGuardSpanProcessor{SpanProcessor: next, guard: guard}
+}
+
+func (p *cardinalityGuardSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(&guardedSpan{
+		ReadOnlySpan: s,
+		name:         p.guard.spanName(s.Name()),
+		attributes:   p.guard.attributes(s.Attributes()),
+	})
+}
+
+// guardedSpan overrides the name and attributes of a ReadOnlySpan
+type guardedSpan struct {
+	trace.ReadOnlySpan
+	name       string
+	attributes []attribute.KeyValue
+}
+
+func (s *guardedSpan) Name() string {
+	return s.name
+}
+
+func (s *guardedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
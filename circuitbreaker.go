@@ -0,0 +1,261 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Circuit breaker defaults
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerOpenTimeout      = 30 * time.Second
+	defaultBreakerMaxOpenTimeout   = 5 * time.Minute
+)
+
+// ErrCircuitOpen is returned by exports skipped while the circuit breaker is open and there is no fallback
+var ErrCircuitOpen = errors.New("iudex export circuit breaker is open")
+
+// CircuitBreakerConfig stops exporting spans and logs to IUDEX after repeated failures, so a
+// failing endpoint does not fill the export queues and the error log. While the circuit is open,
+// telemetry goes to the fallback, or is dropped without one. After OpenTimeout a single export
+// probes IUDEX again, and the circuit closes once one succeeds.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed exports that opens the circuit, 5 by default
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing, 30s by default. It doubles
+	// after every failed probe, up to MaxOpenTimeout, which defaults to 5m.
+	OpenTimeout    time.Duration
+	MaxOpenTimeout time.Duration
+
+	// At most one fallback may be set
+	// FallbackStdout prints telemetry to stdout while the circuit is open
+	FallbackStdout bool
+	// FallbackFile appends telemetry to the file at this path, one JSON object per span or record
+	FallbackFile string
+	// FallbackDestination exports telemetry to a secondary OTLP endpoint
+	FallbackDestination *ExportDestination
+}
+
+// resolved fills the zero values with the defaults
+func (c CircuitBreakerConfig) resolved() CircuitBreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = defaultBreakerOpenTimeout
+	}
+	if c.MaxOpenTimeout <= 0 {
+		c.MaxOpenTimeout = defaultBreakerMaxOpenTimeout
+	}
+	c.MaxOpenTimeout = max(c.MaxOpenTimeout, c.OpenTimeout)
+	return c
+}
+
+// fallbackWriter opens the writer of a stdout or file fallback, or returns nil for none
+func (c CircuitBreakerConfig) fallbackWriter() (io.WriteCloser, error) {
+	set := 0
+	for _, ok := range []bool{c.FallbackStdout, c.FallbackFile != "", c.FallbackDestination != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("circuit breaker: only one fallback can be set")
+	}
+	switch {
+	case c.FallbackStdout:
+		return nopCloser{os.Stdout}, nil
+	case c.FallbackFile != "":
+		f, err := os.OpenFile(c.FallbackFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("circuit breaker: %w", err)
+		}
+		return f, nil
+	}
+	return nil, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// circuitBreaker tracks consecutive export failures of one signal
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+	signal string
+	now    func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+	timeout   time.Duration
+	probing   bool
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig, signal string) *circuitBreaker {
+	config = config.resolved()
+	return &circuitBreaker{config: config, signal: signal, now: time.Now, timeout: config.OpenTimeout}
+}
+
+// allow reports whether an export may go to the primary exporter. Once the open timeout has
+// passed, a single export is let through to probe it.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of an export to the primary exporter
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures, b.open, b.probing, b.timeout = 0, false, false, b.config.OpenTimeout
+		return
+	}
+	if b.probing {
+		b.probing = false
+		b.timeout = min(2*b.timeout, b.config.MaxOpenTimeout)
+		b.openUntil = b.now().Add(b.timeout)
+		return
+	}
+	b.failures++
+	if !b.open && b.failures >= b.config.FailureThreshold {
+		b.open = true
+		b.openUntil = b.now().Add(b.timeout)
+		otel.Handle(fmt.Errorf("%s export circuit breaker opened after %d failed exports: %w", b.signal, b.failures, err))
+	}
+}
+
+// circuitBreakerSpanExporter sends spans to the fallback while the circuit of the primary exporter is open
+type circuitBreakerSpanExporter struct {
+	trace.SpanExporter
+	fallback trace.SpanExporter
+	closer   io.Closer
+	breaker  *circuitBreaker
+}
+
+func (e *circuitBreakerSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if e.breaker.allow() {
+		err := e.SpanExporter.ExportSpans(ctx, spans)
+		e.breaker.record(err)
+		if err == nil || e.fallback == nil {
+			return err
+		}
+	}
+	if e.fallback == nil {
+		return ErrCircuitOpen
+	}
+	return e.fallback.ExportSpans(ctx, spans)
+}
+
+func (e *circuitBreakerSpanExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if e.fallback != nil {
+		err = errors.Join(err, e.fallback.Shutdown(ctx))
+	}
+	if e.closer != nil {
+		err = errors.Join(err, e.closer.Close())
+	}
+	return err
+}
+
+// circuitBreakerLogExporter sends log records to the fallback while the circuit of the primary exporter is open
+type circuitBreakerLogExporter struct {
+	log.Exporter
+	fallback log.Exporter
+	closer   io.Closer
+	breaker  *circuitBreaker
+}
+
+func (e *circuitBreakerLogExporter) Export(ctx context.Context, records []log.Record) error {
+	if e.breaker.allow() {
+		err := e.Exporter.Export(ctx, records)
+		e.breaker.record(err)
+		if err == nil || e.fallback == nil {
+			return err
+		}
+	}
+	if e.fallback == nil {
+		return ErrCircuitOpen
+	}
+	return e.fallback.Export(ctx, records)
+}
+
+func (e *circuitBreakerLogExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	if e.fallback != nil {
+		err = errors.Join(err, e.fallback.Shutdown(ctx))
+	}
+	if e.closer != nil {
+		err = errors.Join(err, e.closer.Close())
+	}
+	return err
+}
+
+// withTraceCircuitBreaker wraps exp with a circuit breaker when one is configured
+func withTraceCircuitBreaker(ctx context.Context, config InstrumentationConfig, exp trace.SpanExporter) (trace.SpanExporter, error) {
+	if config.CircuitBreaker == nil || isDebug(config) {
+		return exp, nil
+	}
+	breaker := &circuitBreakerSpanExporter{SpanExporter: exp, breaker: newCircuitBreaker(*config.CircuitBreaker, "traces")}
+	w, err := config.CircuitBreaker.fallbackWriter()
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		breaker.closer = w
+		breaker.fallback, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	} else if dest := config.CircuitBreaker.FallbackDestination; dest != nil {
+		breaker.fallback, err = newTraceExporter(ctx, dest.config(config), &dest.Headers)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("circuit breaker fallback: %w", err)
+	}
+	return breaker, nil
+}
+
+// withLogCircuitBreaker wraps exp with a circuit breaker when one is configured
+func withLogCircuitBreaker(ctx context.Context, config InstrumentationConfig, exp log.Exporter) (log.Exporter, error) {
+	if config.CircuitBreaker == nil || isDebug(config) {
+		return exp, nil
+	}
+	breaker := &circuitBreakerLogExporter{Exporter: exp, breaker: newCircuitBreaker(*config.CircuitBreaker, "logs")}
+	w, err := config.CircuitBreaker.fallbackWriter()
+	if err != nil {
+		return nil, err
+	}
+	if w != nil {
+		breaker.closer = w
+		breaker.fallback, err = stdoutlog.New(stdoutlog.WithWriter(w))
+	} else if dest := config.CircuitBreaker.FallbackDestination; dest != nil {
+		breaker.fallback, err = newLogExporter(ctx, dest.config(config), &dest.Headers)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("circuit breaker fallback: %w", err)
+	}
+	return breaker, nil
+}
@@ -0,0 +1,345 @@
+// Package client queries logs and traces from the IUDEX API and manages alert monitors, for
+// internal tooling, CI checks, and infrastructure-as-code. The API requires a private API key; public write-only keys are
+// rejected.
+//
+//	c := client.New(os.Getenv("IUDEX_API_KEY"))
+//	page, err := c.SearchLogs(ctx, client.LogQuery{
+//		Service: "checkout",
+//		Start:   time.Now().Add(-time.Hour),
+//		Query:   "payment failed",
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the IUDEX API used unless WithBaseURL is set
+const DefaultBaseURL = "https://api.iudex.ai"
+
+// Errors matched by APIError, for use with errors.Is
+var (
+	ErrUnauthorized = errors.New("IUDEX API key was rejected")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// APIError is returned when the API responds with an error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("iudex api: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("iudex api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches ErrUnauthorized, ErrNotFound, and ErrRateLimited by status code
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// Client queries the IUDEX API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL sets the API URL, e.g. for a regional or self-hosted deployment
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests, e.g. to add a timeout or a traced transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a client authenticated with a private API key
+func New(apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		apiKey:     apiKey,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LogQuery selects log records. Zero fields do not filter. Start and End default to the last hour.
+type LogQuery struct {
+	Service  string
+	Start    time.Time
+	End      time.Time
+	TraceID  string
+	Severity string // minimum severity, e.g. "warn"
+	Query    string // full text search of the body and attributes
+	Limit    int
+	Cursor   string // LogPage.NextCursor of the previous page
+}
+
+// Log is a log record
+type Log struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	Service    string         `json:"service"`
+	Severity   string         `json:"severity"`
+	Body       string         `json:"body"`
+	TraceID    string         `json:"trace_id,omitempty"`
+	SpanID     string         `json:"span_id,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// LogPage is a page of log records, newest first
+type LogPage struct {
+	Logs []Log `json:"logs"`
+	// NextCursor fetches the next page, and is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// TraceQuery selects traces by their root span. Zero fields do not filter. Start and End
+// default to the last hour.
+type TraceQuery struct {
+	Service     string
+	Start       time.Time
+	End         time.Time
+	SpanName    string
+	ErrorsOnly  bool
+	MinDuration time.Duration
+	Limit       int
+	Cursor      string // TracePage.NextCursor of the previous page
+}
+
+// queryRequest is the request body of both searches
+type queryRequest struct {
+	Service       string `json:"service,omitempty"`
+	Start         string `json:"start,omitempty"`
+	End           string `json:"end,omitempty"`
+	TraceID       string `json:"trace_id,omitempty"`
+	Severity      string `json:"severity,omitempty"`
+	Query         string `json:"query,omitempty"`
+	SpanName      string `json:"span_name,omitempty"`
+	ErrorsOnly    bool   `json:"errors_only,omitempty"`
+	MinDurationMs int64  `json:"min_duration_ms,omitempty"`
+	Limit         int    `json:"limit,omitempty"`
+	Cursor        string `json:"cursor,omitempty"`
+}
+
+// formatTime formats t as RFC 3339, or returns "" for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// TraceSummary describes a trace by its root span
+type TraceSummary struct {
+	TraceID   string
+	Service   string
+	RootSpan  string
+	StartTime time.Time
+	Duration  time.Duration
+	SpanCount int
+	Error     bool
+}
+
+// TracePage is a page of traces, newest first
+type TracePage struct {
+	Traces []TraceSummary
+	// NextCursor fetches the next page, and is empty on the last page
+	NextCursor string
+}
+
+// Span is a span of a trace
+type Span struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Service       string         `json:"service"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	StatusCode    string         `json:"status_code"` // "unset", "ok", or "error"
+	StatusMessage string         `json:"status_message,omitempty"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+}
+
+// Trace is a complete trace
+type Trace struct {
+	TraceID string `json:"trace_id"`
+	Spans   []Span `json:"spans"`
+}
+
+// SearchLogs returns a page of the log records matching q
+func (c *Client) SearchLogs(ctx context.Context, q LogQuery) (*LogPage, error) {
+	req := queryRequest{
+		Service:  q.Service,
+		Start:    formatTime(q.Start),
+		End:      formatTime(q.End),
+		TraceID:  q.TraceID,
+		Severity: q.Severity,
+		Query:    q.Query,
+		Limit:    q.Limit,
+		Cursor:   q.Cursor,
+	}
+	var page LogPage
+	if err := c.do(ctx, http.MethodPost, "/v1/query/logs", req, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Logs iterates over every log record matching q, fetching pages as needed.
+// Iteration stops after the first error.
+func (c *Client) Logs(ctx context.Context, q LogQuery) iter.Seq2[Log, error] {
+	return func(yield func(Log, error) bool) {
+		for {
+			page, err := c.SearchLogs(ctx, q)
+			if err != nil {
+				yield(Log{}, err)
+				return
+			}
+			for _, log := range page.Logs {
+				if !yield(log, nil) {
+					return
+				}
+			}
+			if page.NextCursor == "" {
+				return
+			}
+			q.Cursor = page.NextCursor
+		}
+	}
+}
+
+// SearchTraces returns a page of the traces matching q
+func (c *Client) SearchTraces(ctx context.Context, q TraceQuery) (*TracePage, error) {
+	req := queryRequest{
+		Service:       q.Service,
+		Start:         formatTime(q.Start),
+		End:           formatTime(q.End),
+		SpanName:      q.SpanName,
+		ErrorsOnly:    q.ErrorsOnly,
+		MinDurationMs: q.MinDuration.Milliseconds(),
+		Limit:         q.Limit,
+		Cursor:        q.Cursor,
+	}
+	var page struct {
+		Traces []struct {
+			TraceID    string    `json:"trace_id"`
+			Service    string    `json:"service"`
+			RootSpan   string    `json:"root_span"`
+			StartTime  time.Time `json:"start_time"`
+			DurationMs float64   `json:"duration_ms"`
+			SpanCount  int       `json:"span_count"`
+			Error      bool      `json:"error"`
+		} `json:"traces"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/query/traces", req, &page); err != nil {
+		return nil, err
+	}
+
+	result := &TracePage{Traces: make([]TraceSummary, len(page.Traces)), NextCursor: page.NextCursor}
+	for i, t := range page.Traces {
+		result.Traces[i] = TraceSummary{
+			TraceID:   t.TraceID,
+			Service:   t.Service,
+			RootSpan:  t.RootSpan,
+			StartTime: t.StartTime,
+			Duration:  time.Duration(t.DurationMs * float64(time.Millisecond)),
+			SpanCount: t.SpanCount,
+			Error:     t.Error,
+		}
+	}
+	return result, nil
+}
+
+// GetTrace returns every span of a trace. The error matches ErrNotFound for unknown trace IDs.
+func (c *Client) GetTrace(ctx context.Context, traceID string) (*Trace, error) {
+	var trace Trace
+	if err := c.do(ctx, http.MethodGet, "/v1/query/traces/"+url.PathEscape(traceID), nil, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		var errBody struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &errBody) == nil && errBody.Message != "" {
+			apiErr.Message = errBody.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("iudex api: decode response: %w", err)
+	}
+	return nil
+}
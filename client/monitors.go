@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Monitor types
+const (
+	// MonitorTypeLog alerts when more than Threshold log records match Query within Window
+	MonitorTypeLog = "log"
+	// MonitorTypeLatency alerts when the Percentile duration of spans named SpanName exceeds
+	// LatencyThreshold within Window
+	MonitorTypeLatency = "latency"
+)
+
+// Monitor is an alert rule evaluated by IUDEX
+type Monitor struct {
+	ID      string // assigned by IUDEX on creation
+	Name    string
+	Type    string // MonitorTypeLog or MonitorTypeLatency
+	Service string
+	Window  time.Duration
+	// Channels are the notification channels alerted, e.g. "slack:#alerts" or "email:oncall@example.com"
+	Channels []string
+	Disabled bool
+
+	// Log monitors
+	Query     string
+	Severity  string // minimum severity, e.g. "error"
+	Threshold int
+
+	// Latency monitors
+	SpanName         string
+	Percentile       float64 // e.g. 99, defaults to 95
+	LatencyThreshold time.Duration
+
+	CreatedAt time.Time // set by IUDEX
+	UpdatedAt time.Time // set by IUDEX
+}
+
+// monitorJSON is the wire format of a Monitor
+type monitorJSON struct {
+	ID                 string     `json:"id,omitempty"`
+	Name               string     `json:"name"`
+	Type               string     `json:"type"`
+	Service            string     `json:"service,omitempty"`
+	WindowSeconds      float64    `json:"window_seconds,omitempty"`
+	Channels           []string   `json:"channels,omitempty"`
+	Disabled           bool       `json:"disabled,omitempty"`
+	Query              string     `json:"query,omitempty"`
+	Severity           string     `json:"severity,omitempty"`
+	Threshold          int        `json:"threshold,omitempty"`
+	SpanName           string     `json:"span_name,omitempty"`
+	Percentile         float64    `json:"percentile,omitempty"`
+	LatencyThresholdMs float64    `json:"latency_threshold_ms,omitempty"`
+	CreatedAt          *time.Time `json:"created_at,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+func (m Monitor) toJSON() monitorJSON {
+	return monitorJSON{
+		ID:                 m.ID,
+		Name:               m.Name,
+		Type:               m.Type,
+		Service:            m.Service,
+		WindowSeconds:      m.Window.Seconds(),
+		Channels:           m.Channels,
+		Disabled:           m.Disabled,
+		Query:              m.Query,
+		Severity:           m.Severity,
+		Threshold:          m.Threshold,
+		SpanName:           m.SpanName,
+		Percentile:         m.Percentile,
+		LatencyThresholdMs: float64(m.LatencyThreshold) / float64(time.Millisecond),
+	}
+}
+
+func (m monitorJSON) toMonitor() Monitor {
+	monitor := Monitor{
+		ID:               m.ID,
+		Name:             m.Name,
+		Type:             m.Type,
+		Service:          m.Service,
+		Window:           time.Duration(m.WindowSeconds * float64(time.Second)),
+		Channels:         m.Channels,
+		Disabled:         m.Disabled,
+		Query:            m.Query,
+		Severity:         m.Severity,
+		Threshold:        m.Threshold,
+		SpanName:         m.SpanName,
+		Percentile:       m.Percentile,
+		LatencyThreshold: time.Duration(m.LatencyThresholdMs * float64(time.Millisecond)),
+	}
+	if m.CreatedAt != nil {
+		monitor.CreatedAt = *m.CreatedAt
+	}
+	if m.UpdatedAt != nil {
+		monitor.UpdatedAt = *m.UpdatedAt
+	}
+	return monitor
+}
+
+// validate checks the fields the API requires, so mistakes fail before a request is sent
+func (m Monitor) validate() error {
+	if m.Name == "" {
+		return errors.New("monitor name is required")
+	}
+	if m.Window <= 0 {
+		return fmt.Errorf("monitor %q: window must be positive", m.Name)
+	}
+	switch m.Type {
+	case MonitorTypeLog:
+		if m.Query == "" && m.Severity == "" {
+			return fmt.Errorf("monitor %q: log monitors need a query or a severity", m.Name)
+		}
+	case MonitorTypeLatency:
+		if m.LatencyThreshold <= 0 {
+			return fmt.Errorf("monitor %q: latency monitors need a latency threshold", m.Name)
+		}
+		if m.Percentile < 0 || m.Percentile > 100 {
+			return fmt.Errorf("monitor %q: percentile must be between 0 and 100", m.Name)
+		}
+	default:
+		return fmt.Errorf("monitor %q: unknown type %q", m.Name, m.Type)
+	}
+	return nil
+}
+
+// CreateMonitor creates m and returns it with its ID
+func (c *Client) CreateMonitor(ctx context.Context, m Monitor) (*Monitor, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	var created monitorJSON
+	if err := c.do(ctx, http.MethodPost, "/v1/monitors", m.toJSON(), &created); err != nil {
+		return nil, err
+	}
+	result := created.toMonitor()
+	return &result, nil
+}
+
+// UpdateMonitor replaces the monitor with m.ID by m
+func (c *Client) UpdateMonitor(ctx context.Context, m Monitor) (*Monitor, error) {
+	if m.ID == "" {
+		return nil, errors.New("monitor ID is required")
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	var updated monitorJSON
+	if err := c.do(ctx, http.MethodPut, "/v1/monitors/"+url.PathEscape(m.ID), m.toJSON(), &updated); err != nil {
+		return nil, err
+	}
+	result := updated.toMonitor()
+	return &result, nil
+}
+
+// GetMonitor returns a monitor. The error matches ErrNotFound for unknown IDs.
+func (c *Client) GetMonitor(ctx context.Context, id string) (*Monitor, error) {
+	var m monitorJSON
+	if err := c.do(ctx, http.MethodGet, "/v1/monitors/"+url.PathEscape(id), nil, &m); err != nil {
+		return nil, err
+	}
+	result := m.toMonitor()
+	return &result, nil
+}
+
+// ListMonitors returns every monitor
+func (c *Client) ListMonitors(ctx context.Context) ([]Monitor, error) {
+	var list struct {
+		Monitors []monitorJSON `json:"monitors"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/monitors", nil, &list); err != nil {
+		return nil, err
+	}
+	monitors := make([]Monitor, len(list.Monitors))
+	for i, m := range list.Monitors {
+		monitors[i] = m.toMonitor()
+	}
+	return monitors, nil
+}
+
+// DeleteMonitor deletes a monitor. Deleting a monitor that does not exist is not an error,
+// so provisioning tools can delete idempotently.
+func (c *Client) DeleteMonitor(ctx context.Context, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/v1/monitors/"+url.PathEscape(id), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
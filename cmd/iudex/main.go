@@ -0,0 +1,266 @@
+// Command iudex checks and exercises an IUDEX telemetry pipeline from a shell.
+//
+//	iudex doctor [-config iudex.yaml]
+//	iudex send-log [-config iudex.yaml] [-severity info] [-attr key=value ...] message
+//	iudex send-span [-config iudex.yaml] [-duration 100ms] [-error] [-attr key=value ...] name
+//
+// Settings come from the config file, if any, and the same environment variables as the SDK.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const usage = `Usage: iudex <command> [flags] [args]
+
+Commands:
+  doctor      validate the configuration and check connectivity to IUDEX
+  send-log    export a test log record
+  send-span   export a test span
+
+Run "iudex <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "doctor":
+		err = doctor(args)
+	case "send-log":
+		err = sendLog(args)
+	case "send-span":
+		err = sendSpan(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "iudex: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "iudex:", err)
+		os.Exit(1)
+	}
+}
+
+// commonFlags are the flags shared by every command
+type commonFlags struct {
+	configPath  string
+	serviceName string
+	timeout     time.Duration
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configPath, "config", "", "YAML or JSON config file (default: environment only)")
+	fs.StringVar(&c.serviceName, "service", "", "service name of exported telemetry (default: SERVICE_NAME)")
+	fs.DurationVar(&c.timeout, "timeout", 10*time.Second, "how long to wait for IUDEX")
+}
+
+// loadConfig reads the config file, if any. Unset settings fall back to the environment, the
+// API key included.
+func (c *commonFlags) loadConfig() (iudex.InstrumentationConfig, error) {
+	config := iudex.InstrumentationConfig{}
+	if c.configPath != "" {
+		var err error
+		if config, err = iudex.LoadConfig(c.configPath); err != nil {
+			return config, err
+		}
+	}
+	if c.serviceName != "" {
+		config.ServiceName = iudex.StringPtr(c.serviceName)
+	}
+	// A key in the file takes precedence over both key variables
+	if config.APIKey == nil && config.PublicAPIKey == nil {
+		defaults := iudex.GetDefaultConfig()
+		config.APIKey, config.PublicAPIKey = defaults.APIKey, defaults.PublicAPIKey
+	}
+	return config, nil
+}
+
+// attrFlag collects repeated -attr key=value flags
+type attrFlag []attribute.KeyValue
+
+func (a *attrFlag) String() string {
+	pairs := make([]string, len(*a))
+	for i, attr := range *a {
+		pairs[i] = string(attr.Key) + "=" + attr.Value.Emit()
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (a *attrFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("attribute %q is not key=value", value)
+	}
+	*a = append(*a, attribute.String(key, val))
+	return nil
+}
+
+func doctor(args []string) error {
+	var common commonFlags
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	common.register(fs)
+	_ = fs.Parse(args)
+
+	config, err := common.loadConfig()
+	if err != nil {
+		fmt.Println("✗ config:", err)
+		return errors.New("configuration is invalid")
+	}
+	if common.configPath != "" {
+		fmt.Println("✓ config:", common.configPath)
+	} else {
+		fmt.Println("✓ config: environment")
+	}
+	disabled := config.Disabled
+	if disabled == nil {
+		disabled = iudex.GetDefaultConfig().Disabled
+	}
+	if *disabled {
+		fmt.Println("! instrumentation is disabled, nothing will be exported")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+	result, err := iudex.Ping(ctx, config)
+	if result.Endpoint == "" && err == nil {
+		fmt.Println("! debug mode: telemetry is printed, not exported")
+		return nil
+	}
+	if err != nil {
+		var pingErr *iudex.PingError
+		switch {
+		case errors.Is(err, iudex.ErrMissingAPIKey):
+			fmt.Println("✗ API key: not set, use API_KEY or PUBLIC_API_KEY")
+		case errors.As(err, &pingErr):
+			fmt.Printf("✓ API key: %s\n", pingErr.KeyType)
+			fmt.Printf("✗ %s: %v\n", result.Protocol, err)
+		default:
+			fmt.Println("✗", err)
+		}
+		return errors.New("IUDEX is not reachable with this configuration")
+	}
+	fmt.Printf("✓ API key: %s\n", result.KeyType)
+	fmt.Printf("✓ %s %s: accepted in %s\n", result.Protocol, result.Endpoint, result.Latency.Round(time.Millisecond))
+	return nil
+}
+
+func sendLog(args []string) error {
+	var common commonFlags
+	var attrs attrFlag
+	fs := flag.NewFlagSet("send-log", flag.ExitOnError)
+	common.register(fs)
+	level := fs.String("severity", "info", "severity: trace, debug, info, warn, error, or fatal")
+	fs.Var(&attrs, "attr", "attribute as key=value, repeatable")
+	_ = fs.Parse(args)
+
+	message := strings.Join(fs.Args(), " ")
+	if message == "" {
+		message = "iudex test log"
+	}
+	severity, err := iudex.ParseLogLevel(*level)
+	if err != nil {
+		return err
+	}
+
+	return withSDK(common, func(ctx context.Context) {
+		record := otellog.Record{}
+		record.SetTimestamp(time.Now())
+		record.SetSeverity(severity)
+		record.SetSeverityText(strings.ToUpper(*level))
+		record.SetBody(otellog.StringValue(message))
+		for _, attr := range attrs {
+			record.AddAttributes(otellog.String(string(attr.Key), attr.Value.AsString()))
+		}
+		iudex.GetLoggerProvider().Logger("iudex-cli").Emit(ctx, record)
+		fmt.Printf("sent log %q\n", message)
+	})
+}
+
+func sendSpan(args []string) error {
+	var common commonFlags
+	var attrs attrFlag
+	fs := flag.NewFlagSet("send-span", flag.ExitOnError)
+	common.register(fs)
+	duration := fs.Duration("duration", 100*time.Millisecond, "span duration")
+	failed := fs.Bool("error", false, "mark the span as failed")
+	fs.Var(&attrs, "attr", "attribute as key=value, repeatable")
+	_ = fs.Parse(args)
+
+	name := strings.Join(fs.Args(), " ")
+	if name == "" {
+		name = "iudex test span"
+	}
+
+	return withSDK(common, func(ctx context.Context) {
+		end := time.Now()
+		_, span := otel.Tracer("iudex-cli").Start(ctx, name,
+			oteltrace.WithTimestamp(end.Add(-*duration)),
+			oteltrace.WithAttributes(attrs...),
+		)
+		if *failed {
+			span.SetStatus(codes.Error, "test error")
+		}
+		span.End(oteltrace.WithTimestamp(end))
+		fmt.Printf("sent span %q in trace %s\n", name, span.SpanContext().TraceID())
+	})
+}
+
+// withSDK sets up the SDK, runs send, and shuts down so everything is exported before returning.
+// Export errors are reported through the SDK error handler, so they are collected from it.
+func withSDK(common commonFlags, send func(ctx context.Context)) error {
+	config, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var exportErrs []error
+	config.ErrorHandler = &iudex.ErrorHandlerConfig{
+		Interval: -1,
+		Handler: func(err error, _ int) {
+			mu.Lock()
+			defer mu.Unlock()
+			exportErrs = append(exportErrs, err)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+	shutdown, err := iudex.SetupOTelSDK(ctx, config)
+	if err != nil {
+		return err
+	}
+	send(ctx)
+	if err := shutdown(ctx); err != nil {
+		exportErrs = append(exportErrs, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(exportErrs) > 0 {
+		return fmt.Errorf("export failed: %w", errors.Join(exportErrs...))
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package iudex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the schema of a config file. Unset fields fall back to the environment defaults.
+type fileConfig struct {
+	Endpoint     *string           `yaml:"endpoint"`
+	APIKey       *string           `yaml:"api_key"`
+	PublicAPIKey *string           `yaml:"public_api_key"`
+	Protocol     *string           `yaml:"protocol"`
+	Insecure     *bool             `yaml:"insecure"`
+	Gzip         *bool             `yaml:"gzip"`
+	Headers      map[string]string `yaml:"headers"`
+	ProxyURL     *string           `yaml:"proxy_url"`
+	Propagators  []string          `yaml:"propagators"`
+	XRay         *bool             `yaml:"xray"`
+	Debug        *bool             `yaml:"debug"`
+
+	Sampling struct {
+		Sampler   *string  `yaml:"sampler"`
+		Ratio     *float64 `yaml:"ratio"`
+		RateLimit *float64 `yaml:"rate_limit"`
+		Tail      *struct {
+			LatencyThreshold time.Duration `yaml:"latency_threshold"`
+			BaselineRatio    float64       `yaml:"baseline_ratio"`
+			DecisionWait     time.Duration `yaml:"decision_wait"`
+			MaxTraces        int           `yaml:"max_traces"`
+		} `yaml:"tail"`
+		Remote *struct {
+			Interval time.Duration `yaml:"interval"`
+		} `yaml:"remote"`
+	} `yaml:"sampling"`
+
+	BaggageKeys    []string `yaml:"baggage_keys"`
+	SendUserEmail  *bool    `yaml:"send_user_email"`
+	UserHashSecret *string  `yaml:"user_hash_secret"`
+
+	Redaction struct {
+		Builtin []string `yaml:"builtin"`
+		Rules   []struct {
+			Name        string `yaml:"name"`
+			Pattern     string `yaml:"pattern"`
+			Replacement string `yaml:"replacement"`
+		} `yaml:"rules"`
+		AllowAttributes []string `yaml:"allow_attributes"`
+		DenyAttributes  []string `yaml:"deny_attributes"`
+	} `yaml:"redaction"`
+
+	AttributeLimits *struct {
+		ValueLength            int `yaml:"value_length"`
+		SpanAttributeCount     int `yaml:"span_attribute_count"`
+		LogAttributeCount      int `yaml:"log_attribute_count"`
+		EventCount             int `yaml:"event_count"`
+		LinkCount              int `yaml:"link_count"`
+		AttributePerEventCount int `yaml:"attribute_per_event_count"`
+		AttributePerLinkCount  int `yaml:"attribute_per_link_count"`
+	} `yaml:"attribute_limits"`
+	TruncateValues   *int `yaml:"truncate_values"`
+	CardinalityGuard *struct {
+		Window        time.Duration `yaml:"window"`
+		SpanNames     int           `yaml:"span_names"`
+		AttributeKeys int           `yaml:"attribute_keys"`
+	} `yaml:"cardinality_guard"`
+	TenantLimits *struct {
+		SpansPerSecond float64 `yaml:"spans_per_second"`
+		LogsPerSecond  float64 `yaml:"logs_per_second"`
+	} `yaml:"tenant_limits"`
+
+	ExportDestinations []struct {
+		Endpoint string            `yaml:"endpoint"`
+		Protocol string            `yaml:"protocol"`
+		Headers  map[string]string `yaml:"headers"`
+		Insecure bool              `yaml:"insecure"`
+	} `yaml:"export_destinations"`
+
+	TraceRetry *fileRetryConfig `yaml:"trace_retry"`
+	LogRetry   *fileRetryConfig `yaml:"log_retry"`
+
+	CircuitBreaker *struct {
+		FailureThreshold    int           `yaml:"failure_threshold"`
+		OpenTimeout         time.Duration `yaml:"open_timeout"`
+		MaxOpenTimeout      time.Duration `yaml:"max_open_timeout"`
+		FallbackStdout      bool          `yaml:"fallback_stdout"`
+		FallbackFile        string        `yaml:"fallback_file"`
+		FallbackDestination *struct {
+			Endpoint string            `yaml:"endpoint"`
+			Protocol string            `yaml:"protocol"`
+			Headers  map[string]string `yaml:"headers"`
+			Insecure bool              `yaml:"insecure"`
+		} `yaml:"fallback_destination"`
+	} `yaml:"circuit_breaker"`
+	DiskBuffer *struct {
+		Dir      string `yaml:"dir"`
+		MaxBytes int64  `yaml:"max_bytes"`
+	} `yaml:"disk_buffer"`
+
+	Serverless  *bool            `yaml:"serverless"`
+	TraceBatch  *fileBatchConfig `yaml:"trace_batch"`
+	LogBatch    *fileBatchConfig `yaml:"log_batch"`
+	LogLevel    *string          `yaml:"log_level"`
+	LogSampling *struct {
+		Interval   time.Duration `yaml:"interval"`
+		First      int           `yaml:"first"`
+		Thereafter int           `yaml:"thereafter"`
+	} `yaml:"log_sampling"`
+	MetricInterval *time.Duration `yaml:"metric_interval"`
+	SpanMetrics    *bool          `yaml:"span_metrics"`
+	TenantMetrics  *bool          `yaml:"tenant_metrics"`
+	SelfTelemetry  *bool          `yaml:"self_telemetry"`
+	Profiling      *struct {
+		Interval    time.Duration `yaml:"interval"`
+		CPUDuration time.Duration `yaml:"cpu_duration"`
+		DisableCPU  bool          `yaml:"disable_cpu"`
+		DisableHeap bool          `yaml:"disable_heap"`
+	} `yaml:"profiling"`
+	ProfileTriggers *bool `yaml:"profile_triggers"`
+	Heartbeat       *struct {
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"heartbeat"`
+	ConfigWatcher *struct {
+		Path     string        `yaml:"path"`
+		Remote   bool          `yaml:"remote"`
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"config_watcher"`
+
+	ServiceName        *string           `yaml:"service_name"`
+	InstanceID         *string           `yaml:"instance_id"`
+	Env                *string           `yaml:"env"`
+	GitCommit          *string           `yaml:"git_commit"`
+	GitHubURL          *string           `yaml:"github_url"`
+	ResourceAttributes map[string]string `yaml:"resource_attributes"`
+	GlobalAttributes   map[string]string `yaml:"global_attributes"`
+	HostAttributes     *bool             `yaml:"host_attributes"`
+	BuildInfo          *bool             `yaml:"build_info"`
+}
+
+type fileBatchConfig struct {
+	Timeout            time.Duration `yaml:"timeout"`
+	MaxQueueSize       int           `yaml:"max_queue_size"`
+	MaxExportBatchSize int           `yaml:"max_export_batch_size"`
+	ExportTimeout      time.Duration `yaml:"export_timeout"`
+}
+
+type fileRetryConfig struct {
+	Disabled        bool          `yaml:"disabled"`
+	InitialInterval time.Duration `yaml:"initial_interval"`
+	MaxInterval     time.Duration `yaml:"max_interval"`
+	MaxElapsedTime  time.Duration `yaml:"max_elapsed_time"`
+}
+
+// builtinRedactionRules are the rules a config file can refer to by name
+var builtinRedactionRules = map[string]RedactionRule{
+	RedactEmails.Name:       RedactEmails,
+	RedactCreditCards.Name:  RedactCreditCards,
+	RedactBearerTokens.Name: RedactBearerTokens,
+}
+
+// LoadConfig reads an InstrumentationConfig from a YAML or JSON file, so one file can configure
+// several services. Settings missing from the file fall back to the environment defaults, and
+// unknown keys are rejected. Files with a file_format key are read as OpenTelemetry declarative
+// configuration, so SDK settings can be shared with services in other languages. Pass the result
+// to SetupOTelSDK, after applying any options:
+//
+//	config, err := iudex.LoadConfig("iudex.yaml")
+//	if err != nil { ... }
+//	shutdown, err := iudex.SetupOTelSDK(ctx, config)
+func LoadConfig(path string) (InstrumentationConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return InstrumentationConfig{}, fmt.Errorf("load config: %w", err)
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return InstrumentationConfig{}, fmt.Errorf("load config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig decodes a config in either format LoadConfig reads, e.g. one received from a
+// configuration service
+func ParseConfig(data []byte) (InstrumentationConfig, error) {
+	if isOTelConfig(data) {
+		return parseOTelConfig(data)
+	}
+	return parseConfig(data)
+}
+
+// parseConfig decodes a config file. JSON is a subset of YAML, so one decoder handles both.
+func parseConfig(data []byte) (InstrumentationConfig, error) {
+	var file fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return InstrumentationConfig{}, err
+	}
+
+	config := InstrumentationConfig{
+		BaseURL:          file.Endpoint,
+		APIKey:           file.APIKey,
+		PublicAPIKey:     file.PublicAPIKey,
+		Protocol:         file.Protocol,
+		Insecure:         file.Insecure,
+		Gzip:             file.Gzip,
+		ProxyURL:         file.ProxyURL,
+		XRay:             file.XRay,
+		Debug:            file.Debug,
+		Sampler:          file.Sampling.Sampler,
+		SamplerRatio:     file.Sampling.Ratio,
+		SamplerRateLimit: file.Sampling.RateLimit,
+		Serverless:       file.Serverless,
+		MetricInterval:   file.MetricInterval,
+		SpanMetrics:      file.SpanMetrics,
+		TenantMetrics:    file.TenantMetrics,
+		TruncateValues:   file.TruncateValues,
+		SelfTelemetry:    file.SelfTelemetry,
+		SendUserEmail:    file.SendUserEmail,
+		UserHashSecret:   file.UserHashSecret,
+		ProfileTriggers:  file.ProfileTriggers,
+		ServiceName:      file.ServiceName,
+		InstanceID:       file.InstanceID,
+		Env:              file.Env,
+		GitCommit:        file.GitCommit,
+		GitHubURL:        file.GitHubURL,
+		HostAttributes:   file.HostAttributes,
+		BuildInfo:        file.BuildInfo,
+	}
+	if file.Headers != nil {
+		config.Headers = &file.Headers
+	}
+	if file.Propagators != nil {
+		config.Propagators = &file.Propagators
+	}
+	if file.BaggageKeys != nil {
+		config.BaggageKeys = &file.BaggageKeys
+	}
+	if file.ResourceAttributes != nil {
+		config.ResourceAttributes = &file.ResourceAttributes
+	}
+	for _, key := range slices.Sorted(maps.Keys(file.GlobalAttributes)) {
+		config.GlobalAttributes = append(config.GlobalAttributes, attribute.String(key, file.GlobalAttributes[key]))
+	}
+
+	for _, name := range file.Redaction.Builtin {
+		rule, ok := builtinRedactionRules[name]
+		if !ok {
+			return InstrumentationConfig{}, fmt.Errorf("unknown builtin redaction rule %q", name)
+		}
+		config.RedactionRules = append(config.RedactionRules, rule)
+	}
+	for _, r := range file.Redaction.Rules {
+		rule, err := NewRedactionRule(r.Name, r.Pattern)
+		if err != nil {
+			return InstrumentationConfig{}, err
+		}
+		rule.Replacement = r.Replacement
+		config.RedactionRules = append(config.RedactionRules, rule)
+	}
+	if file.Redaction.AllowAttributes != nil || file.Redaction.DenyAttributes != nil {
+		config.AttributeFilter = &AttributeFilter{
+			Allow: file.Redaction.AllowAttributes,
+			Deny:  file.Redaction.DenyAttributes,
+		}
+	}
+
+	if file.AttributeLimits != nil {
+		config.AttributeLimits = (*AttributeLimits)(file.AttributeLimits)
+	}
+	if watcher := file.ConfigWatcher; watcher != nil {
+		config.ConfigWatcher = &ConfigWatcherConfig{Path: watcher.Path, Remote: watcher.Remote, Interval: watcher.Interval}
+	}
+	if file.CardinalityGuard != nil {
+		config.CardinalityGuard = (*CardinalityGuardConfig)(file.CardinalityGuard)
+	}
+	if file.TenantLimits != nil {
+		config.TenantLimits = (*TenantLimitConfig)(file.TenantLimits)
+	}
+	for _, dest := range file.ExportDestinations {
+		config.ExportDestinations = append(config.ExportDestinations, ExportDestination(dest))
+	}
+	if file.TraceRetry != nil {
+		config.TraceRetry = (*RetryConfig)(file.TraceRetry)
+	}
+	if file.LogRetry != nil {
+		config.LogRetry = (*RetryConfig)(file.LogRetry)
+	}
+	if breaker := file.CircuitBreaker; breaker != nil {
+		config.CircuitBreaker = &CircuitBreakerConfig{
+			FailureThreshold: breaker.FailureThreshold,
+			OpenTimeout:      breaker.OpenTimeout,
+			MaxOpenTimeout:   breaker.MaxOpenTimeout,
+			FallbackStdout:   breaker.FallbackStdout,
+			FallbackFile:     breaker.FallbackFile,
+		}
+		if breaker.FallbackDestination != nil {
+			dest := ExportDestination(*breaker.FallbackDestination)
+			config.CircuitBreaker.FallbackDestination = &dest
+		}
+	}
+	if file.DiskBuffer != nil {
+		config.DiskBuffer = &DiskBufferConfig{Dir: file.DiskBuffer.Dir, MaxBytes: file.DiskBuffer.MaxBytes}
+	}
+	if file.LogLevel != nil {
+		severity, err := ParseLogLevel(*file.LogLevel)
+		if err != nil {
+			return InstrumentationConfig{}, err
+		}
+		config.LogLevel = &severity
+	}
+	if file.Sampling.Remote != nil {
+		config.RemoteSampling = (*RemoteSamplingConfig)(file.Sampling.Remote)
+	}
+	if file.Sampling.Tail != nil {
+		config.TailSampling = (*TailSamplingConfig)(file.Sampling.Tail)
+	}
+	if file.LogSampling != nil {
+		config.LogSampling = (*LogSamplingConfig)(file.LogSampling)
+	}
+	if file.Profiling != nil {
+		config.Profiling = (*ProfilingConfig)(file.Profiling)
+	}
+	if file.Heartbeat != nil {
+		config.Heartbeat = (*HeartbeatConfig)(file.Heartbeat)
+	}
+	if file.TraceBatch != nil {
+		config.TraceBatch = (*BatchConfig)(file.TraceBatch)
+	}
+	if file.LogBatch != nil {
+		config.LogBatch = (*BatchConfig)(file.LogBatch)
+	}
+	return config, nil
+}
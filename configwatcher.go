@@ -0,0 +1,315 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// defaultConfigWatchInterval is how often the config file and IUDEX are checked for changes
+const defaultConfigWatchInterval = 30 * time.Second
+
+// remoteConfigSourceName is the source of the remote config in configWatcher.last
+const remoteConfigSourceName = "remote"
+
+// configRequestTimeout bounds each request for the remote config
+const configRequestTimeout = 10 * time.Second
+
+// ConfigWatcherConfig reloads the settings that can change at runtime, the sampler, the log
+// level, and the redaction rules, without restarting the service. Other settings in the file or
+// the remote config are ignored until the next restart.
+type ConfigWatcherConfig struct {
+	// Path is a config file in the LoadConfig format, reloaded when its contents change
+	Path string
+	// Remote fetches the config of the service from IUDEX, so it can be changed centrally.
+	// It requires the HTTP protocol and takes precedence over Path.
+	Remote bool
+	// Interval is how often the file and IUDEX are checked, 30s by default
+	Interval time.Duration
+	// OnReload is called with the settings of every changed config, and the error that kept
+	// them from being applied, if any
+	OnReload func(config InstrumentationConfig, err error)
+}
+
+// samplingConfig holds the sampler settings that Reload changes
+var samplingConfig struct {
+	sync.Mutex
+	config InstrumentationConfig
+}
+
+// setSamplingConfig records the sampler settings of config for Reload
+func setSamplingConfig(config InstrumentationConfig) {
+	samplingConfig.Lock()
+	defer samplingConfig.Unlock()
+	samplingConfig.config = InstrumentationConfig{
+		Sampler:          config.Sampler,
+		SamplerRatio:     config.SamplerRatio,
+		SamplerRateLimit: config.SamplerRateLimit,
+		CustomSampler:    config.CustomSampler,
+	}
+}
+
+// Reload applies the settings of config that can change at runtime: Sampler, SamplerRatio,
+// SamplerRateLimit, and CustomSampler, LogLevel, and RedactionRules. Unset fields keep their
+// current value and other fields are ignored. Nothing is applied when the sampler is invalid.
+func Reload(config InstrumentationConfig) error {
+	if config.Sampler != nil || config.SamplerRatio != nil || config.SamplerRateLimit != nil || config.CustomSampler != nil {
+		samplingConfig.Lock()
+		sampling := samplingConfig.config
+		if config.Sampler != nil {
+			sampling.Sampler = config.Sampler
+		}
+		if config.SamplerRatio != nil {
+			sampling.SamplerRatio = config.SamplerRatio
+		}
+		if config.SamplerRateLimit != nil {
+			sampling.SamplerRateLimit = config.SamplerRateLimit
+		}
+		if config.CustomSampler != nil {
+			sampling.CustomSampler = config.CustomSampler
+		}
+		sampler, err := NewSampler(sampling)
+		if err != nil {
+			samplingConfig.Unlock()
+			return err
+		}
+		samplingConfig.config = sampling
+		SetSampler(sampler)
+		samplingConfig.Unlock()
+	}
+	if config.LogLevel != nil {
+		SetLogLevel(*config.LogLevel)
+	}
+	if config.RedactionRules != nil {
+		SetRedactionRules(config.RedactionRules...)
+	}
+	return nil
+}
+
+// configWatcher polls the config file and IUDEX and reloads the config when it changes
+type configWatcher struct {
+	config ConfigWatcherConfig
+	remote *remoteConfigSource
+	last   map[string][]byte
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// remoteConfigSource fetches settings of the service from IUDEX
+type remoteConfigSource struct {
+	url      string
+	headers  map[string]string
+	resource map[string]string
+	client   *http.Client
+}
+
+// newRemoteConfigSource creates a source for the IUDEX endpoint at path, which is queried with
+// the service name, instance ID, and environment of res
+func newRemoteConfigSource(config InstrumentationConfig, res *resource.Resource, headers *map[string]string, path string) (*remoteConfigSource, error) {
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	if protocol != ProtocolHTTP {
+		return nil, fmt.Errorf("requires the %q protocol", ProtocolHTTP)
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+	attrs := map[string]string{}
+	for _, attr := range res.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	return &remoteConfigSource{
+		url:      otlpHTTPURL(ep, path),
+		headers:  *headers,
+		resource: attrs,
+		client:   &http.Client{Transport: transport, Timeout: configRequestTimeout},
+	}, nil
+}
+
+// setupConfigWatcher starts the config watcher when one is configured and returns a function
+// that stops it, or nil
+func setupConfigWatcher(config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (func(context.Context) error, error) {
+	if config.ConfigWatcher == nil {
+		return nil, nil
+	}
+	if config.ConfigWatcher.Path == "" && !config.ConfigWatcher.Remote {
+		return nil, errors.New("config watcher needs a Path or Remote")
+	}
+	w := &configWatcher{
+		config: *config.ConfigWatcher,
+		last:   map[string][]byte{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if w.config.Interval <= 0 {
+		w.config.Interval = defaultConfigWatchInterval
+	}
+	// The service was set up from the file, so it is only reloaded once it changes
+	if w.config.Path != "" {
+		if data, err := os.ReadFile(w.config.Path); err == nil {
+			w.last[w.config.Path] = data
+		}
+	}
+	if w.config.Remote && !isDebug(config) {
+		remote, err := newRemoteConfigSource(config, res, headers, "/v1/config")
+		if err != nil {
+			return nil, fmt.Errorf("remote config: %w", err)
+		}
+		w.remote = remote
+	}
+	go w.loop()
+	return w.shutdown, nil
+}
+
+func (w *configWatcher) loop() {
+	defer close(w.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-w.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check reloads the file and then the remote config when either changed. The remote config wins,
+// so it is applied again over a changed file.
+func (w *configWatcher) check(ctx context.Context) {
+	fileChanged := false
+	if w.config.Path != "" {
+		data, err := os.ReadFile(w.config.Path)
+		if err != nil {
+			w.report(InstrumentationConfig{}, fmt.Errorf("reload config %s: %w", w.config.Path, err))
+		} else {
+			fileChanged = w.reload(w.config.Path, data)
+		}
+	}
+	if w.remote == nil {
+		return
+	}
+	data, err := w.remote.fetch(ctx)
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		w.report(InstrumentationConfig{}, fmt.Errorf("reload remote config: %w", err))
+	case data == nil:
+		// IUDEX has no config for the service anymore
+		delete(w.last, remoteConfigSourceName)
+	case w.reload(remoteConfigSourceName, data):
+		return
+	}
+	if last, ok := w.last[remoteConfigSourceName]; ok && fileChanged {
+		w.apply(remoteConfigSourceName, last)
+	}
+}
+
+// reload applies data when it differs from the last config read from source, and reports
+// whether it did
+func (w *configWatcher) reload(source string, data []byte) bool {
+	if last, ok := w.last[source]; ok && bytes.Equal(last, data) {
+		return false
+	}
+	w.last[source] = data
+	w.apply(source, data)
+	return true
+}
+
+// apply parses and applies the config read from source
+func (w *configWatcher) apply(source string, data []byte) {
+	config, err := ParseConfig(data)
+	if err == nil {
+		err = Reload(config)
+	}
+	if err != nil {
+		err = fmt.Errorf("reload config %s: %w", source, err)
+	}
+	w.report(config, err)
+}
+
+func (w *configWatcher) report(config InstrumentationConfig, err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+	if w.config.OnReload != nil {
+		w.config.OnReload(config, err)
+	}
+}
+
+// fetch returns the settings of this service, or nil when IUDEX has none
+func (s *remoteConfigSource) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	query := req.URL.Query()
+	for _, key := range []string{"service.name", "service.instance.id", "env"} {
+		if value, ok := s.resource[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (w *configWatcher) shutdown(ctx context.Context) error {
+	w.once.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
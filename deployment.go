@@ -0,0 +1,37 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/sdk/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// DeploymentEventName is the event.name of deployment markers
+const DeploymentEventName = "iudex.deployment"
+
+// MarkDeployment records that version of the service was deployed, so IUDEX can overlay the
+// deploy on latency and error charts. The marker is a log event named DeploymentEventName with
+// service.version set to version and metadata, such as the deployer or the change URL, nested
+// under event.properties. It bypasses the log level and sampling, and is flushed right away,
+// since deploy scripts often exit right after.
+//
+//	iudex.MarkDeployment(ctx, "v1.4.2", map[string]any{"deployer": "ci", "change": prURL})
+func MarkDeployment(ctx context.Context, version string, metadata map[string]any) error {
+	if version == "" {
+		return errors.New("mark deployment: version is required")
+	}
+
+	record, _ := eventRecord(DeploymentEventName, metadata,
+		otellog.String(string(semconv.ServiceVersionKey), version),
+	)
+	GetLoggerProvider().Logger(tracerName).Emit(withKeptRecords(ctx), record)
+
+	if lp, ok := global.GetLoggerProvider().(*log.LoggerProvider); ok {
+		return lp.ForceFlush(ctx)
+	}
+	return nil
+}
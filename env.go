@@ -0,0 +1,115 @@
+package iudex
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getOTelProtocol maps OTEL_EXPORTER_OTLP_PROTOCOL onto the supported protocols
+func getOTelProtocol() *string {
+	protocol := GetEnv("OTEL_EXPORTER_OTLP_PROTOCOL", nil)
+	if protocol == nil {
+		return nil
+	}
+	if *protocol == ProtocolGRPC {
+		return StringPtr(ProtocolGRPC)
+	}
+	// http/protobuf and http/json
+	return StringPtr(ProtocolHTTP)
+}
+
+// getOTelCompression maps OTEL_EXPORTER_OTLP_COMPRESSION onto the Gzip flag
+func getOTelCompression() *bool {
+	compression := GetEnv("OTEL_EXPORTER_OTLP_COMPRESSION", nil)
+	if compression == nil {
+		return nil
+	}
+	switch *compression {
+	case "gzip":
+		return BoolPtr(true)
+	case "none":
+		return BoolPtr(false)
+	default:
+		return nil
+	}
+}
+
+// getEnvList parses a comma separated environment variable, returning nil when unset
+func getEnvList(key string) *[]string {
+	value := GetEnv(key, nil)
+	if value == nil {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(*value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return &items
+}
+
+// getOTelKeyValues parses a comma separated list of url encoded key=value pairs,
+// the format of OTEL_RESOURCE_ATTRIBUTES and OTEL_EXPORTER_OTLP_HEADERS
+func getOTelKeyValues(key string) *map[string]string {
+	value := GetEnv(key, nil)
+	if value == nil {
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, pair := range strings.Split(*value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		k, errK := url.PathUnescape(strings.TrimSpace(k))
+		v, errV := url.PathUnescape(strings.TrimSpace(v))
+		if errK != nil || errV != nil || k == "" {
+			continue
+		}
+		values[k] = v
+	}
+	return &values
+}
+
+// getEnvBool parses a boolean environment variable, returning nil when unset or invalid
+func getEnvBool(key string) *bool {
+	value := GetEnv(key, nil)
+	if value == nil {
+		return nil
+	}
+	b, err := strconv.ParseBool(*value)
+	if err != nil {
+		return nil
+	}
+	return &b
+}
+
+// getEnvFloat parses a float environment variable, returning nil when unset or invalid
+func getEnvFloat(key string) *float64 {
+	value := GetEnv(key, nil)
+	if value == nil {
+		return nil
+	}
+	f, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// getEnvMillis parses an environment variable holding milliseconds, returning nil when unset or invalid
+func getEnvMillis(key string) *time.Duration {
+	value := GetEnv(key, nil)
+	if value == nil {
+		return nil
+	}
+	ms, err := strconv.Atoi(*value)
+	if err != nil || ms <= 0 {
+		return nil
+	}
+	return DurationPtr(time.Duration(ms) * time.Millisecond)
+}
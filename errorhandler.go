@@ -0,0 +1,152 @@
+package iudex
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// maxTrackedErrors bounds the distinct error messages rate limited at once
+const maxTrackedErrors = 100
+
+// ErrorHandlerConfig controls how errors of the OpenTelemetry SDK, e.g. failed exports, are
+// reported. Zero values log each distinct error with slog.Default at most once a minute, or forward
+// it to the error handler the application set before SetupOTelSDK.
+type ErrorHandlerConfig struct {
+	// Handler receives errors instead of slog.Default, with the number of identical errors
+	// suppressed since the previous report
+	Handler func(err error, repeated int)
+	// Interval reports each distinct error message at most once per interval. It defaults to a
+	// minute, and a negative interval reports every error.
+	Interval time.Duration
+	// Escalate is called once errors have kept occurring for EscalateAfter, without a pause longer
+	// than Interval, e.g. to page someone or fail a readiness check. It is called again only after
+	// errors stop and start over.
+	Escalate      func(err error, since time.Duration)
+	EscalateAfter time.Duration
+}
+
+// errorHandler rate limits errors per message and tracks how long they have kept occurring
+type errorHandler struct {
+	config ErrorHandlerConfig
+	now    func() time.Time
+
+	mu         sync.Mutex
+	errors     map[string]*errorState
+	firstError time.Time
+	lastError  time.Time
+	escalated  bool
+}
+
+type errorState struct {
+	reported   time.Time
+	suppressed int
+}
+
+// defaultOTelErrorHandler is the handler otel uses until one is set, printing every error to stderr
+var defaultOTelErrorHandler = otel.GetErrorHandler()
+
+// installErrorHandler sets the global error handler, keeping one the application set unless config
+// is given, in which case errors are forwarded to it when config has no Handler
+func installErrorHandler(config *ErrorHandlerConfig) {
+	current := otel.GetErrorHandler()
+	_, ours := current.(*errorHandler)
+	appHandler := !ours && current != defaultOTelErrorHandler
+	if config == nil {
+		if appHandler {
+			return
+		}
+		config = &ErrorHandlerConfig{}
+	}
+	errorHandlerConfig := *config
+	if errorHandlerConfig.Handler == nil && appHandler {
+		errorHandlerConfig.Handler = func(err error, _ int) { current.Handle(err) }
+	}
+	otel.SetErrorHandler(newErrorHandler(errorHandlerConfig))
+}
+
+func newErrorHandler(config ErrorHandlerConfig) *errorHandler {
+	if config.Interval == 0 {
+		config.Interval = time.Minute
+	}
+	if config.Handler == nil {
+		config.Handler = logSDKError
+	}
+	return &errorHandler{config: config, now: time.Now, errors: map[string]*errorState{}}
+}
+
+// logSDKError is the default handler
+func logSDKError(err error, repeated int) {
+	attrs := []any{"error", err}
+	if repeated > 0 {
+		attrs = append(attrs, "repeated", repeated)
+	}
+	slog.Default().Warn("iudex: telemetry error", attrs...)
+}
+
+// Handle implements otel.ErrorHandler
+func (h *errorHandler) Handle(err error) {
+	if err == nil {
+		return
+	}
+	report, repeated, since, escalate := h.record(err)
+	if report {
+		h.config.Handler(err, repeated)
+	}
+	if escalate {
+		h.config.Escalate(err, since)
+	}
+}
+
+// record counts err and decides whether to report and escalate it
+func (h *errorHandler) record(err error) (report bool, repeated int, since time.Duration, escalate bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+
+	// A pause longer than the interval ends a streak of errors
+	if h.firstError.IsZero() || now.Sub(h.lastError) > max(h.config.Interval, 0) {
+		h.firstError = now
+		h.escalated = false
+	}
+	h.lastError = now
+	since = now.Sub(h.firstError)
+	if h.config.Escalate != nil && !h.escalated && since >= h.config.EscalateAfter {
+		h.escalated = true
+		escalate = true
+	}
+
+	if h.config.Interval < 0 {
+		return true, 0, since, escalate
+	}
+	key := err.Error()
+	state, ok := h.errors[key]
+	if !ok {
+		if len(h.errors) >= maxTrackedErrors {
+			h.prune(now)
+		}
+		h.errors[key] = &errorState{reported: now}
+		return true, 0, since, escalate
+	}
+	if now.Sub(state.reported) < h.config.Interval {
+		state.suppressed++
+		return false, 0, since, escalate
+	}
+	repeated = state.suppressed
+	state.reported, state.suppressed = now, 0
+	return true, repeated, since, escalate
+}
+
+// prune forgets errors last reported more than an interval ago, or all of them if none are
+func (h *errorHandler) prune(now time.Time) {
+	for key, state := range h.errors {
+		if now.Sub(state.reported) >= h.config.Interval {
+			delete(h.errors, key)
+		}
+	}
+	if len(h.errors) >= maxTrackedErrors {
+		clear(h.errors)
+	}
+}
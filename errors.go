@@ -0,0 +1,125 @@
+package iudex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// ErrorFingerprintKey groups occurrences of the same error raised from the same code path
+const ErrorFingerprintKey = attribute.Key("error.fingerprint")
+
+// ErrorOption configures RecordError
+type ErrorOption func(*errorConfig)
+
+type errorConfig struct {
+	log   bool
+	skip  int
+	attrs []Attribute
+}
+
+// WithErrorLog also emits an error-level log record correlated with the current span
+func WithErrorLog() ErrorOption {
+	return func(c *errorConfig) {
+		c.log = true
+	}
+}
+
+// WithErrorAttributes adds attributes to the recorded error
+func WithErrorAttributes(attrs ...Attribute) ErrorOption {
+	return func(c *errorConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// WithStackSkip skips additional caller frames, for helpers that wrap RecordError
+func WithStackSkip(skip int) ErrorOption {
+	return func(c *errorConfig) {
+		c.skip += skip
+	}
+}
+
+// RecordError attaches err to the span in ctx with its type, a stack trace captured at the call site,
+// and a fingerprint, and marks the span as failed. A nil err is ignored.
+func RecordError(ctx context.Context, err error, opts ...ErrorOption) {
+	if err == nil {
+		return
+	}
+	config := errorConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	errType := fmt.Sprintf("%T", err)
+	frames := captureStack(2 + config.skip)
+	attrs := append([]Attribute{
+		semconv.ExceptionType(errType),
+		semconv.ExceptionStacktrace(formatStack(frames)),
+		ErrorFingerprintKey.String(errorFingerprint(errType, frames)),
+	}, config.attrs...)
+
+	span := oteltrace.SpanFromContext(ctx)
+	span.RecordError(err, oteltrace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, err.Error())
+
+	if config.log {
+		record := otellog.Record{}
+		record.SetTimestamp(time.Now())
+		record.SetSeverity(otellog.SeverityError)
+		record.SetSeverityText("ERROR")
+		record.SetBody(otellog.StringValue(err.Error()))
+		record.AddAttributes(otellog.String(string(semconv.ExceptionMessageKey), err.Error()))
+		for _, attr := range attrs {
+			record.AddAttributes(otellog.KeyValue{Key: string(attr.Key), Value: toLogValue(attr.Value.AsInterface())})
+		}
+		GetLoggerProvider().Logger(tracerName).Emit(ctx, record)
+	}
+}
+
+// captureStack returns the stack frames of the caller, skipping skip frames
+func captureStack(skip int) []runtime.Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	iter := runtime.CallersFrames(pcs[:n])
+
+	frames := make([]runtime.Frame, 0, n)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// formatStack renders frames in the same layout as runtime/debug.Stack
+func formatStack(frames []runtime.Frame) string {
+	var b strings.Builder
+	for _, frame := range frames {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return b.String()
+}
+
+// errorFingerprint hashes the error type and calling functions. Line numbers are left out
+// so the fingerprint stays stable across unrelated code changes.
+func errorFingerprint(errType string, frames []runtime.Frame) string {
+	h := sha256.New()
+	h.Write([]byte(errType))
+	for _, frame := range frames {
+		h.Write([]byte{0})
+		h.Write([]byte(frame.Function))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
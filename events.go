@@ -0,0 +1,72 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Event attributes. Properties are nested under EventPropertiesKey so they never collide with
+// attributes added by processors, such as user.id from baggage.
+const (
+	EventNameKey       = attribute.Key("event.name")
+	EventPropertiesKey = attribute.Key("event.properties")
+)
+
+// TrackEvent records a product or business event, such as "signup.completed" or "order.placed",
+// as a log record with the event.name and event.properties attributes. Events emitted inside a
+// span are also added to the span as a span event, so they show up in the trace.
+//
+//	iudex.TrackEvent(ctx, "order.placed", map[string]any{"order_id": order.ID, "total": order.Total})
+//
+// The user, session, and tenant set with SetUserID, SetSessionID, and SetTenantID are attached
+// like on any other log record.
+func TrackEvent(ctx context.Context, name string, properties map[string]any) {
+	if name == "" {
+		otel.Handle(errors.New("track event: name is required"))
+		return
+	}
+
+	record, keys := eventRecord(name, properties)
+	GetLoggerProvider().Logger(tracerName).Emit(ctx, record)
+
+	if span := oteltrace.SpanFromContext(ctx); span.IsRecording() {
+		// Span attributes cannot be nested, so properties are flattened under the prefix
+		attrs := make([]attribute.KeyValue, 0, 1+len(keys))
+		attrs = append(attrs, EventNameKey.String(name))
+		for _, key := range keys {
+			attrs = append(attrs, Attr(string(EventPropertiesKey)+"."+key, properties[key]))
+		}
+		span.AddEvent(name, oteltrace.WithAttributes(attrs...))
+	}
+}
+
+// eventRecord builds the log record of event name, with attrs and then properties nested under
+// EventPropertiesKey, and returns the sorted keys of properties
+func eventRecord(name string, properties map[string]any, attrs ...otellog.KeyValue) (otellog.Record, []string) {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	props := make([]otellog.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		props = append(props, otellog.KeyValue{Key: key, Value: toLogValue(properties[key])})
+	}
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetSeverityText("INFO")
+	record.SetBody(otellog.StringValue(name))
+	record.AddAttributes(otellog.String(string(EventNameKey), name))
+	record.AddAttributes(attrs...)
+	record.AddAttributes(otellog.Map(string(EventPropertiesKey), props...))
+	return record, keys
+}
@@ -0,0 +1,324 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Supported OTLP transport protocols
+const (
+	ProtocolHTTP = "http"
+	ProtocolGRPC = "grpc"
+)
+
+// endpoint is where the OTLP exporters send telemetry, parsed from BaseURL
+type endpoint struct {
+	host     string
+	path     string
+	insecure bool
+}
+
+// getEndpoint parses BaseURL, which is either a host[:port] or a full http(s) URL whose path
+// prefixes the default OTLP paths. An http:// URL or the Insecure flag disables TLS.
+func getEndpoint(config InstrumentationConfig) (endpoint, error) {
+	baseURL := "api.iudex.ai"
+	if config.BaseURL != nil {
+		baseURL = *config.BaseURL
+	}
+
+	ep := endpoint{host: baseURL}
+	if strings.Contains(baseURL, "://") {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return endpoint{}, fmt.Errorf("invalid base URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return endpoint{}, fmt.Errorf("unsupported base URL scheme %q", u.Scheme)
+		}
+		ep = endpoint{
+			host:     u.Host,
+			path:     strings.TrimSuffix(u.Path, "/"),
+			insecure: u.Scheme == "http",
+		}
+	}
+	if config.Insecure != nil && *config.Insecure {
+		ep.insecure = true
+	}
+	return ep, nil
+}
+
+// ExportDestination is an additional OTLP endpoint that receives the same telemetry as IUDEX,
+// such as an in-cluster OTel Collector or another vendor. Each destination exports through its
+// own processors, so a slow or failing destination does not hold up the others.
+type ExportDestination struct {
+	// Endpoint is a host[:port] or a full http(s) URL, like BaseURL
+	Endpoint string
+	// Protocol is ProtocolHTTP (the default) or ProtocolGRPC
+	Protocol string
+	// Headers are sent with every export request; the IUDEX API key is not
+	Headers  map[string]string
+	Insecure bool
+}
+
+// config derives the exporter configuration of the destination from the IUDEX configuration.
+// Proxy settings are shared, debug output is not.
+func (d ExportDestination) config(base InstrumentationConfig) InstrumentationConfig {
+	config := base
+	config.BaseURL = StringPtr(d.Endpoint)
+	config.Protocol = nil
+	if d.Protocol != "" {
+		config.Protocol = StringPtr(d.Protocol)
+	}
+	config.Insecure = BoolPtr(d.Insecure)
+	config.Debug = BoolPtr(false)
+	return config
+}
+
+// destinationTraceExporters creates a span exporter for every additional destination
+func destinationTraceExporters(ctx context.Context, config InstrumentationConfig) ([]trace.SpanExporter, error) {
+	exporters := make([]trace.SpanExporter, 0, len(config.ExportDestinations))
+	for _, dest := range config.ExportDestinations {
+		exp, err := newTraceExporter(ctx, dest.config(config), &dest.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("export destination %q: %w", dest.Endpoint, err)
+		}
+		exporters = append(exporters, exp)
+	}
+	return exporters, nil
+}
+
+// destinationLogExporters creates a log exporter for every additional destination
+func destinationLogExporters(ctx context.Context, config InstrumentationConfig) ([]log.Exporter, error) {
+	exporters := make([]log.Exporter, 0, len(config.ExportDestinations))
+	for _, dest := range config.ExportDestinations {
+		exp, err := newLogExporter(ctx, dest.config(config), &dest.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("export destination %q: %w", dest.Endpoint, err)
+		}
+		exporters = append(exporters, exp)
+	}
+	return exporters, nil
+}
+
+// destinationMetricExporters creates a metric exporter for every additional destination
+func destinationMetricExporters(ctx context.Context, config InstrumentationConfig) ([]metric.Exporter, error) {
+	exporters := make([]metric.Exporter, 0, len(config.ExportDestinations))
+	for _, dest := range config.ExportDestinations {
+		exp, err := newMetricExporter(ctx, dest.config(config), &dest.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("export destination %q: %w", dest.Endpoint, err)
+		}
+		exporters = append(exporters, exp)
+	}
+	return exporters, nil
+}
+
+// getProxy returns the proxy used by the HTTP exporters, or nil to honor HTTPS_PROXY from the environment
+func getProxy(config InstrumentationConfig) (func(*http.Request) (*url.URL, error), error) {
+	if config.Proxy != nil {
+		return config.Proxy, nil
+	}
+	if config.ProxyURL == nil {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(*config.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+func isDebug(config InstrumentationConfig) bool {
+	return config.Debug != nil && *config.Debug
+}
+
+func isGzip(config InstrumentationConfig) bool {
+	return config.Gzip != nil && *config.Gzip
+}
+
+func getProtocol(config InstrumentationConfig) (string, error) {
+	if config.Protocol == nil {
+		return ProtocolHTTP, nil
+	}
+	switch *config.Protocol {
+	case ProtocolHTTP, ProtocolGRPC:
+		return *config.Protocol, nil
+	default:
+		return "", fmt.Errorf("unsupported protocol %q, expected %q or %q", *config.Protocol, ProtocolHTTP, ProtocolGRPC)
+	}
+}
+
+// newTraceExporter creates an OTLP span exporter for the configured protocol
+func newTraceExporter(ctx context.Context, config InstrumentationConfig, headers *map[string]string) (trace.SpanExporter, error) {
+	if isDebug(config) {
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	}
+
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolGRPC {
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(ep.host),
+			otlptracegrpc.WithHeaders(*headers),
+		}
+		if ep.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if config.TraceRetry != nil {
+			opts = append(opts, otlptracegrpc.WithRetry(config.TraceRetry.traceGRPC()))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(ep.host),
+		otlptracehttp.WithHeaders(*headers),
+	}
+	if ep.path != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(ep.path+"/v1/traces"))
+	}
+	if ep.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if proxy != nil {
+		opts = append(opts, otlptracehttp.WithProxy(proxy))
+	}
+	if isGzip(config) {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if config.TraceRetry != nil {
+		opts = append(opts, otlptracehttp.WithRetry(config.TraceRetry.traceHTTP()))
+	}
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// newLogExporter creates an OTLP log exporter for the configured protocol
+func newLogExporter(ctx context.Context, config InstrumentationConfig, headers *map[string]string) (log.Exporter, error) {
+	if isDebug(config) {
+		return stdoutlog.New(stdoutlog.WithPrettyPrint())
+	}
+
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolGRPC {
+		opts := []otlploggrpc.Option{
+			otlploggrpc.WithEndpoint(ep.host),
+			otlploggrpc.WithHeaders(*headers),
+		}
+		if ep.insecure {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		}
+		if config.LogRetry != nil {
+			opts = append(opts, otlploggrpc.WithRetry(config.LogRetry.logGRPC()))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	}
+
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlploghttp.Option{
+		otlploghttp.WithEndpoint(ep.host),
+		otlploghttp.WithHeaders(*headers),
+	}
+	if ep.path != "" {
+		opts = append(opts, otlploghttp.WithURLPath(ep.path+"/v1/logs"))
+	}
+	if ep.insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	}
+	if proxy != nil {
+		opts = append(opts, otlploghttp.WithProxy(proxy))
+	}
+	if isGzip(config) {
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+	}
+	if config.LogRetry != nil {
+		opts = append(opts, otlploghttp.WithRetry(config.LogRetry.logHTTP()))
+	}
+	return otlploghttp.New(ctx, opts...)
+}
+
+// newMetricExporter creates an OTLP metric exporter for the configured protocol
+func newMetricExporter(ctx context.Context, config InstrumentationConfig, headers *map[string]string) (metric.Exporter, error) {
+	if isDebug(config) {
+		return stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+	}
+
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == ProtocolGRPC {
+		opts := []otlpmetricgrpc.Option{
+			otlpmetricgrpc.WithEndpoint(ep.host),
+			otlpmetricgrpc.WithHeaders(*headers),
+		}
+		if ep.insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(ep.host),
+		otlpmetrichttp.WithHeaders(*headers),
+	}
+	if ep.path != "" {
+		opts = append(opts, otlpmetrichttp.WithURLPath(ep.path+"/v1/metrics"))
+	}
+	if ep.insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	if proxy != nil {
+		opts = append(opts, otlpmetrichttp.WithProxy(proxy))
+	}
+	if isGzip(config) {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	return otlpmetrichttp.New(ctx, opts...)
+}
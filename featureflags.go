@@ -0,0 +1,41 @@
+package iudex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// FeatureFlagEventName is the name of span events recorded for flag evaluations
+const FeatureFlagEventName = "feature_flag"
+
+// FeatureFlagVariantsPrefix prefixes the span attribute holding the variant of each flag evaluated
+// in the span, e.g. feature_flag.variants.new-checkout
+const FeatureFlagVariantsPrefix = "feature_flag.variants."
+
+// RecordFlagEvaluation records that the flag flagKey evaluated to variant, following the
+// OpenFeature semantic conventions, so errors and latency can be correlated with flag rollouts.
+// The span in ctx gets a feature_flag event with feature_flag.key, feature_flag.variant, and
+// feature_flag.provider_name, and a feature_flag.variants.<flagKey> attribute so its spans can be
+// filtered by variant. Attributes such as the evaluation reason are added to the event. Nothing is
+// recorded without a recording span.
+//
+//	iudex.RecordFlagEvaluation(ctx, "new-checkout", "on", "launchdarkly")
+func RecordFlagEvaluation(ctx context.Context, flagKey, variant, provider string, attrs ...Attribute) {
+	span := oteltrace.SpanFromContext(ctx)
+	if flagKey == "" || !span.IsRecording() {
+		return
+	}
+	eventAttrs := []attribute.KeyValue{semconv.FeatureFlagKey(flagKey)}
+	if variant != "" {
+		eventAttrs = append(eventAttrs, semconv.FeatureFlagVariant(variant))
+	}
+	if provider != "" {
+		eventAttrs = append(eventAttrs, semconv.FeatureFlagProviderName(provider))
+	}
+	eventAttrs = append(eventAttrs, attrs...)
+	span.AddEvent(FeatureFlagEventName, oteltrace.WithAttributes(eventAttrs...))
+	span.SetAttributes(attribute.String(FeatureFlagVariantsPrefix+flagKey, variant))
+}
@@ -0,0 +1,140 @@
+package iudex
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// AttributeFilter drops span and log attributes by key. Patterns match whole keys and may use *
+// as a wildcard, e.g. "internal.*". When Allow is set only matching keys are kept, and keys
+// matching Deny are always dropped.
+type AttributeFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+// attributeMatcher is an AttributeFilter with its patterns compiled
+type attributeMatcher struct {
+	allow *regexp.Regexp
+	deny  *regexp.Regexp
+}
+
+func newAttributeMatcher(filter AttributeFilter) *attributeMatcher {
+	return &attributeMatcher{
+		allow: compileKeyPatterns(filter.Allow),
+		deny:  compileKeyPatterns(filter.Deny),
+	}
+}
+
+// compileKeyPatterns joins glob patterns into one anchored regular expression, or nil when there are none
+func compileKeyPatterns(patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	alternatives := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		alternatives[i] = strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`)
+	}
+	return regexp.MustCompile(`^(?:` + strings.Join(alternatives, "|") + `)$`)
+}
+
+// keep reports whether the attribute with key passes the filter
+func (m *attributeMatcher) keep(key string) bool {
+	if m.deny != nil && m.deny.MatchString(key) {
+		return false
+	}
+	return m.allow == nil || m.allow.MatchString(key)
+}
+
+// filterAttributes returns the attrs that pass the filter
+func (m *attributeMatcher) filterAttributes(attrs []attribute.KeyValue) []attribute.KeyValue {
+	filtered := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		if m.keep(string(attr.Key)) {
+			filtered = append(filtered, attr)
+		}
+	}
+	return filtered
+}
+
+// filteringSpanProcessor drops filtered attributes before handing spans to the next processor
+type filteringSpanProcessor struct {
+	trace.SpanProcessor
+	matcher *attributeMatcher
+}
+
+// NewAttributeFilterSpanProcessor wraps next so the spans it exports only carry attributes that pass filter
+func NewAttributeFilterSpanProcessor(next trace.SpanProcessor, filter AttributeFilter) trace.SpanProcessor {
+	return &filteringSpanProcessor{SpanProcessor: next, matcher: newAttributeMatcher(filter)}
+}
+
+func (p *filteringSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(&filteredSpan{ReadOnlySpan: s, matcher: p.matcher})
+}
+
+// filteredSpan overrides the attribute carrying parts of a ReadOnlySpan
+type filteredSpan struct {
+	trace.ReadOnlySpan
+	matcher *attributeMatcher
+}
+
+func (s *filteredSpan) Attributes() []attribute.KeyValue {
+	return s.matcher.filterAttributes(s.ReadOnlySpan.Attributes())
+}
+
+func (s *filteredSpan) Events() []trace.Event {
+	events := s.ReadOnlySpan.Events()
+	filtered := make([]trace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = s.matcher.filterAttributes(event.Attributes)
+		filtered[i] = event
+	}
+	return filtered
+}
+
+func (s *filteredSpan) Links() []trace.Link {
+	links := s.ReadOnlySpan.Links()
+	filtered := make([]trace.Link, len(links))
+	for i, link := range links {
+		link.Attributes = s.matcher.filterAttributes(link.Attributes)
+		filtered[i] = link
+	}
+	return filtered
+}
+
+// filteringLogProcessor drops filtered log attributes in place.
+// It must be registered before the exporting processor.
+type filteringLogProcessor struct {
+	matcher *attributeMatcher
+}
+
+// NewAttributeFilterLogProcessor creates a log processor that drops attributes that do not pass filter
+func NewAttributeFilterLogProcessor(filter AttributeFilter) log.Processor {
+	return &filteringLogProcessor{matcher: newAttributeMatcher(filter)}
+}
+
+func (p *filteringLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		if p.matcher.keep(kv.Key) {
+			attrs = append(attrs, kv)
+		}
+		return true
+	})
+	record.SetAttributes(attrs...)
+	return nil
+}
+
+func (p *filteringLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *filteringLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ForceFlush exports all telemetry buffered by the providers installed by SetupOTelSDK.
+// Call it before the process exits or freezes, e.g. at the end of a Lambda invocation.
+func ForceFlush(ctx context.Context) error {
+	var err error
+	if tp, ok := otel.GetTracerProvider().(*trace.TracerProvider); ok {
+		err = errors.Join(err, tp.ForceFlush(ctx))
+	}
+	if lp, ok := global.GetLoggerProvider().(*log.LoggerProvider); ok {
+		err = errors.Join(err, lp.ForceFlush(ctx))
+	}
+	if mp, ok := otel.GetMeterProvider().(*metric.MeterProvider); ok {
+		err = errors.Join(err, mp.ForceFlush(ctx))
+	}
+	return err
+}
+
+// Flush is ForceFlush under a shorter name. Call it before a CLI, cron job, or test suite exits
+// so batched telemetry is not lost.
+func Flush(ctx context.Context) error {
+	return ForceFlush(ctx)
+}
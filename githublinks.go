@@ -0,0 +1,320 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// CodePermalinkKey is a GitHub permalink to the line an error was raised at or a log record was emitted from
+const CodePermalinkKey = attribute.Key("code.permalink")
+
+// gitCommit returns the configured commit, or an empty string to fall back to the VCS revision
+func gitCommit(config InstrumentationConfig) string {
+	if config.GitCommit == nil {
+		return ""
+	}
+	return *config.GitCommit
+}
+
+// githubLinker turns source locations of the main module into GitHub permalinks
+type githubLinker struct {
+	repoURL    string
+	commit     string
+	modulePath string
+	// root is the directory the main module was built in, learned from frames of its packages
+	root atomic.Pointer[string]
+}
+
+// newGitHubLinker creates a linker for the repository at repoURL, e.g. https://github.com/org/repo,
+// checked out at commit. The commit defaults to the revision stamped by go build. It returns nil
+// when the commit is not known.
+func newGitHubLinker(repoURL, commit string) *githubLinker {
+	l := &githubLinker{repoURL: normalizeRepoURL(repoURL), commit: commit}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		l.modulePath = info.Main.Path
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && l.commit == "" {
+				l.commit = setting.Value
+			}
+		}
+	}
+	if l.repoURL == "" || l.commit == "" {
+		return nil
+	}
+	return l
+}
+
+// normalizeRepoURL turns git remotes like git@github.com:org/repo.git into https://github.com/org/repo
+func normalizeRepoURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(url), "/"), ".git")
+	if rest, ok := strings.CutPrefix(url, "git@"); ok {
+		url = "https://" + strings.Replace(rest, ":", "/", 1)
+	}
+	return url
+}
+
+// permalink links to line of file, which holds function. Files outside the main module are not linked.
+func (l *githubLinker) permalink(function, file string, line int) (string, bool) {
+	rel, ok := l.relativePath(function, file)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s/blob/%s/%s#L%d", l.repoURL, l.commit, rel, line), true
+}
+
+// relativePath returns the path of file within the repository. Files are recognized by their
+// module path when built with -trimpath, by the package of function when it belongs to the main
+// module, or else by the build directory learned from earlier frames, e.g. for package main.
+func (l *githubLinker) relativePath(function, file string) (string, bool) {
+	file = filepath.ToSlash(file)
+	if l.modulePath == "" {
+		return "", false
+	}
+	if rel, ok := strings.CutPrefix(file, l.modulePath+"/"); ok {
+		return rel, true
+	}
+	if pkg := packagePath(function); pkg == l.modulePath || strings.HasPrefix(pkg, l.modulePath+"/") {
+		rel := path.Join(strings.TrimPrefix(pkg, l.modulePath), path.Base(file))
+		rel = strings.TrimPrefix(rel, "/")
+		if root, ok := strings.CutSuffix(file, "/"+rel); ok {
+			l.root.Store(&root)
+			return rel, true
+		}
+	}
+	if root := l.root.Load(); root != nil {
+		if rel, ok := strings.CutPrefix(file, *root+"/"); ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// packagePath returns the import path of the package of a function name like
+// github.com/org/repo/pkg.(*T).Method
+func packagePath(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// rewriteStack replaces the locations of frames in the main module in a stack trace, in the
+// layout of runtime/debug.Stack, with permalinks. It also returns the permalink of the innermost
+// linked frame, if any.
+func (l *githubLinker) rewriteStack(stack string) (string, string) {
+	type frame struct {
+		index    int
+		function string
+		file     string
+		line     int
+	}
+	lines := strings.Split(stack, "\n")
+	var frames []frame
+	for i := 1; i < len(lines); i++ {
+		file, line, ok := parseStackLocation(lines[i])
+		if !ok {
+			continue
+		}
+		function := lines[i-1]
+		// debug.Stack prints call arguments after the function name
+		if strings.HasSuffix(function, ")") {
+			if open := strings.LastIndexByte(function, '('); open > 0 {
+				function = function[:open]
+			}
+		}
+		frames = append(frames, frame{index: i, function: function, file: file, line: line})
+	}
+	// A first pass learns the build directory so frames of package main link too
+	for _, f := range frames {
+		l.relativePath(f.function, f.file)
+	}
+
+	first := ""
+	for _, f := range frames {
+		link, ok := l.permalink(f.function, f.file, f.line)
+		if !ok {
+			continue
+		}
+		if first == "" {
+			first = link
+		}
+		lines[f.index] = "\t" + link
+	}
+	if first == "" {
+		return stack, ""
+	}
+	return strings.Join(lines, "\n"), first
+}
+
+// parseStackLocation parses a stack trace line like "\t/src/app/main.go:42 +0x1d"
+func parseStackLocation(s string) (string, int, bool) {
+	s, ok := strings.CutPrefix(s, "\t")
+	if !ok {
+		return "", 0, false
+	}
+	if i := strings.LastIndex(s, " +0x"); i >= 0 {
+		s = s[:i]
+	}
+	colon := strings.LastIndexByte(s, ':')
+	if colon < 0 {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(s[colon+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return s[:colon], line, true
+}
+
+// githubLinkSpanProcessor links the stack traces of recorded exceptions before handing spans to the next processor
+type githubLinkSpanProcessor struct {
+	trace.SpanProcessor
+	linker *githubLinker
+}
+
+// NewGitHubLinkSpanProcessor wraps next so the stack traces of exceptions recorded on the spans it
+// exports point at the lines of the repository at repoURL, checked out at commit, on GitHub. The
+// exception events and their spans get a code.permalink attribute linking to the innermost frame
+// of the service. next is returned unchanged when commit is empty and the binary has no VCS
+// revision stamped.
+func NewGitHubLinkSpanProcessor(next trace.SpanProcessor, repoURL, commit string) trace.SpanProcessor {
+	linker := newGitHubLinker(repoURL, commit)
+	if linker == nil {
+		return next
+	}
+	return &githubLinkSpanProcessor{SpanProcessor: next, linker: linker}
+}
+
+func (p *githubLinkSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	events := s.Events()
+	var linked []trace.Event
+	first := ""
+	for i, event := range events {
+		if event.Name != semconv.ExceptionEventName {
+			continue
+		}
+		for j, attr := range event.Attributes {
+			if attr.Key != semconv.ExceptionStacktraceKey {
+				continue
+			}
+			stack, link := p.linker.rewriteStack(attr.Value.AsString())
+			if link == "" {
+				continue
+			}
+			if linked == nil {
+				linked = make([]trace.Event, len(events))
+				copy(linked, events)
+			}
+			attrs := make([]attribute.KeyValue, len(event.Attributes), len(event.Attributes)+1)
+			copy(attrs, event.Attributes)
+			attrs[j] = semconv.ExceptionStacktrace(stack)
+			linked[i].Attributes = append(attrs, CodePermalinkKey.String(link))
+			if first == "" {
+				first = link
+			}
+		}
+	}
+	if linked == nil {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	p.SpanProcessor.OnEnd(&linkedSpan{ReadOnlySpan: s, events: linked, permalink: first})
+}
+
+// linkedSpan overrides the events of a ReadOnlySpan with their linked stack traces
+type linkedSpan struct {
+	trace.ReadOnlySpan
+	events    []trace.Event
+	permalink string
+}
+
+func (s *linkedSpan) Attributes() []attribute.KeyValue {
+	return append(s.ReadOnlySpan.Attributes(), CodePermalinkKey.String(s.permalink))
+}
+
+func (s *linkedSpan) Events() []trace.Event {
+	return s.events
+}
+
+// githubLinkLogProcessor links stack traces and caller locations of log records in place.
+// It must be registered before the exporting processor.
+type githubLinkLogProcessor struct {
+	linker *githubLinker
+}
+
+// NewGitHubLinkLogProcessor creates a log processor that rewrites the exception.stacktrace of
+// records like NewGitHubLinkSpanProcessor, and adds a code.permalink attribute to records that
+// carry their caller in code.function, code.filepath, and code.lineno. It returns nil when commit
+// is empty and the binary has no VCS revision stamped.
+func NewGitHubLinkLogProcessor(repoURL, commit string) log.Processor {
+	linker := newGitHubLinker(repoURL, commit)
+	if linker == nil {
+		return nil
+	}
+	return &githubLinkLogProcessor{linker: linker}
+}
+
+func (p *githubLinkLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	var function, file string
+	line := 0
+	stackIndex := -1
+	hasPermalink := false
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen()+1)
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		switch attribute.Key(kv.Key) {
+		case semconv.ExceptionStacktraceKey:
+			stackIndex = len(attrs)
+		case semconv.CodeFunctionKey:
+			function = kv.Value.AsString()
+		case semconv.CodeFilepathKey:
+			file = kv.Value.AsString()
+		case semconv.CodeLineNumberKey:
+			line = int(kv.Value.AsInt64())
+		case CodePermalinkKey:
+			hasPermalink = true
+		}
+		attrs = append(attrs, kv)
+		return true
+	})
+	if hasPermalink {
+		return nil
+	}
+
+	link := ""
+	if stackIndex >= 0 {
+		var stack string
+		stack, link = p.linker.rewriteStack(attrs[stackIndex].Value.AsString())
+		if link != "" {
+			attrs[stackIndex].Value = otellog.StringValue(stack)
+		}
+	}
+	if link == "" && file != "" && line > 0 {
+		link, _ = p.linker.permalink(function, file, line)
+	}
+	if link == "" {
+		return nil
+	}
+	record.SetAttributes(append(attrs, otellog.String(string(CodePermalinkKey), link))...)
+	return nil
+}
+
+func (p *githubLinkLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *githubLinkLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package iudex
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// globalAttributes are added to every span and log record. Writers replace the slice under
+// globalAttributesMu so processors can read it without locking.
+var (
+	globalAttributes   atomic.Pointer[[]Attribute]
+	globalAttributesMu sync.Mutex
+)
+
+// SetGlobalAttribute adds key=value to every span and log record created from now on, replacing
+// any previous value of key. Unlike resource attributes, global attributes can change while the
+// service runs, e.g. a deploy ID or a feature flag cohort. Attributes set on a span or record
+// itself take precedence.
+func SetGlobalAttribute(key string, value any) {
+	SetGlobalAttributes(Attr(key, value))
+}
+
+// SetGlobalAttributes adds attrs to every span and log record created from now on, replacing the
+// previous values of their keys
+func SetGlobalAttributes(attrs ...Attribute) {
+	globalAttributesMu.Lock()
+	defer globalAttributesMu.Unlock()
+	updated := slices.Clone(GlobalAttributes())
+	for _, attr := range attrs {
+		i := slices.IndexFunc(updated, func(kv Attribute) bool { return kv.Key == attr.Key })
+		if i >= 0 {
+			updated[i] = attr
+		} else {
+			updated = append(updated, attr)
+		}
+	}
+	globalAttributes.Store(&updated)
+}
+
+// RemoveGlobalAttribute stops adding key to spans and log records
+func RemoveGlobalAttribute(key string) {
+	globalAttributesMu.Lock()
+	defer globalAttributesMu.Unlock()
+	updated := slices.DeleteFunc(slices.Clone(GlobalAttributes()), func(kv Attribute) bool {
+		return kv.Key == attribute.Key(key)
+	})
+	globalAttributes.Store(&updated)
+}
+
+// GlobalAttributes returns the attributes added to every span and log record. It must not be modified.
+func GlobalAttributes() []Attribute {
+	if attrs := globalAttributes.Load(); attrs != nil {
+		return *attrs
+	}
+	return nil
+}
+
+// globalAttributeSpanProcessor adds the global attributes to spans as they start
+type globalAttributeSpanProcessor struct{}
+
+// NewGlobalAttributeSpanProcessor creates a span processor that sets the global attributes on
+// every span that does not set them itself
+func NewGlobalAttributeSpanProcessor() trace.SpanProcessor {
+	return globalAttributeSpanProcessor{}
+}
+
+func (globalAttributeSpanProcessor) OnStart(_ context.Context, s trace.ReadWriteSpan) {
+	attrs := GlobalAttributes()
+	if len(attrs) == 0 {
+		return
+	}
+	own := s.Attributes()
+	for _, attr := range attrs {
+		if !slices.ContainsFunc(own, func(kv attribute.KeyValue) bool { return kv.Key == attr.Key }) {
+			s.SetAttributes(attr)
+		}
+	}
+}
+
+func (globalAttributeSpanProcessor) OnEnd(trace.ReadOnlySpan) {}
+
+func (globalAttributeSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (globalAttributeSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// globalAttributeLogProcessor adds the global attributes to log records in place.
+// It must be registered before the exporting processor.
+type globalAttributeLogProcessor struct{}
+
+// NewGlobalAttributeLogProcessor creates a log processor that adds the global attributes to
+// every log record that does not set them itself
+func NewGlobalAttributeLogProcessor() log.Processor {
+	return globalAttributeLogProcessor{}
+}
+
+func (globalAttributeLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	attrs := GlobalAttributes()
+	if len(attrs) == 0 {
+		return nil
+	}
+	own := make(map[string]bool, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		own[kv.Key] = true
+		return true
+	})
+	for _, attr := range attrs {
+		if !own[string(attr.Key)] {
+			record.AddAttributes(otellog.KeyValue{Key: string(attr.Key), Value: attributeLogValue(attr.Value)})
+		}
+	}
+	return nil
+}
+
+func (globalAttributeLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (globalAttributeLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,324 @@
+package iudex
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RPCRetryAttemptKey is the retry attempt of an RPC, 0 for the first attempt
+const RPCRetryAttemptKey = attribute.Key("rpc.grpc.retry_attempt")
+
+// Metadata carrying the retry attempt: grpc-go sets grpc-previous-rpc-attempts on transparent and
+// service config retries, and the go-grpc-middleware retry interceptor sets x-retry-attempt
+const (
+	previousAttemptsHeader = "grpc-previous-rpc-attempts"
+	retryAttemptHeader     = "x-retry-attempt"
+)
+
+// DefaultGRPCSkipMethods are the services that are not traced unless WithGRPCSkipMethods is used:
+// health checks and server reflection, which are frequent and rarely interesting
+var DefaultGRPCSkipMethods = []string{
+	"grpc.health.v1.Health",
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.reflection.v1alpha.ServerReflection",
+}
+
+// GRPCOption configures the gRPC interceptors
+type GRPCOption func(*grpcConfig)
+
+type grpcConfig struct {
+	skip map[string]bool
+}
+
+// WithGRPCSkipMethods selects the RPCs that are not traced, replacing DefaultGRPCSkipMethods. Each
+// entry is either a full method such as /pkg.Service/Method or a service such as pkg.Service,
+// which skips all of its methods. Call it without arguments to trace every RPC.
+func WithGRPCSkipMethods(methods ...string) GRPCOption {
+	return func(c *grpcConfig) {
+		c.skip = map[string]bool{}
+		for _, method := range methods {
+			c.skip[strings.Trim(method, "/")] = true
+		}
+	}
+}
+
+func newGRPCConfig(opts []GRPCOption) *grpcConfig {
+	config := &grpcConfig{}
+	WithGRPCSkipMethods(DefaultGRPCSkipMethods...)(config)
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// skipped reports whether fullMethod, or its service, is not traced
+func (c *grpcConfig) skipped(fullMethod string) bool {
+	if len(c.skip) == 0 {
+		return false
+	}
+	name := strings.Trim(fullMethod, "/")
+	if c.skip[name] {
+		return true
+	}
+	service, _, ok := strings.Cut(name, "/")
+	return ok && c.skip[service]
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// UnaryServerInterceptor creates a server span for every unary RPC, except the methods skipped
+// with WithGRPCSkipMethods
+func UnaryServerInterceptor(opts ...GRPCOption) grpc.UnaryServerInterceptor {
+	config := newGRPCConfig(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if config.skipped(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, span := startServerSpan(ctx, info.FullMethod)
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		endRPCSpan(span, oteltrace.SpanKindServer, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor creates a server span for every streaming RPC, except the methods
+// skipped with WithGRPCSkipMethods
+func StreamServerInterceptor(opts ...GRPCOption) grpc.StreamServerInterceptor {
+	config := newGRPCConfig(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if config.skipped(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, span := startServerSpan(ss.Context(), info.FullMethod)
+		defer span.End()
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		endRPCSpan(span, oteltrace.SpanKindServer, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor creates a client span for every unary RPC and injects trace context into the outgoing metadata
+func UnaryClientInterceptor(opts ...GRPCOption) grpc.UnaryClientInterceptor {
+	config := newGRPCConfig(opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if config.skipped(method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, span := startClientSpan(ctx, method)
+		defer span.End()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		endRPCSpan(span, oteltrace.SpanKindClient, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor creates a client span for every streaming RPC and injects trace context into the outgoing metadata.
+// The span ends when the stream returns an error or io.EOF, after the response of an RPC without
+// server streaming, or when the context is done.
+func StreamClientInterceptor(opts ...GRPCOption) grpc.StreamClientInterceptor {
+	config := newGRPCConfig(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if config.skipped(method) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, span := startClientSpan(ctx, method)
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			endRPCSpan(span, oteltrace.SpanKindClient, err)
+			span.End()
+			return nil, err
+		}
+		stream := &clientStream{ClientStream: cs, span: span, desc: desc, done: make(chan struct{})}
+		go func() {
+			select {
+			case <-ctx.Done():
+				stream.end(status.FromContextError(ctx.Err()).Err())
+			case <-stream.done:
+			}
+		}()
+		return stream, nil
+	}
+}
+
+func startServerSpan(ctx context.Context, fullMethod string) (context.Context, oteltrace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+	name, attrs := rpcSpanInfo(fullMethod)
+	if attempt, ok := retryAttempt(md, previousAttemptsHeader, retryAttemptHeader); ok {
+		attrs = append(attrs, RPCRetryAttemptKey.Int(attempt))
+	}
+	return Tracer().Start(ctx, name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+func startClientSpan(ctx context.Context, fullMethod string) (context.Context, oteltrace.Span) {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	name, attrs := rpcSpanInfo(fullMethod)
+	if attempt, found := retryAttempt(md, retryAttemptHeader); found {
+		attrs = append(attrs, RPCRetryAttemptKey.Int(attempt))
+	}
+	ctx, span := Tracer().Start(ctx, name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attrs...),
+	)
+
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md), span
+}
+
+// rpcSpanInfo derives the span name and rpc.* attributes from a full method name like
+// /pkg.Service/Method. Spans are named service/method; malformed names are kept whole.
+func rpcSpanInfo(fullMethod string) (string, []attribute.KeyValue) {
+	name := strings.Trim(fullMethod, "/")
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	if service, method, ok := strings.Cut(name, "/"); ok && service != "" && method != "" {
+		attrs = append(attrs, semconv.RPCService(service), semconv.RPCMethod(method))
+	}
+	if name == "" {
+		name = "grpc"
+	}
+	return name, attrs
+}
+
+// retryAttempt reads the retry attempt from the first of headers present in md
+func retryAttempt(md metadata.MD, headers ...string) (int, bool) {
+	for _, header := range headers {
+		if values := md.Get(header); len(values) > 0 {
+			attempt, err := strconv.Atoi(values[0])
+			return attempt, err == nil
+		}
+	}
+	return 0, false
+}
+
+// endRPCSpan records the gRPC status of err on span. Following the semantic conventions, client
+// spans fail on any status but OK, while server spans fail only on statuses that point at the
+// server rather than the request, such as Internal or Unavailable.
+func endRPCSpan(span oteltrace.Span, kind oteltrace.SpanKind, err error) {
+	s, _ := status.FromError(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	if kind == oteltrace.SpanKindClient || serverErrorCode(s.Code()) {
+		message := s.Message()
+		if message == "" {
+			message = s.Code().String()
+		}
+		span.SetStatus(codes.Error, message)
+	}
+}
+
+func serverErrorCode(code grpccodes.Code) bool {
+	switch code {
+	case grpccodes.Unknown, grpccodes.DeadlineExceeded, grpccodes.Unimplemented,
+		grpccodes.Internal, grpccodes.Unavailable, grpccodes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// serverStream overrides the context of a grpc.ServerStream so handlers see the server span
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream ends its span once the stream is finished
+type clientStream struct {
+	grpc.ClientStream
+	span oteltrace.Span
+	desc *grpc.StreamDesc
+	done chan struct{}
+	once sync.Once
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	case !s.desc.ServerStreams:
+		// Without server streaming the only response finishes the RPC
+		s.end(nil)
+	}
+	return err
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		endRPCSpan(s.span, oteltrace.SpanKindClient, err)
+		s.span.End()
+	})
+}
@@ -0,0 +1,189 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// recordSpans installs a global tracer provider recording every span until the test ends
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(trace.NewTracerProvider(trace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+// waitForEnded waits until the recorder holds n ended spans
+func waitForEnded(t *testing.T, recorder *tracetest.SpanRecorder, n int) []trace.ReadOnlySpan {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		ended := recorder.Ended()
+		if len(ended) >= n || time.Now().After(deadline) {
+			if len(ended) != n {
+				t.Fatalf("got %d ended spans, want %d", len(ended), n)
+			}
+			return ended
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakeClientStream returns the queued RecvMsg errors in order
+type fakeClientStream struct {
+	ctx  context.Context
+	recv []error
+}
+
+func (s *fakeClientStream) Header() (metadata.MD, error) { return nil, nil }
+func (s *fakeClientStream) Trailer() metadata.MD         { return nil }
+func (s *fakeClientStream) CloseSend() error             { return nil }
+func (s *fakeClientStream) Context() context.Context     { return s.ctx }
+func (s *fakeClientStream) SendMsg(any) error            { return nil }
+
+func (s *fakeClientStream) RecvMsg(any) error {
+	err := s.recv[0]
+	s.recv = s.recv[1:]
+	return err
+}
+
+func newClientStream(t *testing.T, ctx context.Context, desc *grpc.StreamDesc, recv ...error) grpc.ClientStream {
+	t.Helper()
+	streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{ctx: ctx, recv: recv}, nil
+	}
+	cs, err := StreamClientInterceptor()(ctx, desc, nil, "/pkg.Service/Method", streamer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cs
+}
+
+func TestStreamClientInterceptorServerStreaming(t *testing.T) {
+	recorder := recordSpans(t)
+	cs := newClientStream(t, context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, nil, io.EOF)
+
+	for i := 0; i < 2; i++ {
+		if err := cs.RecvMsg(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ended := recorder.Ended(); len(ended) != 0 {
+		t.Fatalf("span ended before the stream finished")
+	}
+	if err := cs.RecvMsg(nil); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	span := waitForEnded(t, recorder, 1)[0]
+	if span.Name() != "pkg.Service/Method" {
+		t.Errorf("got name %q", span.Name())
+	}
+	if span.Status().Code != codes.Unset {
+		t.Errorf("got status %v, want unset", span.Status())
+	}
+}
+
+func TestStreamClientInterceptorClientStreaming(t *testing.T) {
+	recorder := recordSpans(t)
+	cs := newClientStream(t, context.Background(), &grpc.StreamDesc{ClientStreams: true}, nil)
+
+	// CloseAndRecv receives the only response, without io.EOF
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatal(err)
+	}
+	waitForEnded(t, recorder, 1)
+}
+
+func TestStreamClientInterceptorError(t *testing.T) {
+	recorder := recordSpans(t)
+	cs := newClientStream(t, context.Background(), &grpc.StreamDesc{ServerStreams: true}, status.Error(grpccodes.Unavailable, "down"))
+
+	if err := cs.RecvMsg(nil); err == nil {
+		t.Fatal("want an error")
+	}
+	span := waitForEnded(t, recorder, 1)[0]
+	if span.Status().Code != codes.Error || span.Status().Description != "down" {
+		t.Errorf("got status %v, want error down", span.Status())
+	}
+}
+
+func TestStreamClientInterceptorContextDone(t *testing.T) {
+	recorder := recordSpans(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	newClientStream(t, ctx, &grpc.StreamDesc{ServerStreams: true})
+
+	cancel()
+	span := waitForEnded(t, recorder, 1)[0]
+	if span.Status().Code != codes.Error || span.Status().Description != context.Canceled.Error() {
+		t.Errorf("got status %v, want error for the cancelled context", span.Status())
+	}
+}
+
+func TestStreamClientInterceptorSkipped(t *testing.T) {
+	recorder := recordSpans(t)
+	streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{ctx: ctx, recv: []error{io.EOF}}, nil
+	}
+	cs, err := StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/grpc.health.v1.Health/Watch", streamer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = cs.RecvMsg(nil)
+	if started := recorder.Started(); len(started) != 0 {
+		t.Errorf("got %d spans for a skipped method", len(started))
+	}
+}
+
+// fakeServerStream is a grpc.ServerStream with only a context
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	recorder := recordSpans(t)
+	interceptor := StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/pkg.Service/Method"}
+	ss := &fakeServerStream{ctx: context.Background()}
+
+	handlerErr := status.Error(grpccodes.Internal, "boom")
+	err := interceptor(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+		if !oteltrace.SpanFromContext(stream.Context()).SpanContext().IsValid() {
+			t.Error("handler context has no span")
+		}
+		return handlerErr
+	})
+	if !errors.Is(err, handlerErr) {
+		t.Fatalf("got %v, want the handler error", err)
+	}
+	span := waitForEnded(t, recorder, 1)[0]
+	if span.Status().Code != codes.Error {
+		t.Errorf("got status %v, want error", span.Status())
+	}
+
+	// Client errors such as InvalidArgument do not fail server spans
+	_ = interceptor(nil, ss, info, func(any, grpc.ServerStream) error {
+		return status.Error(grpccodes.InvalidArgument, "bad request")
+	})
+	span = waitForEnded(t, recorder, 2)[1]
+	if span.Status().Code != codes.Unset {
+		t.Errorf("got status %v, want unset", span.Status())
+	}
+}
@@ -0,0 +1,142 @@
+package iudex
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// HeartbeatEventName is the event.name of heartbeat log records
+const HeartbeatEventName = "iudex.heartbeat"
+
+// HeartbeatUptimeKey is the seconds since the process started, on heartbeat log records
+const HeartbeatUptimeKey = attribute.Key("process.uptime")
+
+// defaultHeartbeatInterval is how often a heartbeat is emitted
+const defaultHeartbeatInterval = time.Minute
+
+// processStart is when the process started, as far as the SDK can tell
+var processStart = time.Now()
+
+// HeartbeatConfig emits a heartbeat while the service runs, so IUDEX notices instances that died
+// even when no traffic flows. Each heartbeat is a log event named HeartbeatEventName carrying the
+// instance ID, the uptime, the version, and the Go version, and the process.uptime metric reports
+// the uptime at every metric export.
+type HeartbeatConfig struct {
+	// Interval is how often the heartbeat event is emitted, 1m by default
+	Interval time.Duration
+}
+
+// heartbeat emits heartbeat events until it is stopped
+type heartbeat struct {
+	interval time.Duration
+	attrs    []otellog.KeyValue
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// setupHeartbeat starts the heartbeat when one is configured and returns a function that stops
+// it, or nil
+func setupHeartbeat(config InstrumentationConfig, res *resource.Resource, meter metric.Meter) (func(context.Context) error, error) {
+	if config.Heartbeat == nil {
+		return nil, nil
+	}
+
+	attrs := heartbeatAttributes(res)
+	// Without an instance ID on the resource, heartbeats of replicas could not be told apart
+	var instanceAttrs []attribute.KeyValue
+	if _, ok := res.Set().Value(semconv.ServiceInstanceIDKey); !ok {
+		id := semconv.ServiceInstanceID(uuid.NewString())
+		instanceAttrs = append(instanceAttrs, id)
+		attrs = append(attrs, otellog.String(string(id.Key), id.Value.AsString()))
+	}
+
+	uptime, err := meter.Float64ObservableGauge("process.uptime",
+		metric.WithDescription("Time since the process started"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(uptime, time.Since(processStart).Seconds(), metric.WithAttributes(instanceAttrs...))
+		return nil
+	}, uptime); err != nil {
+		return nil, err
+	}
+
+	h := &heartbeat{
+		interval: config.Heartbeat.Interval,
+		attrs:    attrs,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if h.interval <= 0 {
+		h.interval = defaultHeartbeatInterval
+	}
+	go h.loop()
+	return h.shutdown, nil
+}
+
+// heartbeatAttributes copies the resource attributes that identify a deployment onto heartbeats,
+// so they can be read without joining on the resource
+func heartbeatAttributes(res *resource.Resource) []otellog.KeyValue {
+	attrs := []otellog.KeyValue{
+		otellog.String(string(EventNameKey), HeartbeatEventName),
+		otellog.String(string(semconv.ProcessRuntimeVersionKey), runtime.Version()),
+	}
+	for _, key := range []attribute.Key{semconv.ServiceInstanceIDKey, semconv.ServiceVersionKey, "git.commit"} {
+		if value, ok := res.Set().Value(key); ok {
+			attrs = append(attrs, otellog.String(string(key), value.Emit()))
+		}
+	}
+	return attrs
+}
+
+func (h *heartbeat) loop() {
+	defer close(h.done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.emit()
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// emit records one heartbeat event, which the log level and sampling never drop, so a missing
+// heartbeat always means a dead instance
+func (h *heartbeat) emit() {
+	uptime := time.Since(processStart)
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetSeverityText("INFO")
+	record.SetBody(otellog.StringValue(HeartbeatEventName))
+	record.AddAttributes(h.attrs...)
+	record.AddAttributes(otellog.Float64(string(HeartbeatUptimeKey), uptime.Seconds()))
+	GetLoggerProvider().Logger(tracerName).Emit(withKeptRecords(context.Background()), record)
+}
+
+func (h *heartbeat) shutdown(ctx context.Context) error {
+	h.once.Do(func() { close(h.stop) })
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package iudex
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// HTTPMiddleware wraps handler so every request creates a server span.
+// Incoming trace context is extracted with the propagator installed by SetupOTelSDK.
+// Spans of requests matched by an http.ServeMux pattern are named after it, e.g. "GET /users/{id}".
+func HTTPMiddleware(handler http.Handler, opts ...otelhttp.Option) http.Handler {
+	opts = append([]otelhttp.Option{
+		otelhttp.WithSpanNameFormatter(httpSpanName),
+	}, opts...)
+	return otelhttp.NewHandler(routeHandler(spanStatusHandler(handler)), "http.server", opts...)
+}
+
+// Route wraps handler so requests it serves are named after route, e.g. "GET /users/{id}", for
+// routers that HTTPMiddleware cannot read the matched pattern from. route takes precedence over
+// the http.ServeMux pattern.
+//
+//	mux.Handle("/users/", iudex.Route("/users/{id}", http.HandlerFunc(getUser)))
+func Route(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Middleware outside of the mux, like the access log, reads the route from r.Pattern
+		r.Pattern = route
+		setRequestRoute(r, route)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// routeHandler names the span of each request after the http.ServeMux pattern that matched it,
+// unless Route named it already. The mux sets r.Pattern on the request it is given, so r itself is
+// passed on.
+func routeHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		if route := muxRoute(r.Pattern); route != "" && !hasRoute(oteltrace.SpanFromContext(r.Context())) {
+			setRequestRoute(r, route)
+		}
+	})
+}
+
+// setRequestRoute names the span of r after route and adds it to the otelhttp request metrics
+func setRequestRoute(r *http.Request, route string) {
+	SetHTTPRoute(oteltrace.SpanFromContext(r.Context()), r.Method, route)
+	if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+		labeler.Add(semconv.HTTPRoute(route))
+	}
+}
+
+// hasRoute reports whether span already has an http.route attribute
+func hasRoute(span oteltrace.Span) bool {
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	if !ok {
+		return false
+	}
+	for _, attr := range ro.Attributes() {
+		if attr.Key == semconv.HTTPRouteKey {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPTransport wraps rt so every outgoing request creates a client span and
+// carries trace context headers. A nil rt wraps http.DefaultTransport.
+func HTTPTransport(rt http.RoundTripper, opts ...otelhttp.Option) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	opts = append([]otelhttp.Option{
+		otelhttp.WithSpanNameFormatter(httpSpanName),
+	}, opts...)
+	return otelhttp.NewTransport(rt, opts...)
+}
+
+// httpSpanName names spans after the request method, keeping span names low cardinality
+func httpSpanName(_ string, r *http.Request) string {
+	return r.Method
+}
+
+// StartHTTPServerSpan starts a server span for r, continuing any trace context in its headers.
+// route is the matched route template such as /users/{id}, or empty when it is not known yet.
+// Framework integrations use it so their spans match HTTPMiddleware.
+func StartHTTPServerSpan(r *http.Request, route string) (context.Context, oteltrace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	attrs := append(HTTPAttrs(r), semconv.ClientAddress(r.RemoteAddr))
+
+	ctx, span := Tracer().Start(ctx, r.Method,
+		oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+		oteltrace.WithAttributes(attrs...),
+	)
+	SetHTTPRoute(span, r.Method, route)
+	return ctx, span
+}
+
+// SetHTTPRoute names span after the matched route template, e.g. "GET /users/{id}".
+// Use it when the route is only known after routing.
+func SetHTTPRoute(span oteltrace.Span, method, route string) {
+	if route == "" {
+		return
+	}
+	span.SetName(method + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route))
+}
+
+// EndHTTPServerSpan records the response status and size on span, and sets its status with the
+// SpanStatusFunc, which marks 5xx responses as errors by default. It does not end the span.
+func EndHTTPServerSpan(span oteltrace.Span, status, size int) {
+	EndHTTPServerSpanError(span, status, size, nil)
+}
+
+// EndHTTPServerSpanError is EndHTTPServerSpan for handlers that return errors. err, which the
+// caller records on the span, is passed to the SpanStatusFunc along with the status.
+func EndHTTPServerSpanError(span oteltrace.Span, status, size int, err error) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if size > 0 {
+		span.SetAttributes(semconv.HTTPResponseBodySize(size))
+	}
+	span.SetStatus(spanStatus(status, err))
+}
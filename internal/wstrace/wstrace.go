@@ -0,0 +1,178 @@
+// Package wstrace traces WebSocket connections for the IUDEX WebSocket instrumentations
+package wstrace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// WebSocket attributes
+const (
+	MessagesSentKey     = attribute.Key("websocket.messages.sent")
+	MessagesReceivedKey = attribute.Key("websocket.messages.received")
+	BytesSentKey        = attribute.Key("websocket.bytes.sent")
+	BytesReceivedKey    = attribute.Key("websocket.bytes.received")
+	MessageTypeKey      = attribute.Key("websocket.message.type")
+	MessageSizeKey      = attribute.Key("websocket.message.size")
+	CloseCodeKey        = attribute.Key("websocket.close.code")
+)
+
+// DefaultHeartbeat is how often a heartbeat event is added to connection spans
+const DefaultHeartbeat = 30 * time.Second
+
+// Options configure connection tracing
+type Options struct {
+	// Heartbeat is how often the message counters are added to the connection span as a
+	// websocket.heartbeat event. Zero or less disables heartbeats.
+	Heartbeat time.Duration
+	// MessageSpans creates a child span for every message sent and received
+	MessageSpans bool
+}
+
+// NewOptions returns the default options with opts applied
+func NewOptions[O ~func(*Options)](opts []O) Options {
+	options := Options{Heartbeat: DefaultHeartbeat}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Conn is the tracing state of one connection
+type Conn struct {
+	ctx     context.Context
+	span    oteltrace.Span
+	options Options
+
+	sent, received           atomic.Int64
+	bytesSent, bytesReceived atomic.Int64
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// Accept starts a server span for a connection upgraded from r. The trace context in the
+// handshake headers is continued unless r is already traced by middleware.
+func Accept(r *http.Request, options Options) *Conn {
+	ctx := r.Context()
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+	}
+	return start(ctx, "websocket "+route(r), oteltrace.SpanKindServer, options,
+		semconv.URLPath(r.URL.Path),
+	)
+}
+
+// Dial starts a client span for a connection dialed to rawURL
+func Dial(ctx context.Context, rawURL string, options Options) *Conn {
+	return start(ctx, "websocket dial", oteltrace.SpanKindClient, options, semconv.URLFull(rawURL))
+}
+
+func start(ctx context.Context, name string, kind oteltrace.SpanKind, options Options, attrs ...attribute.KeyValue) *Conn {
+	attrs = append(attrs, semconv.NetworkProtocolName("websocket"))
+	ctx, span := iudex.Tracer().Start(ctx, name,
+		oteltrace.WithSpanKind(kind),
+		oteltrace.WithAttributes(attrs...),
+	)
+	c := &Conn{ctx: ctx, span: span, options: options, stop: make(chan struct{})}
+	if options.Heartbeat > 0 {
+		go c.heartbeat()
+	}
+	return c
+}
+
+// route names the connection after the http.ServeMux pattern that matched r, or its path
+func route(r *http.Request) string {
+	if r.Pattern == "" {
+		return r.URL.Path
+	}
+	if i := strings.IndexByte(r.Pattern, '/'); i >= 0 {
+		return r.Pattern[i:]
+	}
+	return r.Pattern
+}
+
+// Context returns the context of the connection span
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *Conn) heartbeat() {
+	ticker := time.NewTicker(c.options.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.span.AddEvent("websocket.heartbeat", oteltrace.WithAttributes(c.counters()...))
+		}
+	}
+}
+
+func (c *Conn) counters() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		MessagesSentKey.Int64(c.sent.Load()),
+		MessagesReceivedKey.Int64(c.received.Load()),
+		BytesSentKey.Int64(c.bytesSent.Load()),
+		BytesReceivedKey.Int64(c.bytesReceived.Load()),
+	}
+}
+
+// Sent records a message written to the connection
+func (c *Conn) Sent(messageType string, size int, err error) {
+	if err == nil {
+		c.sent.Add(1)
+		c.bytesSent.Add(int64(size))
+	}
+	c.message("websocket send", messageType, size, err)
+}
+
+// Received records a message read from the connection
+func (c *Conn) Received(messageType string, size int, err error) {
+	if err == nil {
+		c.received.Add(1)
+		c.bytesReceived.Add(int64(size))
+	}
+	c.message("websocket receive", messageType, size, err)
+}
+
+func (c *Conn) message(name, messageType string, size int, err error) {
+	if !c.options.MessageSpans || err != nil {
+		return
+	}
+	_, span := iudex.Tracer().Start(c.ctx, name, oteltrace.WithAttributes(
+		MessageTypeKey.String(messageType),
+		MessageSizeKey.Int(size),
+	))
+	span.End()
+}
+
+// End ends the connection span with the final counters. code is the close code, or 0 when
+// unknown. A non-nil err marks the span as failed.
+func (c *Conn) End(code int, err error) {
+	c.once.Do(func() {
+		close(c.stop)
+		c.span.SetAttributes(c.counters()...)
+		if code != 0 {
+			c.span.SetAttributes(CloseCodeKey.Int(code))
+		}
+		if err != nil {
+			c.span.RecordError(err)
+			c.span.SetStatus(codes.Error, err.Error())
+		}
+		c.span.End()
+	})
+}
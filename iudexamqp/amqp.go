@@ -0,0 +1,131 @@
+// Package iudexamqp traces rabbitmq/amqp091-go publishers and consumers with IUDEX
+package iudexamqp
+
+import (
+	"context"
+
+	"github.com/iudexai/iudex-go"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// defaultExchange names the nameless default exchange in span names and attributes
+const defaultExchange = "amq.default"
+
+// HeaderCarrier adapts AMQP message headers to a propagation.TextMapCarrier
+type HeaderCarrier amqp.Table
+
+func (c HeaderCarrier) Get(key string) string {
+	switch v := c[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Channel wraps an amqp.Channel so publishes create producer spans and carry trace context
+// in the message headers. All other channel methods are passed through.
+type Channel struct {
+	*amqp.Channel
+}
+
+// Wrap wraps ch with tracing
+func Wrap(ch *amqp.Channel) *Channel {
+	return &Channel{Channel: ch}
+}
+
+// PublishWithContext publishes msg inside a producer span, injecting the span's trace context
+// into the message headers so consumers continue the trace
+func (ch *Channel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	destination := exchange
+	if destination == "" {
+		destination = defaultExchange
+	}
+	attrs := []attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingOperationTypePublish,
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingRabbitmqDestinationRoutingKey(key),
+	}
+	if msg.MessageId != "" {
+		attrs = append(attrs, semconv.MessagingMessageID(msg.MessageId))
+	}
+	ctx, span := iudex.Tracer().Start(ctx, "publish "+destination,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	// Copy the headers so a Publishing reused across calls does not keep a stale context
+	headers := make(amqp.Table, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+	msg.Headers = headers
+
+	err := ch.Channel.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// StartProcessSpan starts a consumer span for d that continues the trace of its publisher.
+// The caller must end the returned span once the delivery is processed.
+func StartProcessSpan(ctx context.Context, d amqp.Delivery, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	if d.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(d.Headers))
+	}
+	destination := d.Exchange
+	if destination == "" {
+		destination = defaultExchange
+	}
+	attrs = append([]attribute.KeyValue{
+		semconv.MessagingSystemRabbitmq,
+		semconv.MessagingOperationTypeDeliver,
+		semconv.MessagingDestinationName(destination),
+		semconv.MessagingRabbitmqDestinationRoutingKey(d.RoutingKey),
+		semconv.MessagingRabbitmqMessageDeliveryTag(int(d.DeliveryTag)),
+	}, attrs...)
+	if d.MessageId != "" {
+		attrs = append(attrs, semconv.MessagingMessageID(d.MessageId))
+	}
+	return iudex.Tracer().Start(ctx, "process "+destination,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+// ProcessDelivery runs fn inside a consumer span for d, recording a returned error on the span
+func ProcessDelivery(ctx context.Context, d amqp.Delivery, fn func(context.Context) error) error {
+	ctx, span := StartProcessSpan(ctx, d)
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
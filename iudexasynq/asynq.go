@@ -0,0 +1,154 @@
+// Package iudexasynq traces hibiken/asynq task producers and workers with IUDEX
+package iudexasynq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/hibiken/asynq"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Asynq attributes
+const (
+	RetryCountKey = attribute.Key("messaging.asynq.retry_count")
+	MaxRetryKey   = attribute.Key("messaging.asynq.max_retry")
+	WillRetryKey  = attribute.Key("messaging.asynq.will_retry")
+)
+
+// messagingSystem is the messaging.system of asynq spans
+var messagingSystem = semconv.MessagingSystemKey.String("asynq")
+
+// PayloadTraceKey is the field added to JSON object payloads to carry trace context. asynq tasks
+// have no headers, so the trace context travels in the payload. Handlers that decode the payload
+// into a struct ignore the field.
+const PayloadTraceKey = "_iudex_trace"
+
+// Enqueue creates a task and enqueues it inside a producer span. When payload is a JSON object,
+// the span's trace context is added to it under PayloadTraceKey so the worker continues the
+// trace. Other payloads, and the payloads of tasks enqueued with asynq.Unique or asynq.TaskID,
+// which deduplicate tasks, are enqueued unchanged.
+//
+//	payload, _ := json.Marshal(EmailPayload{UserID: 42})
+//	info, err := iudexasynq.Enqueue(ctx, client, "email:welcome", payload, asynq.Queue("critical"))
+func Enqueue(ctx context.Context, client *asynq.Client, typename string, payload []byte, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	ctx, span := iudex.Tracer().Start(ctx, "publish "+typename,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(messagingSystem, semconv.MessagingOperationTypePublish),
+	)
+	defer span.End()
+
+	if !deduplicated(opts) {
+		payload = injectPayload(ctx, payload)
+	}
+	info, err := client.EnqueueContext(ctx, asynq.NewTask(typename, payload), opts...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(
+		semconv.MessagingDestinationName(info.Queue),
+		semconv.MessagingMessageID(info.ID),
+	)
+	return info, nil
+}
+
+// deduplicated reports whether opts deduplicate the task, by its payload or by its ID. The trace
+// context differs between enqueues of the same task, so it must not be added to the payload.
+func deduplicated(opts []asynq.Option) bool {
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.UniqueOpt, asynq.TaskIDOpt:
+			return true
+		}
+	}
+	return false
+}
+
+// injectPayload adds the trace context of ctx to a JSON object payload, keeping its fields in order
+func injectPayload(ctx context.Context, payload []byte) []byte {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) < 2 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return payload
+	}
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return payload
+	}
+	field, err := json.Marshal(map[string]propagation.MapCarrier{PayloadTraceKey: carrier})
+	if err != nil {
+		return payload
+	}
+
+	rest := bytes.TrimSpace(trimmed[1:])
+	injected := make([]byte, 0, len(trimmed)+len(field))
+	injected = append(injected, field[:len(field)-1]...)
+	if rest[0] != '}' {
+		injected = append(injected, ',')
+	}
+	return append(injected, rest...)
+}
+
+// extractPayload returns ctx with the trace context carried in payload, if any
+func extractPayload(ctx context.Context, payload []byte) context.Context {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !bytes.Contains(trimmed, []byte(PayloadTraceKey)) {
+		return ctx
+	}
+	var fields struct {
+		Trace propagation.MapCarrier `json:"_iudex_trace"`
+	}
+	if json.Unmarshal(trimmed, &fields) != nil || len(fields.Trace) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, fields.Trace)
+}
+
+// Middleware creates a consumer span for every task processed by an asynq server, continuing the
+// trace of the producer when the task was enqueued with Enqueue. Spans record the queue, task
+// ID, retry count, and whether a failed task will be retried.
+//
+//	mux := asynq.NewServeMux()
+//	mux.Use(iudexasynq.Middleware())
+func Middleware() asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			ctx = extractPayload(ctx, t.Payload())
+			attrs := []attribute.KeyValue{messagingSystem, semconv.MessagingOperationTypeDeliver}
+			if queue, ok := asynq.GetQueueName(ctx); ok {
+				attrs = append(attrs, semconv.MessagingDestinationName(queue))
+			}
+			if id, ok := asynq.GetTaskID(ctx); ok {
+				attrs = append(attrs, semconv.MessagingMessageID(id))
+			}
+			retry, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			attrs = append(attrs, RetryCountKey.Int(retry), MaxRetryKey.Int(maxRetry))
+
+			ctx, span := iudex.Tracer().Start(ctx, "process "+t.Type(),
+				oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+				oteltrace.WithAttributes(attrs...),
+			)
+			defer span.End()
+
+			err := next.ProcessTask(ctx, t)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				willRetry := retry < maxRetry && !errors.Is(err, asynq.SkipRetry)
+				span.SetAttributes(WillRetryKey.Bool(willRetry))
+			}
+			return err
+		})
+	}
+}
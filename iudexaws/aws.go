@@ -0,0 +1,98 @@
+// Package iudexaws traces AWS SDK v2 calls with IUDEX
+package iudexaws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// rpcSystemAWS is the rpc.system of AWS API calls
+var rpcSystemAWS = semconv.RPCSystemKey.String("aws-api")
+
+// AppendMiddleware instruments every client created from cfg, such as S3, DynamoDB, SQS, and SNS,
+// so each API call creates a client span. SQS and SNS publishes also carry trace context in their
+// message attributes so consumers continue the trace.
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	iudexaws.AppendMiddleware(&cfg)
+//	client := s3.NewFromConfig(cfg)
+func AppendMiddleware(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, addMiddleware)
+}
+
+func addMiddleware(stack *middleware.Stack) error {
+	// After, so the service and operation metadata is registered
+	if err := stack.Initialize.Add(middleware.InitializeMiddlewareFunc("IudexStartSpan", startSpan), middleware.After); err != nil {
+		return err
+	}
+	return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("IudexResponse", recordResponse), middleware.Before)
+}
+
+func startSpan(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	service := awsmiddleware.GetServiceID(ctx)
+	operation := awsmiddleware.GetOperationName(ctx)
+	attrs := []attribute.KeyValue{
+		rpcSystemAWS,
+		semconv.RPCService(service),
+		semconv.RPCMethod(operation),
+		semconv.CloudProviderAWS,
+		semconv.CloudRegion(awsmiddleware.GetRegion(ctx)),
+	}
+
+	kind := oteltrace.SpanKindClient
+	if isPublish(in.Parameters) {
+		kind = oteltrace.SpanKindProducer
+	}
+	ctx, span := iudex.Tracer().Start(ctx, service+"."+operation,
+		oteltrace.WithSpanKind(kind),
+		oteltrace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	in.Parameters = injectMessageAttributes(ctx, in.Parameters)
+
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		span.SetAttributes(semconv.AWSRequestID(requestID))
+	}
+	if err != nil {
+		var respErr *smithyhttp.ResponseError
+		if errors.As(err, &respErr) {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(respErr.HTTPStatusCode()))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return out, metadata, err
+}
+
+// recordResponse records the HTTP status of successful calls on the span started by startSpan
+func recordResponse(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (middleware.DeserializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleDeserialize(ctx, in)
+	if resp, ok := out.RawResponse.(*smithyhttp.Response); ok {
+		oteltrace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	}
+	return out, metadata, err
+}
+
+// isPublish reports whether params is an SQS or SNS call that sends messages
+func isPublish(params any) bool {
+	switch params.(type) {
+	case *sqs.SendMessageInput, *sqs.SendMessageBatchInput, *sns.PublishInput, *sns.PublishBatchInput:
+		return true
+	default:
+		return false
+	}
+}
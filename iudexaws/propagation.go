@@ -0,0 +1,119 @@
+package iudexaws
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+)
+
+// maxMessageAttributes is the number of message attributes SQS and SNS accept per message
+const maxMessageAttributes = 10
+
+// SQSAttributeCarrier adapts SQS message attributes to a propagation.TextMapCarrier
+type SQSAttributeCarrier map[string]sqstypes.MessageAttributeValue
+
+func (c SQSAttributeCarrier) Get(key string) string {
+	if v, ok := c[key]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+	return ""
+}
+
+func (c SQSAttributeCarrier) Set(key, value string) {
+	c[key] = sqstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (c SQSAttributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// SNSAttributeCarrier adapts SNS message attributes to a propagation.TextMapCarrier
+type SNSAttributeCarrier map[string]snstypes.MessageAttributeValue
+
+func (c SNSAttributeCarrier) Get(key string) string {
+	if v, ok := c[key]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+	return ""
+}
+
+func (c SNSAttributeCarrier) Set(key, value string) {
+	c[key] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func (c SNSAttributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// injectMessageAttributes returns a copy of params whose messages carry the trace context in ctx,
+// leaving the caller's input and attribute maps untouched. Messages without room for the
+// propagation fields under the attribute limit are left unchanged.
+func injectMessageAttributes(ctx context.Context, params any) any {
+	switch in := params.(type) {
+	case *sqs.SendMessageInput:
+		out := *in
+		out.MessageAttributes = injectSQS(ctx, in.MessageAttributes)
+		return &out
+	case *sqs.SendMessageBatchInput:
+		out := *in
+		out.Entries = slices.Clone(in.Entries)
+		for i := range out.Entries {
+			out.Entries[i].MessageAttributes = injectSQS(ctx, in.Entries[i].MessageAttributes)
+		}
+		return &out
+	case *sns.PublishInput:
+		out := *in
+		out.MessageAttributes = injectSNS(ctx, in.MessageAttributes)
+		return &out
+	case *sns.PublishBatchInput:
+		out := *in
+		out.PublishBatchRequestEntries = slices.Clone(in.PublishBatchRequestEntries)
+		for i := range out.PublishBatchRequestEntries {
+			entry := &out.PublishBatchRequestEntries[i]
+			entry.MessageAttributes = injectSNS(ctx, entry.MessageAttributes)
+		}
+		return &out
+	}
+	return params
+}
+
+func injectSQS(ctx context.Context, attrs map[string]sqstypes.MessageAttributeValue) map[string]sqstypes.MessageAttributeValue {
+	propagator := otel.GetTextMapPropagator()
+	if len(attrs)+len(propagator.Fields()) > maxMessageAttributes {
+		return attrs
+	}
+	attrs = maps.Clone(attrs)
+	if attrs == nil {
+		attrs = map[string]sqstypes.MessageAttributeValue{}
+	}
+	propagator.Inject(ctx, SQSAttributeCarrier(attrs))
+	return attrs
+}
+
+func injectSNS(ctx context.Context, attrs map[string]snstypes.MessageAttributeValue) map[string]snstypes.MessageAttributeValue {
+	propagator := otel.GetTextMapPropagator()
+	if len(attrs)+len(propagator.Fields()) > maxMessageAttributes {
+		return attrs
+	}
+	attrs = maps.Clone(attrs)
+	if attrs == nil {
+		attrs = map[string]snstypes.MessageAttributeValue{}
+	}
+	propagator.Inject(ctx, SNSAttributeCarrier(attrs))
+	return attrs
+}
@@ -0,0 +1,122 @@
+package iudexaws
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+var messagingSystemSQS = semconv.MessagingSystemKey.String("aws_sqs")
+
+// ExtractSQSContext returns ctx with the trace context propagated in msg's message attributes.
+// Messages delivered by an SNS subscription without raw message delivery carry the SNS message
+// attributes in their JSON body, which is checked when the SQS attributes have no context.
+// Receive messages with MessageAttributeNames set to []string{"All"} so the attributes are returned.
+func ExtractSQSContext(ctx context.Context, msg sqstypes.Message) context.Context {
+	propagator := otel.GetTextMapPropagator()
+	if extracted := propagator.Extract(ctx, SQSAttributeCarrier(msg.MessageAttributes)); hasRemoteParent(ctx, extracted) {
+		return extracted
+	}
+	if msg.Body != nil {
+		if carrier, ok := snsEnvelopeCarrier(*msg.Body); ok {
+			return propagator.Extract(ctx, carrier)
+		}
+	}
+	return ctx
+}
+
+// StartProcessSpan starts a consumer span for msg as a child of the producer span that sent it.
+// The caller must end the returned span once the message is processed.
+func StartProcessSpan(ctx context.Context, queueURL string, msg sqstypes.Message, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	ctx = ExtractSQSContext(ctx, msg)
+	queue := queueName(queueURL)
+	attrs = append([]attribute.KeyValue{
+		messagingSystemSQS,
+		semconv.MessagingOperationTypeDeliver,
+		semconv.MessagingDestinationName(queue),
+	}, attrs...)
+	if msg.MessageId != nil {
+		attrs = append(attrs, semconv.MessagingMessageID(*msg.MessageId))
+	}
+	return iudex.Tracer().Start(ctx, "process "+queue,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+// ProcessMessage runs fn inside a consumer span for msg, recording a returned error on the span
+func ProcessMessage(ctx context.Context, queueURL string, msg sqstypes.Message, fn func(context.Context) error) error {
+	ctx, span := StartProcessSpan(ctx, queueURL, msg)
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// StartReceiveSpan starts a span for a batch of received messages. A batch has many producers,
+// so instead of a parent the span links to the producer span of every message.
+// The caller must end the returned span once the batch is handled.
+func StartReceiveSpan(ctx context.Context, queueURL string, msgs []sqstypes.Message) (context.Context, oteltrace.Span) {
+	links := make([]oteltrace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		spanContext := oteltrace.SpanContextFromContext(ExtractSQSContext(context.Background(), msg))
+		if spanContext.IsValid() {
+			links = append(links, oteltrace.Link{SpanContext: spanContext})
+		}
+	}
+
+	queue := queueName(queueURL)
+	return iudex.Tracer().Start(ctx, "receive "+queue,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithLinks(links...),
+		oteltrace.WithAttributes(
+			messagingSystemSQS,
+			semconv.MessagingOperationTypeReceive,
+			semconv.MessagingDestinationName(queue),
+			semconv.MessagingBatchMessageCount(len(msgs)),
+		),
+	)
+}
+
+// hasRemoteParent reports whether extraction added a span context that was not already in ctx
+func hasRemoteParent(ctx, extracted context.Context) bool {
+	spanContext := oteltrace.SpanContextFromContext(extracted)
+	return spanContext.IsValid() && !spanContext.Equal(oteltrace.SpanContextFromContext(ctx))
+}
+
+// snsEnvelopeCarrier reads the message attributes of an SNS notification envelope
+func snsEnvelopeCarrier(body string) (propagation.MapCarrier, bool) {
+	var envelope struct {
+		Type              string
+		MessageAttributes map[string]struct {
+			Type  string
+			Value string
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || envelope.Type != "Notification" {
+		return nil, false
+	}
+	carrier := propagation.MapCarrier{}
+	for key, attr := range envelope.MessageAttributes {
+		carrier[key] = attr.Value
+	}
+	return carrier, true
+}
+
+// queueName returns the queue name from a queue URL such as https://sqs.us-east-1.amazonaws.com/123456789012/orders
+func queueName(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
+}
@@ -0,0 +1,39 @@
+// Package iudexchi instruments chi routers with IUDEX tracing
+package iudexchi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/iudexai/iudex-go"
+)
+
+// Middleware creates a server span for every request, named after the chi route pattern
+// such as "GET /users/{id}" rather than the raw path, keeping span names low cardinality.
+// The pattern is only known once chi has routed the request, so the span is renamed after
+// the handler returns.
+//
+//	r := chi.NewRouter()
+//	r.Use(iudexchi.Middleware())
+func Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := iudex.StartHTTPServerSpan(r, "")
+			defer span.End()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			r = r.WithContext(ctx)
+			next.ServeHTTP(ww, r)
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				iudex.SetHTTPRoute(span, r.Method, rctx.RoutePattern())
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			iudex.EndHTTPServerSpan(span, status, ww.BytesWritten())
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package iudexcron traces scheduled jobs run by robfig/cron or a plain ticker with IUDEX
+package iudexcron
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iudexai/iudex-go"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Cron attributes
+const (
+	ScheduleKey   = attribute.Key("cron.schedule")
+	RunKey        = attribute.Key("cron.run")
+	MissedRunsKey = attribute.Key("cron.missed_runs")
+)
+
+// missedRunTolerance is how late a run may start before the scheduled run is counted as missed
+const missedRunTolerance = time.Second
+
+// maxMissedRuns bounds the schedule walk after a long pause, e.g. a suspended laptop
+const maxMissedRuns = 10000
+
+// Option configures a traced job
+type Option func(*runner)
+
+// WithOverlap runs a job even when its previous run is still in progress. By default the new run
+// is skipped. Overlapping and skipped runs are logged either way.
+func WithOverlap() Option {
+	return func(r *runner) {
+		r.overlap = true
+	}
+}
+
+// WithParser parses schedules with parser, to match a cron.Cron created with cron.WithParser
+// or cron.WithSeconds. Schedules are parsed with cron.ParseStandard by default.
+func WithParser(parser cron.ScheduleParser) Option {
+	return func(r *runner) {
+		r.parser = parser
+	}
+}
+
+// runner runs a job function, tracing each run and detecting missed and overlapping runs
+type runner struct {
+	name      string
+	schedule  string
+	fn        func(context.Context) error
+	next      func(time.Time) time.Time
+	tolerance time.Duration
+	overlap   bool
+	parser    cron.ScheduleParser
+	logger    *slog.Logger
+
+	running atomic.Int32
+	runs    atomic.Int64
+
+	mu       sync.Mutex
+	expected time.Time // when the next run is scheduled, zero before the first run
+}
+
+func newRunner(name, schedule string, fn func(context.Context) error, opts []Option) *runner {
+	r := &runner{
+		name:      name,
+		schedule:  schedule,
+		fn:        fn,
+		tolerance: missedRunTolerance,
+		parser:    cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor),
+		logger:    iudex.NewSlogLogger("iudexcron"),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewJob wraps fn in a cron.Job that traces each run as an IUDEX job span with the schedule and
+// run number, records the cron.run.duration histogram, and logs missed, skipped, and overlapping
+// runs. spec must be the schedule the job is added with.
+//
+//	job, err := iudexcron.NewJob("@every 5m", "sync-invoices", syncInvoices)
+//	c.AddJob("@every 5m", job)
+func NewJob(spec, name string, fn func(context.Context) error, opts ...Option) (cron.Job, error) {
+	r := newRunner(name, spec, fn, opts)
+	schedule, err := r.parser.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	r.next = schedule.Next
+	return cron.FuncJob(func() { r.run(context.Background()) }), nil
+}
+
+// AddFunc adds fn to c as a traced job, like c.AddFunc
+//
+//	c := cron.New()
+//	iudexcron.AddFunc(c, "0 3 * * *", "nightly-report", buildReport)
+func AddFunc(c *cron.Cron, spec, name string, fn func(context.Context) error, opts ...Option) (cron.EntryID, error) {
+	job, err := NewJob(spec, name, fn, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return c.AddJob(spec, job)
+}
+
+// RunTicker runs fn every interval until ctx is done, tracing each run like NewJob. Ticks dropped
+// while a slow run is in progress are logged as missed runs.
+//
+//	go iudexcron.RunTicker(ctx, time.Minute, "refresh-cache", refreshCache)
+func RunTicker(ctx context.Context, interval time.Duration, name string, fn func(context.Context) error, opts ...Option) {
+	r := newRunner(name, "@every "+interval.String(), fn, opts)
+	r.next = func(t time.Time) time.Time { return t.Add(interval) }
+	r.tolerance = min(r.tolerance, interval/2)
+	r.expected = time.Now().Add(interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Runs are sequential, so a slow run delays the next tick rather than overlapping it
+			r.run(ctx)
+		}
+	}
+}
+
+// run runs the job once, unless its previous run is still in progress
+func (r *runner) run(ctx context.Context) {
+	now := time.Now()
+	if missed := r.advance(now); missed > 0 {
+		r.logger.WarnContext(ctx, "missed cron runs",
+			string(iudex.JobNameKey), r.name,
+			string(ScheduleKey), r.schedule,
+			string(MissedRunsKey), missed,
+		)
+		iudex.Counter("cron.runs.missed").Add(ctx, float64(missed), iudex.JobNameKey.String(r.name))
+	}
+
+	if r.running.Add(1) > 1 {
+		if !r.overlap {
+			r.running.Add(-1)
+			r.logger.WarnContext(ctx, "skipped cron run, the previous run is still in progress",
+				string(iudex.JobNameKey), r.name,
+				string(ScheduleKey), r.schedule,
+			)
+			iudex.Counter("cron.runs.skipped").Inc(ctx, iudex.JobNameKey.String(r.name))
+			return
+		}
+		r.logger.WarnContext(ctx, "overlapping cron run, the previous run is still in progress",
+			string(iudex.JobNameKey), r.name,
+			string(ScheduleKey), r.schedule,
+		)
+	}
+
+	number := r.runs.Add(1)
+	outcome := iudex.JobOutcomeFailure
+	defer func() {
+		r.running.Add(-1)
+		iudex.Histogram("cron.run.duration", "s").Record(ctx, time.Since(now).Seconds(),
+			iudex.JobNameKey.String(r.name),
+			iudex.JobOutcomeKey.String(outcome),
+		)
+	}()
+
+	err := iudex.RunJob(ctx, r.name, r.fn, iudex.WithJobAttributes(
+		ScheduleKey.String(r.schedule),
+		RunKey.Int64(number),
+	))
+	if err == nil {
+		outcome = iudex.JobOutcomeSuccess
+	}
+}
+
+// advance moves the expected run time past now and returns how many scheduled runs were missed
+// before this one
+func (r *runner) advance(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.expected.IsZero() {
+		r.expected = r.next(now)
+		return 0
+	}
+	// Count the scheduled runs due by now; this run stands in for the last of them
+	due := 0
+	last := now
+	for t := r.expected; !t.After(now.Add(r.tolerance)) && due < maxMissedRuns; t = r.next(t) {
+		due++
+		last = t
+	}
+	r.expected = r.next(last)
+	return max(due-1, 0)
+}
@@ -0,0 +1,69 @@
+package iudexdetectors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// azureMetadataURL is the Azure Instance Metadata Service compute endpoint
+const azureMetadataURL = "http://169.254.169.254/metadata/instance/compute?api-version=2021-02-01&format=json"
+
+// azureTimeout keeps startup fast when the metadata service is unreachable off Azure
+const azureTimeout = 2 * time.Second
+
+type azureDetector struct {
+	client *http.Client
+}
+
+// Azure detects the VM ID, name, size, region, and subscription of an Azure VM
+func Azure() resource.Detector {
+	return &azureDetector{client: &http.Client{Timeout: azureTimeout}}
+}
+
+func (d *azureDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, azureMetadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// Not on Azure
+		return resource.Empty(), nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resource.Empty(), nil
+	}
+
+	var compute struct {
+		VMID           string `json:"vmId"`
+		Name           string `json:"name"`
+		VMSize         string `json:"vmSize"`
+		Location       string `json:"location"`
+		SubscriptionID string `json:"subscriptionId"`
+		ResourceID     string `json:"resourceId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&compute); err != nil {
+		return nil, err
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.CloudProviderAzure,
+		semconv.CloudPlatformAzureVM,
+		semconv.CloudRegion(compute.Location),
+		semconv.CloudAccountID(compute.SubscriptionID),
+		semconv.CloudResourceID(compute.ResourceID),
+		semconv.HostID(compute.VMID),
+		semconv.HostName(compute.Name),
+		semconv.HostType(compute.VMSize),
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
@@ -0,0 +1,36 @@
+// Package iudexdetectors provides cloud resource detectors for WithResourceDetectors.
+// Each detector adds nothing when the process is not running on its platform.
+package iudexdetectors
+
+import (
+	"go.opentelemetry.io/contrib/detectors/aws/ec2"
+	"go.opentelemetry.io/contrib/detectors/aws/ecs"
+	"go.opentelemetry.io/contrib/detectors/aws/eks"
+	"go.opentelemetry.io/contrib/detectors/gcp"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// EC2 detects the instance ID, region, availability zone, and account of an EC2 instance
+func EC2() resource.Detector {
+	return ec2.NewResourceDetector()
+}
+
+// ECS detects the task ARN, cluster, launch type, and container ID of an ECS task
+func ECS() resource.Detector {
+	return ecs.NewResourceDetector()
+}
+
+// EKS detects the cluster name and container ID of a pod running on EKS
+func EKS() resource.Detector {
+	return eks.NewResourceDetector()
+}
+
+// GCP detects the project, region, and platform of GCE, GKE, Cloud Run, and Cloud Functions
+func GCP() resource.Detector {
+	return gcp.NewDetector()
+}
+
+// All returns every cloud and Kubernetes detector with default options
+func All() []resource.Detector {
+	return []resource.Detector{EC2(), ECS(), EKS(), GCP(), Azure(), Kubernetes()}
+}
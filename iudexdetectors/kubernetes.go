@@ -0,0 +1,123 @@
+package iudexdetectors
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// namespaceFile is mounted into every pod that has a service account token
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// KubernetesOption configures the Kubernetes detector
+type KubernetesOption func(*kubernetesDetector)
+
+// WithLabelsFile adds the pod labels in a downward API volume file, such as /etc/podinfo/labels,
+// as k8s.pod.label.<key> attributes
+func WithLabelsFile(path string) KubernetesOption {
+	return func(d *kubernetesDetector) {
+		d.labelsFile = path
+	}
+}
+
+type kubernetesDetector struct {
+	labelsFile string
+}
+
+// Kubernetes detects the pod name, UID, namespace, node, and deployment from environment variables
+// set through the downward API. Each is read from K8S_<NAME> or the common unprefixed name:
+//
+//	K8S_POD_NAME or POD_NAME, falling back to HOSTNAME
+//	K8S_POD_UID or POD_UID
+//	K8S_NAMESPACE_NAME or POD_NAMESPACE, falling back to the service account namespace
+//	K8S_NODE_NAME or NODE_NAME
+//	K8S_DEPLOYMENT_NAME or DEPLOYMENT_NAME
+func Kubernetes(opts ...KubernetesOption) resource.Detector {
+	d := &kubernetesDetector{}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+func (d *kubernetesDetector) Detect(context.Context) (*resource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		// Not running in a pod
+		return resource.Empty(), nil
+	}
+
+	attrs := []attribute.KeyValue{}
+	add := func(attr func(string) attribute.KeyValue, value string) {
+		if value != "" {
+			attrs = append(attrs, attr(value))
+		}
+	}
+
+	add(semconv.K8SPodName, firstEnv("K8S_POD_NAME", "POD_NAME", "HOSTNAME"))
+	add(semconv.K8SPodUID, firstEnv("K8S_POD_UID", "POD_UID"))
+	namespace := firstEnv("K8S_NAMESPACE_NAME", "POD_NAMESPACE")
+	if namespace == "" {
+		if data, err := os.ReadFile(namespaceFile); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		}
+	}
+	add(semconv.K8SNamespaceName, namespace)
+	add(semconv.K8SNodeName, firstEnv("K8S_NODE_NAME", "NODE_NAME"))
+	add(semconv.K8SDeploymentName, firstEnv("K8S_DEPLOYMENT_NAME", "DEPLOYMENT_NAME"))
+
+	if d.labelsFile != "" {
+		labels, err := readLabelsFile(d.labelsFile)
+		if err != nil {
+			return resource.NewWithAttributes(semconv.SchemaURL, attrs...), fmt.Errorf("%w: %w", resource.ErrPartialResource, err)
+		}
+		for key, value := range labels {
+			attrs = append(attrs, attribute.String("k8s.pod.label."+key, value))
+		}
+	}
+
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// firstEnv returns the first non-empty environment variable of keys
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// readLabelsFile parses a downward API labels file, which holds one key="value" pair per line
+func readLabelsFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod labels: %w", err)
+	}
+	defer f.Close()
+
+	labels := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, quoted, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		labels[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pod labels: %w", err)
+	}
+	return labels, nil
+}
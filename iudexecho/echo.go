@@ -0,0 +1,60 @@
+// Package iudexecho instruments echo routers with IUDEX tracing
+package iudexecho
+
+import (
+	"github.com/iudexai/iudex-go"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// RequestIDKey is the span attribute and baggage member carrying the request ID
+const RequestIDKey = attribute.Key("http.request.id")
+
+// Middleware creates a server span for every request, named after the echo route pattern
+// such as "GET /users/:id". Handler errors are recorded on the span and passed to echo's
+// error handler so the span records the status code it responds with.
+//
+// The request ID set by echo's RequestID middleware is added to the span and to the baggage
+// propagated to downstream services, so register that middleware first:
+//
+//	e := echo.New()
+//	e.Use(middleware.RequestID())
+//	e.Use(iudexecho.Middleware())
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx, span := iudex.StartHTTPServerSpan(req, c.Path())
+			defer span.End()
+
+			if id := requestID(c); id != "" {
+				span.SetAttributes(RequestIDKey.String(id))
+				if member, err := baggage.NewMember(string(RequestIDKey), id); err == nil {
+					if bag, err := baggage.FromContext(ctx).SetMember(member); err == nil {
+						ctx = baggage.ContextWithBaggage(ctx, bag)
+					}
+				}
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+				c.Error(err)
+			}
+
+			res := c.Response()
+			iudex.EndHTTPServerSpanError(span, res.Status, int(res.Size), err)
+			return err
+		}
+	}
+}
+
+// requestID returns the ID from the incoming request or the one generated by echo's RequestID middleware
+func requestID(c echo.Context) string {
+	if id := c.Request().Header.Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,96 @@
+// Package iudexfiber instruments fiber apps with IUDEX tracing and request logs
+package iudexfiber
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Config configures the fiber middleware
+type Config struct {
+	// Next skips the middleware for requests where it returns true
+	Next func(c *fiber.Ctx) bool
+
+	// DisableRequestLog stops the middleware from logging a summary of every request
+	DisableRequestLog bool
+}
+
+// New creates a middleware that starts a server span for every request, continuing any trace
+// context in its headers, and logs a request summary through the IUDEX logger. Spans are named
+// after the fiber route such as "GET /users/:id". Handlers should use c.UserContext() to log
+// or start child spans.
+//
+//	app := fiber.New()
+//	app.Use(iudexfiber.New())
+func New(config ...Config) fiber.Handler {
+	cfg := Config{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	logger := iudex.NewSlogLogger("iudexfiber")
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), propagation.HeaderCarrier(c.GetReqHeaders()))
+		ctx, span := iudex.Tracer().Start(ctx, c.Method(),
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLScheme(c.Protocol()),
+				semconv.URLPath(c.Path()),
+				semconv.ServerAddress(c.Hostname()),
+				semconv.ClientAddress(c.IP()),
+				semconv.UserAgentOriginal(c.Get(fiber.HeaderUserAgent)),
+			),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		// Errors are normally handled after the middleware chain returns, so handle them here
+		// to record the status code that is sent
+		err := c.Next()
+		if err != nil {
+			span.RecordError(err)
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		route := c.Route().Path
+		iudex.SetHTTPRoute(span, c.Method(), route)
+		status := c.Response().StatusCode()
+		iudex.EndHTTPServerSpanError(span, status, len(c.Response().Body()), err)
+
+		if !cfg.DisableRequestLog {
+			logRequest(ctx, logger, c.Method(), route, status, time.Since(start))
+		}
+		return nil
+	}
+}
+
+// logRequest logs a one line summary of a request, at error level for 5xx responses
+func logRequest(ctx context.Context, logger *slog.Logger, method, route string, status int, duration time.Duration) {
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, method+" "+route,
+		slog.String(string(semconv.HTTPRequestMethodKey), method),
+		slog.String(string(semconv.HTTPRouteKey), route),
+		slog.Int(string(semconv.HTTPResponseStatusCodeKey), status),
+		slog.Float64("http.server.duration_ms", float64(duration)/float64(time.Millisecond)),
+	)
+}
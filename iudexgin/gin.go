@@ -0,0 +1,33 @@
+// Package iudexgin instruments gin routers with IUDEX tracing
+package iudexgin
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iudexai/iudex-go"
+)
+
+// Middleware creates a server span for every request, named after the gin route template
+// such as "GET /users/:id". Errors added with c.Error are recorded on the span and passed to the
+// iudex.SpanStatusFunc.
+//
+//	r := gin.New()
+//	r.Use(iudexgin.Middleware())
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := iudex.StartHTTPServerSpan(c.Request, c.FullPath())
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		var errs []error
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+			errs = append(errs, err.Err)
+		}
+		err := errors.Join(errs...)
+		iudex.EndHTTPServerSpanError(span, c.Writer.Status(), c.Writer.Size(), err)
+	}
+}
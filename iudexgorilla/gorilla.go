@@ -0,0 +1,145 @@
+// Package iudexgorilla traces gorilla/websocket connections with IUDEX
+package iudexgorilla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/iudexai/iudex-go/internal/wstrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Option configures connection tracing
+type Option func(*wstrace.Options)
+
+// WithHeartbeat sets how often the message counters are added to the connection span as a
+// websocket.heartbeat event, every 30 seconds by default. Zero disables heartbeats.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *wstrace.Options) {
+		o.Heartbeat = interval
+	}
+}
+
+// WithMessageSpans creates a child span of the connection span for every message sent and received
+func WithMessageSpans() Option {
+	return func(o *wstrace.Options) {
+		o.MessageSpans = true
+	}
+}
+
+// Conn wraps a websocket.Conn so the connection is traced by a span that lasts until it closes.
+// ReadMessage, WriteMessage, ReadJSON, and WriteJSON are counted; messages read or written
+// through NextReader and NextWriter are not.
+type Conn struct {
+	*websocket.Conn
+	trace *wstrace.Conn
+}
+
+// Upgrade upgrades r to a WebSocket connection like upgrader.Upgrade, and starts a server span
+// for the connection, named after the route such as "websocket /ws". The span ends when the
+// connection is closed or a read fails.
+//
+//	conn, err := iudexgorilla.Upgrade(&upgrader, w, r, nil)
+//	if err != nil {
+//		return
+//	}
+//	defer conn.Close()
+func Upgrade(upgrader *websocket.Upgrader, w http.ResponseWriter, r *http.Request, responseHeader http.Header, opts ...Option) (*Conn, error) {
+	conn, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, trace: wstrace.Accept(r, wstrace.NewOptions(opts))}, nil
+}
+
+// Dial connects to urlStr like dialer.DialContext inside a client span that lasts until the
+// connection closes. The trace context is sent in the handshake headers, so a server using
+// Upgrade continues the trace.
+func Dial(ctx context.Context, dialer *websocket.Dialer, urlStr string, requestHeader http.Header, opts ...Option) (*Conn, *http.Response, error) {
+	trace := wstrace.Dial(ctx, urlStr, wstrace.NewOptions(opts))
+	header := requestHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(trace.Context(), propagation.HeaderCarrier(header))
+
+	conn, resp, err := dialer.DialContext(ctx, urlStr, header)
+	if err != nil {
+		trace.End(0, err)
+		return nil, resp, err
+	}
+	return &Conn{Conn: conn, trace: trace}, resp, nil
+}
+
+// Context returns a context holding the connection span, for work done on behalf of the connection
+func (c *Conn) Context() context.Context {
+	return c.trace.Context()
+}
+
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType, data, err := c.Conn.ReadMessage()
+	c.trace.Received(typeName(messageType), len(data), err)
+	if err != nil {
+		// The connection cannot be read from again after an error
+		c.endWith(err)
+	}
+	return messageType, data, err
+}
+
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	err := c.Conn.WriteMessage(messageType, data)
+	if messageType == websocket.TextMessage || messageType == websocket.BinaryMessage {
+		c.trace.Sent(typeName(messageType), len(data), err)
+	}
+	return err
+}
+
+// ReadJSON reads the next message and decodes it into v
+func (c *Conn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteJSON encodes v and writes it as a text message
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+// Close closes the connection and ends its span
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	c.trace.End(0, nil)
+	return err
+}
+
+// endWith ends the span after a read error. Normal closures by the peer are not failures.
+func (c *Conn) endWith(err error) {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			err = nil
+		}
+		c.trace.End(closeErr.Code, err)
+		return
+	}
+	c.trace.End(0, err)
+}
+
+func typeName(messageType int) string {
+	if messageType == websocket.BinaryMessage {
+		return "binary"
+	}
+	return "text"
+}
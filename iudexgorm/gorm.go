@@ -0,0 +1,180 @@
+// Package iudexgorm traces GORM queries with IUDEX
+package iudexgorm
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// RowsAffectedKey is the number of rows returned or changed by a statement
+const RowsAffectedKey = attribute.Key("db.rows_affected")
+
+// DefaultSlowQueryThreshold matches the slow query threshold of GORM's default logger
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+const (
+	spanInstanceKey  = "iudex:span"
+	startInstanceKey = "iudex:start"
+)
+
+// Option configures the plugin
+type Option func(*plugin)
+
+// WithSlowQueryThreshold sets how long a statement may run before a slow query warning is logged.
+// Zero or less disables slow query logs.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(p *plugin) {
+		p.slowThreshold = threshold
+	}
+}
+
+// WithoutQueryText leaves the SQL text off spans and slow query logs
+func WithoutQueryText() Option {
+	return func(p *plugin) {
+		p.omitQuery = true
+	}
+}
+
+type plugin struct {
+	slowThreshold time.Duration
+	omitQuery     bool
+	logger        *slog.Logger
+}
+
+// NewPlugin creates a GORM plugin that creates a client span for every statement, recording the
+// table, operation, rows affected, and errors, and logs statements slower than the threshold.
+// Statement values are never recorded, only the SQL with its placeholders.
+//
+//	db.Use(iudexgorm.NewPlugin())
+func NewPlugin(opts ...Option) gorm.Plugin {
+	p := &plugin{
+		slowThreshold: DefaultSlowQueryThreshold,
+		logger:        iudex.NewSlogLogger("iudexgorm"),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *plugin) Name() string {
+	return "iudex"
+}
+
+func (p *plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("iudex:before_create", p.before("create")),
+		cb.Create().After("gorm:create").Register("iudex:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("iudex:before_query", p.before("query")),
+		cb.Query().After("gorm:query").Register("iudex:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("iudex:before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("iudex:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("iudex:before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("iudex:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("iudex:before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("iudex:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("iudex:before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("iudex:after_raw", p.after),
+	)
+}
+
+func (p *plugin) before(callback string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		ctx, span := iudex.Tracer().Start(db.Statement.Context, "gorm."+callback,
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(dbSystem(db.Dialector.Name())),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanInstanceKey, span)
+		db.InstanceSet(startInstanceKey, time.Now())
+	}
+}
+
+func (p *plugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span := v.(oteltrace.Span)
+	defer span.End()
+
+	query := db.Statement.SQL.String()
+	operation := sqlOperation(query)
+	table := db.Statement.Table
+	if operation != "" && table != "" {
+		span.SetName(operation + " " + table)
+	} else if operation != "" {
+		span.SetName(operation)
+	}
+
+	attrs := []attribute.KeyValue{RowsAffectedKey.Int64(db.RowsAffected)}
+	if operation != "" {
+		attrs = append(attrs, semconv.DBOperationName(operation))
+	}
+	if table != "" {
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+	if !p.omitQuery {
+		attrs = append(attrs, semconv.DBQueryText(query))
+	}
+	span.SetAttributes(attrs...)
+
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+
+	start, ok := db.InstanceGet(startInstanceKey)
+	if !ok || p.slowThreshold <= 0 {
+		return
+	}
+	if elapsed := time.Since(start.(time.Time)); elapsed > p.slowThreshold {
+		logAttrs := []any{
+			slog.Float64("db.duration_ms", float64(elapsed)/float64(time.Millisecond)),
+			slog.Int64(string(RowsAffectedKey), db.RowsAffected),
+			slog.String(string(semconv.DBCollectionNameKey), table),
+		}
+		if !p.omitQuery {
+			logAttrs = append(logAttrs, slog.String(string(semconv.DBQueryTextKey), query))
+		}
+		p.logger.WarnContext(db.Statement.Context, "slow query", logAttrs...)
+	}
+}
+
+// sqlOperation returns the leading SQL keyword of query, e.g. SELECT
+func sqlOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// dbSystem maps GORM dialector names to db.system values
+func dbSystem(dialector string) attribute.KeyValue {
+	switch dialector {
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "mysql":
+		return semconv.DBSystemMySQL
+	case "sqlite":
+		return semconv.DBSystemSqlite
+	case "sqlserver":
+		return semconv.DBSystemMSSQL
+	default:
+		return semconv.DBSystemKey.String(dialector)
+	}
+}
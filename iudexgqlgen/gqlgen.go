@@ -0,0 +1,239 @@
+// Package iudexgqlgen traces gqlgen GraphQL servers with IUDEX
+package iudexgqlgen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/iudexai/iudex-go"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// GraphQL attributes, in addition to the semantic convention operation attributes
+const (
+	FieldNameKey    = attribute.Key("graphql.field.name")
+	FieldParentKey  = attribute.Key("graphql.field.parent")
+	FieldPathKey    = attribute.Key("graphql.field.path")
+	ErrorMessageKey = attribute.Key("graphql.error.message")
+	ErrorPathKey    = attribute.Key("graphql.error.path")
+	ErrorCodeKey    = attribute.Key("graphql.error.code")
+	ErrorCountKey   = attribute.Key("graphql.errors")
+)
+
+// Dataloader attributes
+const (
+	LoaderNameKey   = attribute.Key("dataloader.name")
+	BatchSizeKey    = attribute.Key("dataloader.batch_size")
+	LoaderErrorsKey = attribute.Key("dataloader.errors")
+)
+
+// errorEventName names the span events recording GraphQL errors
+const errorEventName = "graphql.error"
+
+// DefaultMaxResolverDepth is how deep in the query resolver spans are created with WithResolverSpans
+const DefaultMaxResolverDepth = 3
+
+// Option configures the tracer
+type Option func(*Tracer)
+
+// WithResolverSpans creates a span for every call of a field resolver up to maxDepth fields deep,
+// where top-level fields are at depth 1. Zero or less uses DefaultMaxResolverDepth. Fields read
+// directly from a struct are never traced.
+func WithResolverSpans(maxDepth int) Option {
+	return func(t *Tracer) {
+		if maxDepth <= 0 {
+			maxDepth = DefaultMaxResolverDepth
+		}
+		t.maxDepth = maxDepth
+	}
+}
+
+// WithoutDocument leaves the query document off operation spans
+func WithoutDocument() Option {
+	return func(t *Tracer) {
+		t.omitDocument = true
+	}
+}
+
+// Tracer is a gqlgen extension that traces GraphQL operations and, optionally, resolvers
+type Tracer struct {
+	maxDepth     int
+	omitDocument bool
+}
+
+var (
+	_ graphql.HandlerExtension     = (*Tracer)(nil)
+	_ graphql.OperationInterceptor = (*Tracer)(nil)
+	_ graphql.FieldInterceptor     = (*Tracer)(nil)
+)
+
+// NewTracer creates a gqlgen extension that creates a span for every operation, named after the
+// operation type and name such as "query GetUser". GraphQL errors in the response are recorded as
+// graphql.error span events with the message and path. Operation spans are children of the
+// request span when the server is wrapped with iudex.HTTPMiddleware.
+//
+//	srv := handler.NewDefaultServer(generated.NewExecutableSchema(cfg))
+//	srv.Use(iudexgqlgen.NewTracer(iudexgqlgen.WithResolverSpans(2)))
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Tracer) ExtensionName() string {
+	return "IudexTracer"
+}
+
+func (t *Tracer) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (t *Tracer) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	oc := graphql.GetOperationContext(ctx)
+	opType := ""
+	opName := oc.OperationName
+	if oc.Operation != nil {
+		opType = string(oc.Operation.Operation)
+		if opName == "" {
+			opName = oc.Operation.Name
+		}
+	}
+	spanName := opType
+	if opName != "" {
+		spanName += " " + opName
+	}
+	if spanName == "" {
+		spanName = "graphql"
+	}
+
+	var attrs []attribute.KeyValue
+	if opType != "" {
+		attrs = append(attrs, semconv.GraphqlOperationTypeKey.String(opType))
+	}
+	if opName != "" {
+		attrs = append(attrs, semconv.GraphqlOperationName(opName))
+	}
+	if !t.omitDocument {
+		attrs = append(attrs, semconv.GraphqlDocument(oc.RawQuery))
+	}
+	ctx, span := iudex.Tracer().Start(ctx, spanName, oteltrace.WithAttributes(attrs...))
+
+	responses := next(ctx)
+	ended := false
+	return func(ctx context.Context) *graphql.Response {
+		resp := responses(ctx)
+		if ended {
+			return resp
+		}
+		if resp == nil {
+			span.End()
+			ended = true
+			return nil
+		}
+		recordErrors(span, resp.Errors)
+		// Subscriptions stream responses until they return nil; other operations respond once
+		if oc.Operation == nil || oc.Operation.Operation != ast.Subscription {
+			span.End()
+			ended = true
+		}
+		return resp
+	}
+}
+
+func (t *Tracer) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if t.maxDepth == 0 || fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+	path := fc.Path()
+	if fieldDepth(path) > t.maxDepth {
+		return next(ctx)
+	}
+
+	ctx, span := iudex.Tracer().Start(ctx, fc.Object+"."+fc.Field.Name,
+		oteltrace.WithAttributes(
+			FieldNameKey.String(fc.Field.Name),
+			FieldParentKey.String(fc.Object),
+			FieldPathKey.String(path.String()),
+		),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	recordErrors(span, graphql.GetFieldErrors(ctx, fc))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
+
+// fieldDepth counts the fields in path, skipping list indexes
+func fieldDepth(path ast.Path) int {
+	depth := 0
+	for _, elem := range path {
+		if _, ok := elem.(ast.PathName); ok {
+			depth++
+		}
+	}
+	return depth
+}
+
+// recordErrors adds an event for each GraphQL error and marks the span as failed
+func recordErrors(span oteltrace.Span, errs gqlerror.List) {
+	if len(errs) == 0 {
+		return
+	}
+	for _, err := range errs {
+		attrs := []attribute.KeyValue{ErrorMessageKey.String(err.Message)}
+		if len(err.Path) > 0 {
+			attrs = append(attrs, ErrorPathKey.String(err.Path.String()))
+		}
+		if code, ok := err.Extensions["code"]; ok {
+			attrs = append(attrs, ErrorCodeKey.String(fmt.Sprint(code)))
+		}
+		span.AddEvent(errorEventName, oteltrace.WithAttributes(attrs...))
+	}
+	span.SetAttributes(ErrorCountKey.Int(len(errs)))
+	span.SetStatus(codes.Error, errs[0].Message)
+}
+
+// WrapBatch traces each call of a dataloader batch function, such as the fetch function of
+// vikstrous/dataloadgen, with the loader name and batch size. Errors returned for individual keys
+// are counted on the span.
+//
+//	loader := dataloadgen.NewLoader(iudexgqlgen.WrapBatch("users", fetchUsers))
+func WrapBatch[K comparable, V any](name string, fetch func(ctx context.Context, keys []K) ([]V, []error)) func(ctx context.Context, keys []K) ([]V, []error) {
+	return func(ctx context.Context, keys []K) ([]V, []error) {
+		ctx, span := iudex.Tracer().Start(ctx, "dataloader "+name,
+			oteltrace.WithAttributes(LoaderNameKey.String(name), BatchSizeKey.Int(len(keys))),
+		)
+		defer span.End()
+
+		values, errs := fetch(ctx, keys)
+		failed := 0
+		var first error
+		for _, err := range errs {
+			if err != nil {
+				if first == nil {
+					first = err
+				}
+				failed++
+			}
+		}
+		if first != nil {
+			span.RecordError(first)
+			span.SetAttributes(LoaderErrorsKey.Int(failed))
+			span.SetStatus(codes.Error, first.Error())
+		}
+		return values, errs
+	}
+}
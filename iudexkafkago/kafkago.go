@@ -0,0 +1,112 @@
+// Package iudexkafkago traces segmentio/kafka-go writers and readers with IUDEX
+package iudexkafkago
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/iudexai/iudex-go"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// MessageCarrier adapts the headers of a kafka-go message to a propagation.TextMapCarrier
+type MessageCarrier struct {
+	msg *kafka.Message
+}
+
+// NewMessageCarrier creates a carrier that reads and writes trace context in msg's headers
+func NewMessageCarrier(msg *kafka.Message) MessageCarrier {
+	return MessageCarrier{msg: msg}
+}
+
+func (c MessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c MessageCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if h.Key == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c MessageCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// WriteMessages writes msgs with w inside a producer span, injecting the span's trace context
+// into every message so consumers continue the trace
+func WriteMessages(ctx context.Context, w *kafka.Writer, msgs ...kafka.Message) error {
+	topic := w.Topic
+	if topic == "" && len(msgs) > 0 {
+		topic = msgs[0].Topic
+	}
+	ctx, span := iudex.Tracer().Start(ctx, "publish "+topic,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(len(msgs)),
+		),
+	)
+	defer span.End()
+
+	for i := range msgs {
+		otel.GetTextMapPropagator().Inject(ctx, NewMessageCarrier(&msgs[i]))
+	}
+
+	err := w.WriteMessages(ctx, msgs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// StartProcessSpan starts a consumer span for msg that continues the trace of its producer.
+// The caller must end the returned span once the message is processed.
+func StartProcessSpan(ctx context.Context, msg kafka.Message, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, NewMessageCarrier(&msg))
+	attrs = append([]attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationTypeDeliver,
+		semconv.MessagingDestinationName(msg.Topic),
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+		semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+	}, attrs...)
+	return iudex.Tracer().Start(ctx, "process "+msg.Topic,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+// ProcessMessage runs fn inside a consumer span for msg, recording a returned error on the span
+func ProcessMessage(ctx context.Context, msg kafka.Message, fn func(context.Context) error) error {
+	ctx, span := StartProcessSpan(ctx, msg)
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+// Package iudexmachinery traces RichardKnop/machinery task producers and workers with IUDEX
+package iudexmachinery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RichardKnop/machinery/v2/backends/result"
+	"github.com/RichardKnop/machinery/v2/tasks"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Machinery attributes
+const (
+	RetriesRemainingKey = attribute.Key("messaging.machinery.retries_remaining")
+	WillRetryKey        = attribute.Key("messaging.machinery.will_retry")
+)
+
+// messagingSystem is the messaging.system of machinery spans
+var messagingSystem = semconv.MessagingSystemKey.String("machinery")
+
+// HeaderCarrier adapts machinery task headers to a propagation.TextMapCarrier
+type HeaderCarrier tasks.Headers
+
+func (c HeaderCarrier) Get(key string) string {
+	value, _ := c[key].(string)
+	return value
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Sender sends tasks, e.g. a *machinery.Server
+type Sender interface {
+	SendTaskWithContext(ctx context.Context, signature *tasks.Signature) (*result.AsyncResult, error)
+}
+
+// SendTask sends signature inside a producer span, injecting the span's trace context into the
+// task headers so the worker continues the trace
+//
+//	asyncResult, err := iudexmachinery.SendTask(ctx, server, &tasks.Signature{Name: "send_email"})
+func SendTask(ctx context.Context, server Sender, signature *tasks.Signature) (*result.AsyncResult, error) {
+	attrs := []attribute.KeyValue{messagingSystem, semconv.MessagingOperationTypePublish}
+	if signature.RoutingKey != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(signature.RoutingKey))
+	}
+	ctx, span := iudex.Tracer().Start(ctx, "publish "+signature.Name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	if signature.Headers == nil {
+		signature.Headers = tasks.Headers{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(signature.Headers))
+
+	asyncResult, err := server.SendTaskWithContext(ctx, signature)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	// The task UUID is generated while sending when it is not set
+	span.SetAttributes(semconv.MessagingMessageID(signature.UUID))
+	return asyncResult, nil
+}
+
+// ProcessTask runs fn inside a consumer span for the task being processed, continuing the trace
+// of the producer when the task was sent with SendTask. Call it at the top of a task function
+// that takes a context, which machinery fills with the task signature:
+//
+//	func sendEmail(ctx context.Context, userID int64) error {
+//		return iudexmachinery.ProcessTask(ctx, func(ctx context.Context) error {
+//			return deliverEmail(ctx, userID)
+//		})
+//	}
+//
+// Spans record the queue, task UUID, the retries remaining, and whether a failed task will be
+// retried.
+func ProcessTask(ctx context.Context, fn func(context.Context) error) error {
+	signature := tasks.SignatureFromContext(ctx)
+	if signature == nil {
+		return fn(ctx)
+	}
+	if signature.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(signature.Headers))
+	}
+	attrs := []attribute.KeyValue{
+		messagingSystem,
+		semconv.MessagingOperationTypeDeliver,
+		semconv.MessagingMessageID(signature.UUID),
+		RetriesRemainingKey.Int(signature.RetryCount),
+	}
+	if signature.RoutingKey != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(signature.RoutingKey))
+	}
+	ctx, span := iudex.Tracer().Start(ctx, "process "+signature.Name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		var retryLater tasks.ErrRetryTaskLater
+		willRetry := signature.RetryCount > 0 || errors.As(err, &retryLater)
+		span.SetAttributes(WillRetryKey.Bool(willRetry))
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+// Package iudexmongo traces MongoDB commands with IUDEX
+package iudexmongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iudexai/iudex-go"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// DurationKey is the command duration in milliseconds as reported by the driver
+const DurationKey = attribute.Key("db.duration_ms")
+
+// spanKey identifies a command in flight; request IDs are only unique per connection
+type spanKey struct {
+	connectionID string
+	requestID    int64
+}
+
+type monitor struct {
+	spans sync.Map
+}
+
+// NewMonitor creates a command monitor that turns every command, such as find, insert, or
+// aggregate, into a span with the database, collection, operation, and duration. Command
+// documents are not recorded since they carry query values.
+//
+//	opts := options.Client().ApplyURI(uri).SetMonitor(iudexmongo.NewMonitor())
+//	client, err := mongo.Connect(ctx, opts)
+func NewMonitor() *event.CommandMonitor {
+	m := &monitor{}
+	return &event.CommandMonitor{
+		Started:   m.started,
+		Succeeded: m.succeeded,
+		Failed:    m.failed,
+	}
+}
+
+func (m *monitor) started(ctx context.Context, e *event.CommandStartedEvent) {
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemMongoDB,
+		semconv.DBNamespace(e.DatabaseName),
+		semconv.DBOperationName(e.CommandName),
+	}
+	name := e.CommandName
+	// The collection is the value of the command name field, e.g. {"find": "users", ...}
+	if collection, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+		attrs = append(attrs, semconv.DBCollectionName(collection))
+		name = e.CommandName + " " + collection
+	}
+
+	_, span := iudex.Tracer().Start(ctx, name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attrs...),
+	)
+	m.spans.Store(spanKey{e.ConnectionID, e.RequestID}, span)
+}
+
+func (m *monitor) succeeded(_ context.Context, e *event.CommandSucceededEvent) {
+	m.finish(e.CommandFinishedEvent, nil)
+}
+
+func (m *monitor) failed(_ context.Context, e *event.CommandFailedEvent) {
+	m.finish(e.CommandFinishedEvent, errors.New(e.Failure))
+}
+
+func (m *monitor) finish(e event.CommandFinishedEvent, err error) {
+	v, ok := m.spans.LoadAndDelete(spanKey{e.ConnectionID, e.RequestID})
+	if !ok {
+		return
+	}
+	span := v.(oteltrace.Span)
+	span.SetAttributes(DurationKey.Float64(float64(e.Duration) / float64(time.Millisecond)))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
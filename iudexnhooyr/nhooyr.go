@@ -0,0 +1,141 @@
+// Package iudexnhooyr traces nhooyr.io/websocket connections with IUDEX
+package iudexnhooyr
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/iudexai/iudex-go/internal/wstrace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"nhooyr.io/websocket"
+)
+
+// Option configures connection tracing
+type Option func(*wstrace.Options)
+
+// WithHeartbeat sets how often the message counters are added to the connection span as a
+// websocket.heartbeat event, every 30 seconds by default. Zero disables heartbeats.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *wstrace.Options) {
+		o.Heartbeat = interval
+	}
+}
+
+// WithMessageSpans creates a child span of the connection span for every message sent and received
+func WithMessageSpans() Option {
+	return func(o *wstrace.Options) {
+		o.MessageSpans = true
+	}
+}
+
+// Conn wraps a websocket.Conn so the connection is traced by a span that lasts until it closes.
+// Read and Write are counted; messages read or written through Reader and Writer are not.
+type Conn struct {
+	*websocket.Conn
+	trace *wstrace.Conn
+}
+
+// Accept accepts a WebSocket handshake from r like websocket.Accept, and starts a server span for
+// the connection, named after the route such as "websocket /ws". The span ends when the
+// connection is closed or a read fails.
+//
+//	conn, err := iudexnhooyr.Accept(w, r, nil)
+//	if err != nil {
+//		return
+//	}
+//	defer conn.CloseNow()
+func Accept(w http.ResponseWriter, r *http.Request, acceptOpts *websocket.AcceptOptions, opts ...Option) (*Conn, error) {
+	conn, err := websocket.Accept(w, r, acceptOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, trace: wstrace.Accept(r, wstrace.NewOptions(opts))}, nil
+}
+
+// Dial connects to u like websocket.Dial inside a client span that lasts until the connection
+// closes. The trace context is sent in the handshake headers, so a server using Accept continues
+// the trace.
+func Dial(ctx context.Context, u string, dialOpts *websocket.DialOptions, opts ...Option) (*Conn, *http.Response, error) {
+	trace := wstrace.Dial(ctx, u, wstrace.NewOptions(opts))
+	dialOptions := websocket.DialOptions{}
+	if dialOpts != nil {
+		dialOptions = *dialOpts
+	}
+	dialOptions.HTTPHeader = dialOptions.HTTPHeader.Clone()
+	if dialOptions.HTTPHeader == nil {
+		dialOptions.HTTPHeader = http.Header{}
+	}
+	otel.GetTextMapPropagator().Inject(trace.Context(), propagation.HeaderCarrier(dialOptions.HTTPHeader))
+
+	conn, resp, err := websocket.Dial(ctx, u, &dialOptions)
+	if err != nil {
+		trace.End(0, err)
+		return nil, resp, err
+	}
+	return &Conn{Conn: conn, trace: trace}, resp, nil
+}
+
+// Context returns a context holding the connection span, for work done on behalf of the connection
+func (c *Conn) Context() context.Context {
+	return c.trace.Context()
+}
+
+func (c *Conn) Read(ctx context.Context) (websocket.MessageType, []byte, error) {
+	typ, data, err := c.Conn.Read(ctx)
+	c.trace.Received(typeName(typ), len(data), err)
+	if err != nil {
+		// The connection is closed after a failed read
+		c.endWith(err)
+	}
+	return typ, data, err
+}
+
+func (c *Conn) Write(ctx context.Context, typ websocket.MessageType, p []byte) error {
+	err := c.Conn.Write(ctx, typ, p)
+	c.trace.Sent(typeName(typ), len(p), err)
+	return err
+}
+
+// Close closes the connection with the status code and reason, and ends its span
+func (c *Conn) Close(code websocket.StatusCode, reason string) error {
+	err := c.Conn.Close(code, reason)
+	var spanErr error
+	if !normalClosure(code) {
+		spanErr = websocket.CloseError{Code: code, Reason: reason}
+	}
+	c.trace.End(int(code), spanErr)
+	return err
+}
+
+// CloseNow closes the connection without a close handshake, and ends its span
+func (c *Conn) CloseNow() error {
+	err := c.Conn.CloseNow()
+	c.trace.End(0, nil)
+	return err
+}
+
+// endWith ends the span after a read error. Normal closures by the peer are not failures.
+func (c *Conn) endWith(err error) {
+	code := websocket.CloseStatus(err)
+	if code == -1 {
+		c.trace.End(0, err)
+		return
+	}
+	if normalClosure(code) {
+		err = nil
+	}
+	c.trace.End(int(code), err)
+}
+
+func normalClosure(code websocket.StatusCode) bool {
+	return code == websocket.StatusNormalClosure || code == websocket.StatusGoingAway || code == websocket.StatusNoStatusRcvd
+}
+
+func typeName(typ websocket.MessageType) string {
+	if typ == websocket.MessageBinary {
+		return "binary"
+	}
+	return "text"
+}
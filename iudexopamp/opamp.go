@@ -0,0 +1,311 @@
+// Package iudexopamp connects services instrumented with IUDEX to an OpAMP server, which rolls out
+// sampling, log level, and redaction changes to the fleet and receives the health of each service
+package iudexopamp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iudexai/iudex-go"
+	"github.com/open-telemetry/opamp-go/client"
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// defaultHealthInterval is how often the health of the export pipeline is reported
+const defaultHealthInterval = 30 * time.Second
+
+// identifyingAttributes tell instances of a service apart, the other resource attributes describe them
+var identifyingAttributes = []attribute.Key{
+	"service.name",
+	"service.namespace",
+	"service.version",
+	"service.instance.id",
+}
+
+// Config describes the OpAMP server and the service reporting to it
+type Config struct {
+	// Endpoint is the URL of the OpAMP server, ws:// or wss:// for WebSocket and http:// or https://
+	// for plain HTTP, e.g. wss://opamp.example.com/v1/opamp
+	Endpoint string
+	// Headers are sent with every request, e.g. for authorization
+	Headers http.Header
+	// Resource describes the service to the server, the resource of the environment defaults when nil
+	Resource *resource.Resource
+	// HealthInterval is how often health is reported, 30s by default
+	HealthInterval time.Duration
+}
+
+// agent reports to the OpAMP server and applies the remote configs it offers
+type agent struct {
+	client client.OpAMPClient
+	start  time.Time
+
+	mu            sync.Mutex
+	appliedHash   []byte
+	appliedConfig *protobufs.AgentConfigMap
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Start connects to the OpAMP server and returns a function that disconnects. Remote configs are
+// applied with iudex.Reload, in either config file format, so they can change the sampler, the
+// log level, and the redaction rules. Health is derived from iudex.Stats: a signal is unhealthy
+// while all its exports fail.
+//
+//	stop, err := iudexopamp.Start(ctx, iudexopamp.Config{Endpoint: "wss://opamp.example.com/v1/opamp"})
+func Start(ctx context.Context, config Config) (func(context.Context) error, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("opamp endpoint: %w", err)
+	}
+	a := &agent{
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+		a.client = client.NewWebSocket(logger{})
+	case "http", "https":
+		a.client = client.NewHTTP(logger{})
+	default:
+		return nil, fmt.Errorf("opamp endpoint: unsupported scheme %q", u.Scheme)
+	}
+
+	res := config.Resource
+	if res == nil {
+		if res, err = iudex.NewResource(ctx, iudex.GetDefaultConfig()); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.client.SetAgentDescription(agentDescription(res)); err != nil {
+		return nil, err
+	}
+	if err := a.client.SetHealth(a.health(iudex.Stats(), iudex.TelemetryStats{})); err != nil {
+		return nil, err
+	}
+
+	err = a.client.Start(ctx, types.StartSettings{
+		OpAMPServerURL: config.Endpoint,
+		Header:         config.Headers,
+		InstanceUid:    instanceUID(res),
+		Capabilities: protobufs.AgentCapabilities_AgentCapabilities_ReportsStatus |
+			protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig |
+			protobufs.AgentCapabilities_AgentCapabilities_ReportsRemoteConfig |
+			protobufs.AgentCapabilities_AgentCapabilities_ReportsEffectiveConfig |
+			protobufs.AgentCapabilities_AgentCapabilities_ReportsHealth,
+		Callbacks: types.CallbacksStruct{
+			OnMessageFunc: a.onMessage,
+			OnConnectFailedFunc: func(_ context.Context, err error) {
+				otel.Handle(fmt.Errorf("opamp: %w", err))
+			},
+			OnErrorFunc: func(_ context.Context, err *protobufs.ServerErrorResponse) {
+				otel.Handle(fmt.Errorf("opamp: %s", err.GetErrorMessage()))
+			},
+			GetEffectiveConfigFunc: a.effectiveConfig,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	interval := config.HealthInterval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	go a.reportHealth(interval)
+	return a.shutdown, nil
+}
+
+// agentDescription splits the resource attributes into identifying and describing ones
+func agentDescription(res *resource.Resource) *protobufs.AgentDescription {
+	description := &protobufs.AgentDescription{}
+	for _, attr := range res.Attributes() {
+		kv := &protobufs.KeyValue{
+			Key:   string(attr.Key),
+			Value: &protobufs.AnyValue{Value: &protobufs.AnyValue_StringValue{StringValue: attr.Value.Emit()}},
+		}
+		if slices.Contains(identifyingAttributes, attr.Key) {
+			description.IdentifyingAttributes = append(description.IdentifyingAttributes, kv)
+		} else {
+			description.NonIdentifyingAttributes = append(description.NonIdentifyingAttributes, kv)
+		}
+	}
+	return description
+}
+
+// instanceUID uses service.instance.id when it is a UUID, and a new UUID otherwise
+func instanceUID(res *resource.Resource) types.InstanceUid {
+	if value, ok := res.Set().Value("service.instance.id"); ok {
+		if id, err := uuid.Parse(value.Emit()); err == nil {
+			return types.InstanceUid(id)
+		}
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		id = uuid.New()
+	}
+	return types.InstanceUid(id)
+}
+
+// onMessage applies a remote config offered by the server and reports whether it was applied
+func (a *agent) onMessage(ctx context.Context, msg *types.MessageData) {
+	remote := msg.RemoteConfig
+	if remote == nil {
+		return
+	}
+	a.mu.Lock()
+	if slices.Equal(remote.GetConfigHash(), a.appliedHash) {
+		a.mu.Unlock()
+		return
+	}
+	a.mu.Unlock()
+
+	status := &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: remote.GetConfigHash(),
+		Status:               protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED,
+	}
+	if err := apply(remote.GetConfig()); err != nil {
+		otel.Handle(fmt.Errorf("opamp: %w", err))
+		status.Status = protobufs.RemoteConfigStatuses_RemoteConfigStatuses_FAILED
+		status.ErrorMessage = err.Error()
+	} else {
+		a.mu.Lock()
+		a.appliedHash, a.appliedConfig = remote.GetConfigHash(), remote.GetConfig()
+		a.mu.Unlock()
+	}
+	if err := a.client.SetRemoteConfigStatus(status); err != nil {
+		otel.Handle(fmt.Errorf("opamp: %w", err))
+	}
+	if status.Status == protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED {
+		if err := a.client.UpdateEffectiveConfig(ctx); err != nil {
+			otel.Handle(fmt.Errorf("opamp: %w", err))
+		}
+	}
+}
+
+// apply parses every file of the config map, in the order of their names, and reloads them
+// together. Nothing is reloaded when a file is invalid.
+func apply(configMap *protobufs.AgentConfigMap) error {
+	files := configMap.GetConfigMap()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var configs []iudex.InstrumentationConfig
+	for _, name := range names {
+		file := files[name]
+		switch file.GetContentType() {
+		case "", "application/yaml", "application/x-yaml", "text/yaml", "application/json":
+		default:
+			return fmt.Errorf("config %q: unsupported content type %q", name, file.GetContentType())
+		}
+		config, err := iudex.ParseConfig(file.GetBody())
+		if err != nil {
+			return fmt.Errorf("config %q: %w", name, err)
+		}
+		configs = append(configs, config)
+	}
+	var err error
+	for _, config := range configs {
+		err = errors.Join(err, iudex.Reload(config))
+	}
+	return err
+}
+
+// effectiveConfig reports the last remote config applied
+func (a *agent) effectiveConfig(context.Context) (*protobufs.EffectiveConfig, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	configMap := a.appliedConfig
+	if configMap == nil {
+		configMap = &protobufs.AgentConfigMap{ConfigMap: map[string]*protobufs.AgentConfigFile{}}
+	}
+	return &protobufs.EffectiveConfig{ConfigMap: configMap}, nil
+}
+
+// reportHealth reports the health of the export pipeline every interval
+func (a *agent) reportHealth(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := iudex.Stats()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+		stats := iudex.Stats()
+		if err := a.client.SetHealth(a.health(stats, last)); err != nil {
+			otel.Handle(fmt.Errorf("opamp: %w", err))
+		}
+		last = stats
+	}
+}
+
+// health compares the export counters with those of the last report
+func (a *agent) health(stats, last iudex.TelemetryStats) *protobufs.ComponentHealth {
+	now := uint64(time.Now().UnixNano())
+	signal := func(exports, failed int64) *protobufs.ComponentHealth {
+		health := &protobufs.ComponentHealth{
+			Healthy:            failed == 0 || failed < exports,
+			StartTimeUnixNano:  uint64(a.start.UnixNano()),
+			Status:             "exporting",
+			StatusTimeUnixNano: now,
+		}
+		if !health.Healthy {
+			health.Status = "failing"
+			health.LastError = fmt.Sprintf("all %d exports failed", exports)
+		}
+		return health
+	}
+	traces := signal(stats.SpanExports-last.SpanExports, stats.FailedSpanExports-last.FailedSpanExports)
+	logs := signal(stats.LogExports-last.LogExports, stats.FailedLogExports-last.FailedLogExports)
+	health := &protobufs.ComponentHealth{
+		Healthy:            traces.Healthy && logs.Healthy,
+		StartTimeUnixNano:  uint64(a.start.UnixNano()),
+		Status:             "running",
+		StatusTimeUnixNano: now,
+		ComponentHealthMap: map[string]*protobufs.ComponentHealth{"traces": traces, "logs": logs},
+	}
+	if !health.Healthy {
+		health.Status = "degraded"
+		health.LastError = "exports are failing"
+	}
+	return health
+}
+
+func (a *agent) shutdown(ctx context.Context) error {
+	a.once.Do(func() { close(a.stop) })
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return a.client.Stop(ctx)
+}
+
+// logger reports errors of the OpAMP client through the OpenTelemetry error handler
+type logger struct{}
+
+func (logger) Debugf(context.Context, string, ...any) {}
+
+func (logger) Errorf(_ context.Context, format string, v ...any) {
+	otel.Handle(fmt.Errorf("opamp: "+format, v...))
+}
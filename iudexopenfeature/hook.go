@@ -0,0 +1,82 @@
+// Package iudexopenfeature records OpenFeature flag evaluations on IUDEX spans
+package iudexopenfeature
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iudexai/iudex-go"
+	"github.com/open-feature/go-sdk/openfeature"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Attributes added to feature_flag span events
+const (
+	ContextIDKey    = attribute.Key("feature_flag.context.id")
+	ReasonKey       = attribute.Key("feature_flag.evaluation.reason")
+	ErrorMessageKey = attribute.Key("feature_flag.evaluation.error.message")
+)
+
+// Option configures the hook
+type Option func(*hook)
+
+// WithoutTargetingKey leaves out the targeting key of the evaluation context, e.g. when it is a
+// user ID that must not leave the service
+func WithoutTargetingKey() Option {
+	return func(h *hook) {
+		h.targetingKey = false
+	}
+}
+
+type hook struct {
+	openfeature.UnimplementedHook
+	targetingKey bool
+}
+
+// NewHook creates an OpenFeature hook that records every flag evaluation on the span in the
+// evaluation context with iudex.RecordFlagEvaluation, along with the reason and the targeting
+// key. Failed evaluations are recorded with the default value as the variant and the error.
+//
+//	openfeature.AddHooks(iudexopenfeature.NewHook())
+//	client := openfeature.NewClient("checkout")
+//	enabled, _ := client.BooleanValue(ctx, "new-checkout", false, openfeature.TransactionContext(ctx))
+func NewHook(opts ...Option) openfeature.Hook {
+	h := &hook{targetingKey: true}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *hook) After(ctx context.Context, hookContext openfeature.HookContext, details openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) error {
+	variant := details.Variant
+	if variant == "" {
+		// Providers without named variants report the value itself
+		variant = fmt.Sprint(details.Value)
+	}
+	attrs := h.attributes(hookContext)
+	if details.Reason != "" {
+		attrs = append(attrs, ReasonKey.String(string(details.Reason)))
+	}
+	iudex.RecordFlagEvaluation(ctx, hookContext.FlagKey(), variant, hookContext.ProviderMetadata().Name, attrs...)
+	return nil
+}
+
+func (h *hook) Error(ctx context.Context, hookContext openfeature.HookContext, err error, _ openfeature.HookHints) {
+	attrs := append(h.attributes(hookContext),
+		ReasonKey.String(string(openfeature.ErrorReason)),
+		ErrorMessageKey.String(err.Error()),
+	)
+	iudex.RecordFlagEvaluation(ctx, hookContext.FlagKey(), fmt.Sprint(hookContext.DefaultValue()), hookContext.ProviderMetadata().Name, attrs...)
+}
+
+// attributes records the targeting key of the evaluation context, unless it is left out
+func (h *hook) attributes(hookContext openfeature.HookContext) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if h.targetingKey {
+		if key := hookContext.EvaluationContext().TargetingKey(); key != "" {
+			attrs = append(attrs, ContextIDKey.String(key))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,231 @@
+// Package iudexpgx traces pgx v5 connections and pools with IUDEX
+package iudexpgx
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/iudexai/iudex-go"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes recorded by the tracer
+const (
+	RowsAffectedKey    = attribute.Key("db.rows_affected")
+	BatchSizeKey       = attribute.Key("db.batch.size")
+	PoolTotalConnsKey  = attribute.Key("db.client.connections.total")
+	PoolIdleConnsKey   = attribute.Key("db.client.connections.idle")
+	PoolUsedConnsKey   = attribute.Key("db.client.connections.used")
+	PoolMaxConnsKey    = attribute.Key("db.client.connections.max")
+	PreparedStmtKey    = attribute.Key("db.statement.name")
+	AlreadyPreparedKey = attribute.Key("db.statement.already_prepared")
+)
+
+// Option configures the tracer
+type Option func(*Tracer)
+
+// WithoutQueryText leaves the SQL text off spans
+func WithoutQueryText() Option {
+	return func(t *Tracer) {
+		t.omitQuery = true
+	}
+}
+
+// Tracer creates spans for pgx queries, batches, prepares, connects, copies, and pool acquires.
+// Query arguments are never recorded.
+type Tracer struct {
+	omitQuery bool
+
+	mu        sync.Mutex
+	conns     map[*pgx.Conn][]attribute.KeyValue // connection attributes, cached per connection
+	sweepSize int                                // size of conns that triggers the next sweep
+}
+
+var (
+	_ pgx.QueryTracer       = (*Tracer)(nil)
+	_ pgx.BatchTracer       = (*Tracer)(nil)
+	_ pgx.CopyFromTracer    = (*Tracer)(nil)
+	_ pgx.PrepareTracer     = (*Tracer)(nil)
+	_ pgx.ConnectTracer     = (*Tracer)(nil)
+	_ pgxpool.AcquireTracer = (*Tracer)(nil)
+)
+
+// NewTracer creates a tracer to set as the ConnConfig.Tracer of a connection or pool:
+//
+//	config, err := pgxpool.ParseConfig(dsn)
+//	config.ConnConfig.Tracer = iudexpgx.NewTracer()
+//	pool, err := pgxpool.NewWithConfig(ctx, config)
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Tracer) start(ctx context.Context, name string, connAttrs []attribute.KeyValue, attrs ...attribute.KeyValue) context.Context {
+	attrs = append(attrs, semconv.DBSystemPostgreSQL)
+	attrs = append(attrs, connAttrs...)
+	ctx, _ = iudex.Tracer().Start(ctx, name,
+		oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+		oteltrace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+func (t *Tracer) queryAttrs(sql string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	if operation := sqlOperation(sql); operation != "" {
+		attrs = append(attrs, semconv.DBOperationName(operation))
+	}
+	if !t.omitQuery {
+		attrs = append(attrs, semconv.DBQueryText(sql))
+	}
+	return attrs
+}
+
+// end records err on the span in ctx and ends it
+func end(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	span := oteltrace.SpanFromContext(ctx)
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *Tracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := sqlOperation(data.SQL)
+	if name == "" {
+		name = "postgresql.query"
+	}
+	return t.start(ctx, name, t.connAttrs(conn), t.queryAttrs(data.SQL)...)
+}
+
+func (t *Tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	end(ctx, data.Err, RowsAffectedKey.Int64(data.CommandTag.RowsAffected()))
+}
+
+func (t *Tracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	size := 0
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+	return t.start(ctx, "postgresql.batch", t.connAttrs(conn), BatchSizeKey.Int(size))
+}
+
+// TraceBatchQuery records each query of a batch as an event on the batch span
+func (t *Tracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := append(t.queryAttrs(data.SQL), RowsAffectedKey.Int64(data.CommandTag.RowsAffected()))
+	if data.Err != nil {
+		attrs = append(attrs, semconv.ExceptionMessage(data.Err.Error()))
+	}
+	oteltrace.SpanFromContext(ctx).AddEvent("query", oteltrace.WithAttributes(attrs...))
+}
+
+func (t *Tracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	end(ctx, data.Err)
+}
+
+func (t *Tracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	table := data.TableName.Sanitize()
+	return t.start(ctx, "COPY "+table, t.connAttrs(conn),
+		semconv.DBOperationName("COPY"),
+		semconv.DBCollectionName(table),
+	)
+}
+
+func (t *Tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	end(ctx, data.Err, RowsAffectedKey.Int64(data.CommandTag.RowsAffected()))
+}
+
+func (t *Tracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
+	attrs := append(t.queryAttrs(data.SQL), PreparedStmtKey.String(data.Name))
+	return t.start(ctx, "PREPARE", t.connAttrs(conn), attrs...)
+}
+
+func (t *Tracer) TracePrepareEnd(ctx context.Context, _ *pgx.Conn, data pgx.TracePrepareEndData) {
+	end(ctx, data.Err, AlreadyPreparedKey.Bool(data.AlreadyPrepared))
+}
+
+func (t *Tracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	return t.start(ctx, "postgresql.connect", configAttrs(data.ConnConfig))
+}
+
+func (t *Tracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	end(ctx, data.Err)
+}
+
+// TraceAcquireStart records the pool state when a connection is requested
+func (t *Tracer) TraceAcquireStart(ctx context.Context, pool *pgxpool.Pool, _ pgxpool.TraceAcquireStartData) context.Context {
+	attrs := []attribute.KeyValue{}
+	if pool != nil {
+		stat := pool.Stat()
+		attrs = append(attrs,
+			PoolTotalConnsKey.Int(int(stat.TotalConns())),
+			PoolIdleConnsKey.Int(int(stat.IdleConns())),
+			PoolUsedConnsKey.Int(int(stat.AcquiredConns())),
+			PoolMaxConnsKey.Int(int(stat.MaxConns())),
+		)
+	}
+	return t.start(ctx, "postgresql.pool.acquire", nil, attrs...)
+}
+
+func (t *Tracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool, data pgxpool.TraceAcquireEndData) {
+	end(ctx, data.Err)
+}
+
+// configAttrs returns the database and server attributes of config
+func configAttrs(config *pgx.ConnConfig) []attribute.KeyValue {
+	if config == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		semconv.DBNamespace(config.Database),
+		semconv.ServerAddress(config.Host),
+		semconv.ServerPort(int(config.Port)),
+	}
+}
+
+// connAttrs returns the attributes of conn, cached since conn.Config copies the whole config.
+// Closed connections are swept from the cache whenever it has doubled in size.
+func (t *Tracer) connAttrs(conn *pgx.Conn) []attribute.KeyValue {
+	if conn == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if attrs, ok := t.conns[conn]; ok {
+		return attrs
+	}
+	if t.conns == nil {
+		t.conns = map[*pgx.Conn][]attribute.KeyValue{}
+	}
+	if len(t.conns) >= t.sweepSize {
+		for cached := range t.conns {
+			if cached.IsClosed() {
+				delete(t.conns, cached)
+			}
+		}
+		t.sweepSize = max(2*len(t.conns), 16)
+	}
+	attrs := configAttrs(conn.Config())
+	t.conns[conn] = attrs
+	return attrs
+}
+
+// sqlOperation returns the leading SQL keyword of sql, e.g. SELECT
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
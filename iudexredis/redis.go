@@ -0,0 +1,154 @@
+// Package iudexredis traces go-redis commands with IUDEX
+package iudexredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/iudexai/iudex-go"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes recorded by the hook
+const (
+	KeyPrefixKey    = attribute.Key("db.redis.key_prefix")
+	PipelineSizeKey = attribute.Key("db.redis.pipeline.size")
+	PipelineCmdsKey = attribute.Key("db.redis.pipeline.commands")
+)
+
+const defaultSeparator = ":"
+
+// Option configures the hook
+type Option func(*hook)
+
+// WithKeySeparator sets the separator that ends a key prefix, ":" by default.
+// The prefix of "user:123" is "user".
+func WithKeySeparator(separator string) Option {
+	return func(h *hook) {
+		h.separator = separator
+	}
+}
+
+type hook struct {
+	separator string
+}
+
+// NewHook creates a go-redis hook that traces connection dials, commands, and pipelines.
+// Only key prefixes are recorded, never full keys or values, to keep attributes low cardinality.
+// redis.Nil replies are not treated as errors.
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	rdb.AddHook(iudexredis.NewHook())
+func NewHook(opts ...Option) redis.Hook {
+	h := &hook{separator: defaultSeparator}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, span := iudex.Tracer().Start(ctx, "redis.dial",
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(semconv.DBSystemRedis, semconv.ServerAddress(addr)),
+		)
+		defer span.End()
+
+		conn, err := next(ctx, network, addr)
+		recordError(span, err)
+		return conn, err
+	}
+}
+
+func (h *hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemRedis,
+			semconv.DBOperationName(cmd.Name()),
+		}
+		if prefix := h.keyPrefix(cmd); prefix != "" {
+			attrs = append(attrs, KeyPrefixKey.String(prefix))
+		}
+		ctx, span := iudex.Tracer().Start(ctx, strings.ToUpper(cmd.Name()),
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		err := next(ctx, cmd)
+		recordError(span, err)
+		return err
+	}
+}
+
+func (h *hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		prefixes := make([]string, 0, len(cmds))
+		seenNames := map[string]bool{}
+		seenPrefixes := map[string]bool{}
+		for _, cmd := range cmds {
+			if name := cmd.Name(); !seenNames[name] {
+				seenNames[name] = true
+				names = append(names, name)
+			}
+			if prefix := h.keyPrefix(cmd); prefix != "" && !seenPrefixes[prefix] {
+				seenPrefixes[prefix] = true
+				prefixes = append(prefixes, prefix)
+			}
+		}
+
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemRedis,
+			semconv.DBOperationName("pipeline"),
+			PipelineSizeKey.Int(len(cmds)),
+			PipelineCmdsKey.StringSlice(names),
+		}
+		if len(prefixes) > 0 {
+			attrs = append(attrs, KeyPrefixKey.StringSlice(prefixes))
+		}
+		ctx, span := iudex.Tracer().Start(ctx, "redis.pipeline",
+			oteltrace.WithSpanKind(oteltrace.SpanKindClient),
+			oteltrace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		err := next(ctx, cmds)
+		recordError(span, err)
+		return err
+	}
+}
+
+// keyPrefix returns the part of the command's first key before the separator, or "" when the
+// command has no key or the key has no prefix
+func (h *hook) keyPrefix(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return ""
+	}
+	prefix, _, found := strings.Cut(key, h.separator)
+	if !found {
+		return ""
+	}
+	return prefix
+}
+
+// recordError marks span as failed unless err is nil or a redis.Nil miss
+func recordError(span oteltrace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
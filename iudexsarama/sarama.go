@@ -0,0 +1,142 @@
+// Package iudexsarama traces IBM/sarama Kafka producers and consumers with IUDEX
+package iudexsarama
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/IBM/sarama"
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// ProducerMessageCarrier adapts the headers of a producer message to a propagation.TextMapCarrier
+type ProducerMessageCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+// NewProducerMessageCarrier creates a carrier that injects trace context into msg's headers
+func NewProducerMessageCarrier(msg *sarama.ProducerMessage) ProducerMessageCarrier {
+	return ProducerMessageCarrier{msg: msg}
+}
+
+func (c ProducerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c ProducerMessageCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c ProducerMessageCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
+
+// ConsumerMessageCarrier adapts the headers of a consumed message to a propagation.TextMapCarrier
+type ConsumerMessageCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+// NewConsumerMessageCarrier creates a carrier that extracts trace context from msg's headers
+func NewConsumerMessageCarrier(msg *sarama.ConsumerMessage) ConsumerMessageCarrier {
+	return ConsumerMessageCarrier{msg: msg}
+}
+
+func (c ConsumerMessageCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c ConsumerMessageCarrier) Set(key, value string) {
+	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (c ConsumerMessageCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
+
+// SendMessage sends msg with producer inside a producer span, injecting the span's trace context
+// into the message headers so consumers continue the trace
+func SendMessage(ctx context.Context, producer sarama.SyncProducer, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	ctx, span := iudex.Tracer().Start(ctx, "publish "+msg.Topic,
+		oteltrace.WithSpanKind(oteltrace.SpanKindProducer),
+		oteltrace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(msg.Topic),
+		),
+	)
+	defer span.End()
+	otel.GetTextMapPropagator().Inject(ctx, NewProducerMessageCarrier(msg))
+
+	partition, offset, err = producer.SendMessage(msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return partition, offset, err
+	}
+	span.SetAttributes(
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(partition))),
+		semconv.MessagingKafkaMessageOffset(int(offset)),
+	)
+	return partition, offset, nil
+}
+
+// StartProcessSpan starts a consumer span for msg that continues the trace of its producer.
+// The caller must end the returned span once the message is processed.
+func StartProcessSpan(ctx context.Context, msg *sarama.ConsumerMessage, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, NewConsumerMessageCarrier(msg))
+	attrs = append([]attribute.KeyValue{
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationTypeDeliver,
+		semconv.MessagingDestinationName(msg.Topic),
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(msg.Partition))),
+		semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+	}, attrs...)
+	return iudex.Tracer().Start(ctx, "process "+msg.Topic,
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithAttributes(attrs...),
+	)
+}
+
+// ProcessMessage runs fn inside a consumer span for msg, recording a returned error on the span
+func ProcessMessage(ctx context.Context, msg *sarama.ConsumerMessage, fn func(context.Context) error) error {
+	ctx, span := StartProcessSpan(ctx, msg)
+	defer span.End()
+
+	err := fn(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+// Package iudextemporal traces Temporal workflows and activities with IUDEX
+package iudextemporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Temporal attributes, set from the tags the Temporal SDK adds to every span
+const (
+	WorkflowIDKey = attribute.Key("temporal.workflow.id")
+	RunIDKey      = attribute.Key("temporal.run.id")
+	ActivityIDKey = attribute.Key("temporal.activity.id")
+	UpdateIDKey   = attribute.Key("temporal.update.id")
+)
+
+// tagKeys maps the Temporal SDK span tags to attributes
+var tagKeys = map[string]attribute.Key{
+	"temporalWorkflowID": WorkflowIDKey,
+	"temporalRunID":      RunIDKey,
+	"temporalActivityID": ActivityIDKey,
+	"temporalUpdateID":   UpdateIDKey,
+}
+
+// headerKey is the Temporal header that carries trace context. It matches the OpenTelemetry
+// tracer of the Temporal SDKs, so traces continue across services instrumented with either.
+const headerKey = "_tracer-data"
+
+// headerPropagator encodes trace context in the Temporal header. The header is only read by
+// Temporal interceptors, so it always uses W3C trace context, whatever formats Setup configured.
+var headerPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+type spanContextKey struct{}
+
+// NewInterceptor creates a Temporal interceptor that propagates trace context and baggage in
+// workflow and activity headers and creates spans for starting, signaling, querying, and
+// updating workflows, and for workflow and activity executions. Register it on the client;
+// workers created from the client use it too:
+//
+//	c, err := client.Dial(client.Options{
+//		Interceptors: []interceptor.ClientInterceptor{iudextemporal.NewInterceptor()},
+//	})
+//
+// Workflow spans start at the time recorded in workflow history rather than the wall clock, and
+// spans are not recreated when a workflow is replayed, so tracing never breaks determinism.
+func NewInterceptor() interceptor.Interceptor {
+	return interceptor.NewTracingInterceptor(&tracer{propagator: headerPropagator})
+}
+
+// tracer adapts the IUDEX tracer to the Temporal SDK tracing interceptor
+type tracer struct {
+	interceptor.BaseTracer
+	propagator propagation.TextMapPropagator
+}
+
+// spanRef is a parent span read from a Temporal header. It keeps the extracted context so
+// baggage propagates too.
+type spanRef struct {
+	ctx context.Context
+}
+
+// span is a span started by the tracer
+type span struct {
+	spanRef
+	oteltrace.Span
+}
+
+func (t *tracer) Options() interceptor.TracerOptions {
+	return interceptor.TracerOptions{
+		SpanContextKey: spanContextKey{},
+		HeaderKey:      headerKey,
+	}
+}
+
+func (t *tracer) UnmarshalSpan(carrier map[string]string) (interceptor.TracerSpanRef, error) {
+	ctx := t.propagator.Extract(context.Background(), propagation.MapCarrier(carrier))
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		return nil, errors.New("no valid span context in Temporal header")
+	}
+	return &spanRef{ctx: ctx}, nil
+}
+
+func (t *tracer) MarshalSpan(s interceptor.TracerSpan) (map[string]string, error) {
+	carrier := propagation.MapCarrier{}
+	t.propagator.Inject(s.(*span).ctx, carrier)
+	return carrier, nil
+}
+
+func (t *tracer) SpanFromContext(ctx context.Context) interceptor.TracerSpan {
+	s := oteltrace.SpanFromContext(ctx)
+	if !s.SpanContext().IsValid() {
+		return nil
+	}
+	return &span{spanRef: spanRef{ctx: ctx}, Span: s}
+}
+
+func (t *tracer) ContextWithSpan(ctx context.Context, s interceptor.TracerSpan) context.Context {
+	sp := s.(*span)
+	if bag := baggage.FromContext(sp.ctx); bag.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, bag)
+	}
+	return oteltrace.ContextWithSpan(ctx, sp.Span)
+}
+
+func (t *tracer) StartSpan(opts *interceptor.TracerStartSpanOptions) (interceptor.TracerSpan, error) {
+	ctx := context.Background()
+	switch parent := opts.Parent.(type) {
+	case *span:
+		ctx = parent.ctx
+	case *spanRef:
+		ctx = parent.ctx
+	case nil:
+	default:
+		return nil, fmt.Errorf("unrecognized parent span %T", opts.Parent)
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(opts.Tags))
+	for tag, value := range opts.Tags {
+		if key, ok := tagKeys[tag]; ok {
+			attrs = append(attrs, key.String(value))
+		} else {
+			attrs = append(attrs, attribute.String(tag, value))
+		}
+	}
+	startOpts := []oteltrace.SpanStartOption{
+		oteltrace.WithSpanKind(spanKind(opts.Operation)),
+		oteltrace.WithAttributes(attrs...),
+	}
+	// For workflow and activity executions, Time comes from history and is the same on replay
+	if !opts.Time.IsZero() {
+		startOpts = append(startOpts, oteltrace.WithTimestamp(opts.Time))
+	}
+
+	ctx, s := iudex.Tracer().Start(ctx, t.SpanName(opts), startOpts...)
+	return &span{spanRef: spanRef{ctx: ctx}, Span: s}, nil
+}
+
+// spanKind returns the span kind of a Temporal operation, such as StartWorkflow or RunActivity
+func spanKind(operation string) oteltrace.SpanKind {
+	for _, prefix := range []string{"Run", "Handle", "Validate"} {
+		if strings.HasPrefix(operation, prefix) {
+			return oteltrace.SpanKindServer
+		}
+	}
+	return oteltrace.SpanKindClient
+}
+
+// GetLogger adds the trace and span IDs to the Temporal logger, so workflow and activity logs
+// can be correlated with their spans
+func (t *tracer) GetLogger(logger log.Logger, ref interceptor.TracerSpanRef) log.Logger {
+	s, ok := ref.(*span)
+	if !ok {
+		return logger
+	}
+	sc := s.SpanContext()
+	return log.With(logger, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
+
+func (s *span) Finish(opts *interceptor.TracerFinishSpanOptions) {
+	// Continuing as new ends a workflow run on purpose
+	var continueAsNew *workflow.ContinueAsNewError
+	if opts.Error != nil && !errors.As(opts.Error, &continueAsNew) {
+		s.RecordError(opts.Error)
+		s.SetStatus(codes.Error, opts.Error.Error())
+	}
+	s.End()
+}
@@ -0,0 +1,162 @@
+// Package iudextest installs in-memory exporters so code instrumented with iudex and its
+// integrations can be unit-tested without exporting anything over the network.
+package iudextest
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	iudex "github.com/iudexai/iudex-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Harness records every span and log record emitted while a test runs
+type Harness struct {
+	t     testing.TB
+	spans *tracetest.InMemoryExporter
+	logs  *logExporter
+}
+
+// SetupTest installs tracer and logger providers that export synchronously into memory, and
+// restores the previous global providers when the test ends. The providers are global, so tests
+// using SetupTest must not run in parallel.
+func SetupTest(t testing.TB) *Harness {
+	t.Helper()
+
+	h := &Harness{t: t, spans: tracetest.NewInMemoryExporter(), logs: &logExporter{}}
+	tracerProvider := trace.NewTracerProvider(trace.WithSyncer(h.spans))
+	loggerProvider := log.NewLoggerProvider(log.WithProcessor(log.NewSimpleProcessor(h.logs)))
+
+	prevTracerProvider := otel.GetTracerProvider()
+	prevLoggerProvider := global.GetLoggerProvider()
+	prevPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tracerProvider)
+	global.SetLoggerProvider(loggerProvider)
+	otel.SetTextMapPropagator(iudex.NewPropagator())
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		_ = tracerProvider.Shutdown(ctx)
+		_ = loggerProvider.Shutdown(ctx)
+		otel.SetTracerProvider(prevTracerProvider)
+		global.SetLoggerProvider(prevLoggerProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return h
+}
+
+// Spans returns the ended spans, oldest first
+func (h *Harness) Spans() tracetest.SpanStubs {
+	return h.spans.GetSpans()
+}
+
+// SpansByName returns the ended spans with the given name
+func (h *Harness) SpansByName(name string) tracetest.SpanStubs {
+	var spans tracetest.SpanStubs
+	for _, span := range h.spans.GetSpans() {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// RequireSpan returns the first ended span with the given name, failing the test when there is none
+func (h *Harness) RequireSpan(name string) tracetest.SpanStub {
+	h.t.Helper()
+	spans := h.SpansByName(name)
+	if len(spans) == 0 {
+		h.t.Fatalf("no span named %q, got %v", name, spanNames(h.spans.GetSpans()))
+	}
+	return spans[0]
+}
+
+// RequireSpanAttr fails the test unless the first span with the given name has attribute key
+// set to want. want is converted like iudex.Attr, so an int matches an int64 attribute.
+func (h *Harness) RequireSpanAttr(name, key string, want any) {
+	h.t.Helper()
+	span := h.RequireSpan(name)
+	wantValue := iudex.Attr(key, want).Value.AsInterface()
+	for _, attr := range span.Attributes {
+		if string(attr.Key) != key {
+			continue
+		}
+		if got := attr.Value.AsInterface(); !reflect.DeepEqual(got, wantValue) {
+			h.t.Fatalf("span %q: attribute %q is %v, want %v", name, key, got, wantValue)
+		}
+		return
+	}
+	h.t.Fatalf("span %q has no attribute %q", name, key)
+}
+
+// Logs returns the emitted log records, oldest first
+func (h *Harness) Logs() []log.Record {
+	return h.logs.records()
+}
+
+// LogsByBody returns the emitted log records whose body is the given string
+func (h *Harness) LogsByBody(body string) []log.Record {
+	var records []log.Record
+	for _, record := range h.logs.records() {
+		if record.Body().AsString() == body {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Reset forgets all recorded spans and log records
+func (h *Harness) Reset() {
+	h.spans.Reset()
+	h.logs.reset()
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	return names
+}
+
+// logExporter keeps exported log records in memory
+type logExporter struct {
+	mu       sync.Mutex
+	exported []log.Record
+}
+
+func (e *logExporter) Export(_ context.Context, records []log.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// The SDK may reuse records after Export returns
+	for _, record := range records {
+		e.exported = append(e.exported, record.Clone())
+	}
+	return nil
+}
+
+func (e *logExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func (e *logExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *logExporter) records() []log.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]log.Record(nil), e.exported...)
+}
+
+func (e *logExporter) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exported = nil
+}
@@ -0,0 +1,165 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Job attributes
+const (
+	JobNameKey    = attribute.Key("job.name")
+	JobIDKey      = attribute.Key("job.id")
+	JobAttemptKey = attribute.Key("job.attempt")
+	JobOutcomeKey = attribute.Key("job.outcome")
+)
+
+// Job outcomes, recorded in the job.outcome attribute
+const (
+	JobOutcomeSuccess = "success"
+	JobOutcomeFailure = "failure"
+	JobOutcomeRetry   = "retry"
+)
+
+// jobFlushTimeout bounds how long a job with WithJobFlush waits for telemetry to export
+const jobFlushTimeout = 5 * time.Second
+
+// JobOption configures a job started with StartJob or RunJob
+type JobOption func(*jobConfig)
+
+type jobConfig struct {
+	id      string
+	attempt int
+	links   []oteltrace.Link
+	attrs   []Attribute
+	flush   bool
+}
+
+// WithJobID sets the job.id attribute, e.g. to the queue's message ID
+func WithJobID(id string) JobOption {
+	return func(c *jobConfig) {
+		c.id = id
+	}
+}
+
+// WithJobAttempt sets the job.attempt attribute, starting at 1 for the first attempt
+func WithJobAttempt(attempt int) JobOption {
+	return func(c *jobConfig) {
+		c.attempt = attempt
+	}
+}
+
+// WithJobLink links the job to the span in ctx, e.g. the context returned by Extract for trace
+// context carried in the job payload. The span in the ctx passed to StartJob is linked by default.
+func WithJobLink(ctx context.Context) JobOption {
+	return func(c *jobConfig) {
+		if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+			c.links = append(c.links, oteltrace.Link{SpanContext: sc})
+		}
+	}
+}
+
+// WithJobAttributes adds attributes to the job span
+func WithJobAttributes(attrs ...Attribute) JobOption {
+	return func(c *jobConfig) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// WithJobFlush flushes all telemetry when the job ends, for short-lived workers that may exit
+// or be frozen before the batch processors export
+func WithJobFlush() JobOption {
+	return func(c *jobConfig) {
+		c.flush = true
+	}
+}
+
+// Job is a unit of asynchronous work traced as the root span of its own trace
+type Job struct {
+	span  oteltrace.Span
+	flush bool
+	once  sync.Once
+}
+
+// StartJob starts a job span as the root of a new trace, so long-running or retried jobs do not
+// stretch the trace that enqueued them. The span in ctx, if any, is linked instead. The caller
+// must end the job with End or Retry.
+//
+//	ctx, job := iudex.StartJob(ctx, "send-invoice", iudex.WithJobID(msg.ID), iudex.WithJobAttempt(msg.Attempt))
+//	job.End(sendInvoice(ctx, msg))
+func StartJob(ctx context.Context, name string, opts ...JobOption) (context.Context, *Job) {
+	config := jobConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	links := config.links
+	if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+		links = append([]oteltrace.Link{{SpanContext: sc}}, links...)
+	}
+	attrs := append([]Attribute{JobNameKey.String(name)}, config.attrs...)
+	if config.id != "" {
+		attrs = append(attrs, JobIDKey.String(config.id))
+	}
+	if config.attempt > 0 {
+		attrs = append(attrs, JobAttemptKey.Int(config.attempt))
+	}
+
+	ctx, span := Tracer().Start(ctx, name,
+		oteltrace.WithNewRoot(),
+		oteltrace.WithSpanKind(oteltrace.SpanKindConsumer),
+		oteltrace.WithLinks(links...),
+		oteltrace.WithAttributes(attrs...),
+	)
+	return ctx, &Job{span: span, flush: config.flush}
+}
+
+// End ends the job, recording success, or failure when err is not nil. Only the first call to
+// End or Retry has an effect.
+func (j *Job) End(err error) {
+	if err != nil {
+		j.finish(JobOutcomeFailure, err)
+		return
+	}
+	j.finish(JobOutcomeSuccess, nil)
+}
+
+// Retry ends the job, recording that the attempt failed with err and will be retried
+func (j *Job) Retry(err error) {
+	j.finish(JobOutcomeRetry, err)
+}
+
+func (j *Job) finish(outcome string, err error) {
+	j.once.Do(func() {
+		j.span.SetAttributes(JobOutcomeKey.String(outcome))
+		if err != nil {
+			recordSpanError(j.span, err)
+		}
+		j.span.End()
+
+		if j.flush {
+			ctx, cancel := context.WithTimeout(context.Background(), jobFlushTimeout)
+			defer cancel()
+			_ = ForceFlush(ctx)
+		}
+	})
+}
+
+// RunJob runs fn as a job started with StartJob, ending it with the returned error. A panic is
+// recorded as a failure and re-panicked.
+func RunJob(ctx context.Context, name string, fn func(context.Context) error, opts ...JobOption) (err error) {
+	ctx, job := StartJob(ctx, name, opts...)
+	defer func() {
+		if r := recover(); r != nil {
+			job.End(fmt.Errorf("panic: %v", r))
+			panic(r)
+		}
+		job.End(err)
+	}()
+
+	return fn(ctx)
+}
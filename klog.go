@@ -0,0 +1,231 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"k8s.io/klog/v2"
+)
+
+// Attributes of records from klog and glog
+const (
+	// LogComponentKey is the name of the logger, with the names added by logr's WithName joined by slashes
+	LogComponentKey = "component"
+	// LogVerbosityKey is the klog verbosity of info records, as in klog.V(2).InfoS
+	LogVerbosityKey = "v"
+)
+
+// InstallKlog routes klog output, from both structured calls like klog.InfoS and printf style
+// calls like klog.Warningf, into the OTel log pipeline instead of stderr. Records carry name in
+// the component attribute. klog still applies its -v and -vmodule flags before records are emitted.
+// Call it during initialization, before other goroutines log.
+func InstallKlog(name string) {
+	writer := NewGlogWriter(name)
+	klog.SetLoggerWithOptions(NewKlogLogger(name, WithKlogVerbosity(math.MaxInt)), klog.WriteKlogBuffer(func(p []byte) {
+		_, _ = writer.Write(p)
+	}))
+}
+
+// NewKlogLogger creates a logr.Logger that emits OTel log records, for Kubernetes libraries that
+// take a logr.Logger, e.g. ctrl.SetLogger from controller-runtime. Only info records up to the
+// verbosity set with WithKlogVerbosity, 0 by default, are emitted, and those of verbosity 1 and
+// above have debug severity. Names added with WithName are appended to the component attribute.
+func NewKlogLogger(name string, opts ...LoggerOption) logr.Logger {
+	config := newLoggerConfig(opts)
+	return logr.New(&klogSink{
+		logger:    GetLoggerProvider().Logger(name),
+		component: name,
+		verbosity: config.klogVerbosity,
+	})
+}
+
+// WithKlogVerbosity sets the highest verbosity of info records emitted by NewKlogLogger, as in
+// the -v flag of klog, so V(n) records above it are skipped without being formatted
+func WithKlogVerbosity(verbosity int) LoggerOption {
+	return func(c *loggerConfig) {
+		c.klogVerbosity = verbosity
+	}
+}
+
+// klogSink is a logr.LogSink that emits OTel log records
+type klogSink struct {
+	logger    otellog.Logger
+	component string
+	verbosity int
+	values    []otellog.KeyValue
+}
+
+func (s *klogSink) Init(logr.RuntimeInfo) {}
+
+// Enabled reports whether info records of level are emitted
+func (s *klogSink) Enabled(level int) bool {
+	return level <= s.verbosity
+}
+
+func (s *klogSink) Info(level int, msg string, keysAndValues ...any) {
+	severity, text := otellog.SeverityInfo, "INFO"
+	if level > 0 {
+		severity, text = otellog.SeverityDebug, "DEBUG"
+	}
+	// Copy so the caller's backing array is never written to
+	keysAndValues = append(keysAndValues[:len(keysAndValues):len(keysAndValues)], LogVerbosityKey, level)
+	s.emit(severity, text, msg, nil, keysAndValues)
+}
+
+func (s *klogSink) Error(err error, msg string, keysAndValues ...any) {
+	s.emit(otellog.SeverityError, "ERROR", msg, err, keysAndValues)
+}
+
+func (s *klogSink) emit(severity otellog.Severity, text, msg string, err error, keysAndValues []any) {
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetBody(otellog.StringValue(msg))
+	record.SetSeverity(severity)
+	record.SetSeverityText(text)
+	record.AddAttributes(s.values...)
+	record.AddAttributes(logrKeyValues(keysAndValues)...)
+	if err != nil {
+		record.AddAttributes(
+			otellog.String(string(semconv.ExceptionMessageKey), err.Error()),
+			otellog.String(string(semconv.ExceptionTypeKey), fmt.Sprintf("%T", err)),
+		)
+	}
+	record.AddAttributes(otellog.String(LogComponentKey, s.component))
+	s.logger.Emit(context.Background(), record)
+}
+
+func (s *klogSink) WithValues(keysAndValues ...any) logr.LogSink {
+	values := append(s.values[:len(s.values):len(s.values)], logrKeyValues(keysAndValues)...)
+	return &klogSink{logger: s.logger, component: s.component, verbosity: s.verbosity, values: values}
+}
+
+func (s *klogSink) WithName(name string) logr.LogSink {
+	return &klogSink{logger: s.logger, component: s.component + "/" + name, verbosity: s.verbosity, values: s.values}
+}
+
+// logrKeyValues converts logr's alternating keys and values to log attributes. A key without a
+// value gets an empty one.
+func logrKeyValues(keysAndValues []any) []otellog.KeyValue {
+	attrs := make([]otellog.KeyValue, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var value otellog.Value
+		if i+1 < len(keysAndValues) {
+			value = toLogValue(keysAndValues[i+1])
+		}
+		attrs = append(attrs, otellog.KeyValue{Key: key, Value: value})
+	}
+	return attrs
+}
+
+// GlogWriter is an io.Writer that forwards log lines in the glog text format, which klog also
+// writes, to the global LoggerProvider
+type GlogWriter struct {
+	logger    otellog.Logger
+	component string
+}
+
+// NewGlogWriter creates a writer that parses lines like
+// "W1016 04:01:26.972334   12345 controller.go:42] message" into OTel log records, with the
+// severity of their first letter, the file and line in code.filepath and code.lineno, and name in
+// the component attribute. glog has no hook for its output, so run it with -logtostderr and copy
+// stderr into the writer line by line. Lines without a header are forwarded as info records.
+func NewGlogWriter(name string) *GlogWriter {
+	return &GlogWriter{
+		logger:    GetLoggerProvider().Logger(name),
+		component: name,
+	}
+}
+
+// Write parses one or more newline separated entries and emits them as log records. Lines
+// without a header continue the message of the entry before them in the same write.
+func (w *GlogWriter) Write(p []byte) (int, error) {
+	var entry []byte
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if glogHeader.Match(line) && entry != nil {
+			w.emit(entry)
+			entry = nil
+		}
+		if entry != nil {
+			entry = append(append(entry, '\n'), line...)
+		} else {
+			entry = line
+		}
+	}
+	if len(bytes.TrimSpace(entry)) > 0 {
+		w.emit(entry)
+	}
+	return len(p), nil
+}
+
+// glogHeader matches the header of a glog line: severity, date, time, thread ID, file, and line
+var glogHeader = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}\.\d{6})\s+(\d+) ([^:\]]+):(\d+)\] ?`)
+
+func (w *GlogWriter) emit(entry []byte) {
+	record := otellog.Record{}
+	record.AddAttributes(otellog.String(LogComponentKey, w.component))
+
+	match := glogHeader.FindSubmatch(entry)
+	if match == nil {
+		record.SetTimestamp(time.Now())
+		record.SetSeverity(otellog.SeverityInfo)
+		record.SetSeverityText("INFO")
+		record.SetBody(otellog.StringValue(string(entry)))
+		w.logger.Emit(context.Background(), record)
+		return
+	}
+
+	record.SetTimestamp(glogTime(string(match[2])))
+	severity, text := glogSeverity(match[1][0])
+	record.SetSeverity(severity)
+	record.SetSeverityText(text)
+	record.SetBody(otellog.StringValue(string(entry[len(match[0]):])))
+	line, _ := strconv.Atoi(string(match[5]))
+	thread, _ := strconv.Atoi(string(match[3]))
+	record.AddAttributes(
+		otellog.String(string(semconv.CodeFilepathKey), string(match[4])),
+		otellog.Int(string(semconv.CodeLineNumberKey), line),
+		otellog.Int(string(semconv.ThreadIDKey), thread),
+	)
+	w.logger.Emit(context.Background(), record)
+}
+
+// glogTime parses the header time, which has no year, in the local time zone of the current year
+func glogTime(s string) time.Time {
+	now := time.Now()
+	t, err := time.ParseInLocation("0102 15:04:05.000000", s, time.Local)
+	if err != nil {
+		return now
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	// Entries from the end of December read in early January belong to the previous year
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// glogSeverity maps the severity letter of a glog header to an OTel severity
+func glogSeverity(letter byte) (otellog.Severity, string) {
+	switch letter {
+	case 'W':
+		return otellog.SeverityWarn, "WARNING"
+	case 'E':
+		return otellog.SeverityError, "ERROR"
+	case 'F':
+		return otellog.SeverityFatal, "FATAL"
+	default:
+		return otellog.SeverityInfo, "INFO"
+	}
+}
@@ -0,0 +1,43 @@
+package iudex
+
+import (
+	"context"
+	"os"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// WrapLambdaHandler traces every invocation of handler and flushes all telemetry before returning,
+// so nothing is lost when Lambda freezes the execution environment. A panic is recorded on the
+// invocation span, which is flushed before the panic continues.
+// Use it as lambda.Start(iudex.WrapLambdaHandler(handler)).
+func WrapLambdaHandler[TIn, TOut any](handler func(context.Context, TIn) (TOut, error)) func(context.Context, TIn) (TOut, error) {
+	name, ok := os.LookupEnv("AWS_LAMBDA_FUNCTION_NAME")
+	if !ok {
+		name = "lambda.invoke"
+	}
+
+	return func(ctx context.Context, event TIn) (TOut, error) {
+		ctx, span := Tracer().Start(ctx, name,
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(semconv.CloudProviderAWS, semconv.FaaSName(name)),
+		)
+		defer func() {
+			if rec := recover(); rec != nil {
+				recordPanic(ctx, rec, func() { span.End() })
+				panic(rec)
+			}
+		}()
+
+		out, err := handler(ctx, event)
+		if err != nil {
+			recordSpanError(span, err)
+		}
+		span.End()
+
+		// Flush errors are not the handler's concern; the invocation result is returned as is
+		_ = ForceFlush(ctx)
+		return out, err
+	}
+}
@@ -0,0 +1,54 @@
+package iudex
+
+import (
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// AttributeLimits bounds the size of spans and log records before export, so oversized payloads
+// are truncated client-side instead of being rejected at ingestion. Zero values keep the
+// OpenTelemetry defaults, which the OTEL_SPAN_*_LIMIT, OTEL_ATTRIBUTE_*_LIMIT, and
+// OTEL_LOGRECORD_*_LIMIT environment variables override. Negative values remove a limit.
+type AttributeLimits struct {
+	// ValueLength truncates string attribute values of spans, events, links, and log records
+	// to this many bytes. Unlimited by default.
+	ValueLength int
+	// SpanAttributeCount and LogAttributeCount cap the attributes of a span or log record, 128 by default
+	SpanAttributeCount int
+	LogAttributeCount  int
+	// EventCount and LinkCount cap the events and links of a span, 128 by default
+	EventCount int
+	LinkCount  int
+	// AttributePerEventCount and AttributePerLinkCount cap the attributes of an event or link, 128 by default
+	AttributePerEventCount int
+	AttributePerLinkCount  int
+}
+
+// spanLimits applies the configured limits over the defaults
+func (l AttributeLimits) spanLimits() trace.SpanLimits {
+	limits := trace.NewSpanLimits()
+	override := func(limit *int, value int) {
+		if value != 0 {
+			*limit = value
+		}
+	}
+	override(&limits.AttributeValueLengthLimit, l.ValueLength)
+	override(&limits.AttributeCountLimit, l.SpanAttributeCount)
+	override(&limits.EventCountLimit, l.EventCount)
+	override(&limits.LinkCountLimit, l.LinkCount)
+	override(&limits.AttributePerEventCountLimit, l.AttributePerEventCount)
+	override(&limits.AttributePerLinkCountLimit, l.AttributePerLinkCount)
+	return limits
+}
+
+// logLimitOptions converts the configured limits into logger provider options
+func (l AttributeLimits) logLimitOptions() []log.LoggerProviderOption {
+	var opts []log.LoggerProviderOption
+	if l.ValueLength != 0 {
+		opts = append(opts, log.WithAttributeValueLengthLimit(l.ValueLength))
+	}
+	if l.LogAttributeCount != 0 {
+		opts = append(opts, log.WithAttributeCountLimit(l.LogAttributeCount))
+	}
+	return opts
+}
@@ -0,0 +1,91 @@
+package iudex
+
+import (
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// toLogValue converts an arbitrary field value into an OTel log value
+func toLogValue(v any) otellog.Value {
+	switch v := v.(type) {
+	case nil:
+		return otellog.Value{}
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case int:
+		return otellog.IntValue(v)
+	case int8:
+		return otellog.Int64Value(int64(v))
+	case int16:
+		return otellog.Int64Value(int64(v))
+	case int32:
+		return otellog.Int64Value(int64(v))
+	case int64:
+		return otellog.Int64Value(v)
+	case uint8:
+		return otellog.Int64Value(int64(v))
+	case uint16:
+		return otellog.Int64Value(int64(v))
+	case uint32:
+		return otellog.Int64Value(int64(v))
+	case float32:
+		return otellog.Float64Value(float64(v))
+	case float64:
+		return otellog.Float64Value(v)
+	case []byte:
+		return otellog.BytesValue(v)
+	case time.Time:
+		return otellog.StringValue(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return otellog.StringValue(v.String())
+	case error:
+		return otellog.StringValue(v.Error())
+	case fmt.Stringer:
+		return otellog.StringValue(v.String())
+	case []any:
+		values := make([]otellog.Value, 0, len(v))
+		for _, item := range v {
+			values = append(values, toLogValue(item))
+		}
+		return otellog.SliceValue(values...)
+	case map[string]any:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for key, item := range v {
+			kvs = append(kvs, otellog.KeyValue{Key: key, Value: toLogValue(item)})
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		return otellog.StringValue(fmt.Sprintf("%+v", v))
+	}
+}
+
+// attributeLogValue converts a span attribute value into an OTel log value
+func attributeLogValue(v attribute.Value) otellog.Value {
+	var values []otellog.Value
+	switch v.Type() {
+	case attribute.BOOLSLICE:
+		for _, item := range v.AsBoolSlice() {
+			values = append(values, otellog.BoolValue(item))
+		}
+	case attribute.INT64SLICE:
+		for _, item := range v.AsInt64Slice() {
+			values = append(values, otellog.Int64Value(item))
+		}
+	case attribute.FLOAT64SLICE:
+		for _, item := range v.AsFloat64Slice() {
+			values = append(values, otellog.Float64Value(item))
+		}
+	case attribute.STRINGSLICE:
+		for _, item := range v.AsStringSlice() {
+			values = append(values, otellog.StringValue(item))
+		}
+	default:
+		return toLogValue(v.AsInterface())
+	}
+	return otellog.SliceValue(values...)
+}
@@ -0,0 +1,358 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LoggerOption configures the loggers created by NewSlogLogger, NewZapLogger, NewStdLogger, and NewKlogLogger
+type LoggerOption func(*loggerConfig)
+
+type loggerConfig struct {
+	caller     bool
+	callerSkip int
+	slogTee    []slog.Handler
+	zapTee     []zapcore.Core
+	zapLevel   zapcore.LevelEnabler
+	zapFields  bool
+	stdLevel   otellog.Severity
+
+	klogVerbosity int
+}
+
+func newLoggerConfig(opts []LoggerOption) loggerConfig {
+	config := loggerConfig{stdLevel: otellog.SeverityInfo}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithLogCaller records where each log record was emitted from in the code.filepath, code.lineno,
+// and code.function attributes
+func WithLogCaller() LoggerOption {
+	return func(c *loggerConfig) {
+		c.caller = true
+	}
+}
+
+// WithLogCallerSkip records the caller like WithLogCaller, skipping skip more frames, for helpers
+// that wrap the logger
+func WithLogCallerSkip(skip int) LoggerOption {
+	return func(c *loggerConfig) {
+		c.caller = true
+		c.callerSkip = skip
+	}
+}
+
+// WithSlogTee also sends the records of a logger created by NewSlogLogger to handler, e.g. to keep
+// logs on stdout for kubectl logs while they are exported. handler filters records by its own
+// level, independently of the export log level. Records logged with a span in their context get
+// trace_id, span_id, and trace_flags attributes in handler, to find their trace from local logs.
+//
+//	logger := iudex.NewSlogLogger("main", iudex.WithSlogTee(slog.NewJSONHandler(os.Stdout, nil)))
+func WithSlogTee(handler slog.Handler) LoggerOption {
+	return func(c *loggerConfig) {
+		c.slogTee = append(c.slogTee, handler)
+	}
+}
+
+// WithZapTee also writes the entries of a logger created by NewZapLogger to core, which filters
+// entries by its own level, independently of WithZapLevel and the export log level. A context
+// field, e.g. zap.Any("context", ctx), is written to core as trace_id, span_id, and trace_flags.
+func WithZapTee(core zapcore.Core) LoggerOption {
+	return func(c *loggerConfig) {
+		c.zapTee = append(c.zapTee, core)
+	}
+}
+
+// WithZapConsole tees the entries of a logger created by NewZapLogger at level or above to stderr,
+// formatted by zap's console encoder, so local output keeps working while logs are exported
+func WithZapConsole(level zapcore.Level) LoggerOption {
+	encoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	return WithZapTee(zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), level))
+}
+
+// WithZapLevel exports only the entries of a logger created by NewZapLogger that level enables,
+// e.g. zapcore.WarnLevel to print info logs to the console with WithZapConsole but export warnings
+// and errors only
+func WithZapLevel(level zapcore.LevelEnabler) LoggerOption {
+	return func(c *loggerConfig) {
+		c.zapLevel = level
+	}
+}
+
+// WithZapFieldMapping exports zap fields as richer log attributes: durations and times as
+// readable strings rather than nanoseconds, and the error of zap.Error as the exception.message
+// and exception.type attributes, with the stack trace of errors that print one with %+v in
+// exception.stacktrace
+func WithZapFieldMapping() LoggerOption {
+	return func(c *loggerConfig) {
+		c.zapFields = true
+	}
+}
+
+// WithStdLogSeverity sets the severity of the records written through a logger created by
+// NewStdLogger, which has no levels of its own, e.g. otellog.SeverityError for http.Server.ErrorLog.
+// It defaults to info.
+func WithStdLogSeverity(severity otellog.Severity) LoggerOption {
+	return func(c *loggerConfig) {
+		c.stdLevel = severity
+	}
+}
+
+// fanoutHandler sends records to every handler that is enabled for them
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			if err := handler.Handle(ctx, record.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+// rootAttrsHandler adds attributes to records at the top level, outside of any group. Attributes
+// added after a group would be nested in it, so once a group is opened they are added to the
+// handler from before the group and the later WithAttrs and WithGroup calls are replayed on top.
+type rootAttrsHandler struct {
+	slog.Handler
+	base slog.Handler
+	ops  []func(slog.Handler) slog.Handler
+}
+
+func newRootAttrsHandler(handler slog.Handler) rootAttrsHandler {
+	return rootAttrsHandler{Handler: handler, base: handler}
+}
+
+// handle passes record on with attrs added at the top level
+func (h rootAttrsHandler) handle(ctx context.Context, record slog.Record, attrs ...slog.Attr) error {
+	if len(h.ops) == 0 {
+		record.AddAttrs(attrs...)
+		return h.Handler.Handle(ctx, record)
+	}
+	handler := h.base.WithAttrs(attrs)
+	for _, op := range h.ops {
+		handler = op(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h rootAttrsHandler) withAttrs(attrs []slog.Attr) rootAttrsHandler {
+	if len(h.ops) == 0 {
+		return newRootAttrsHandler(h.base.WithAttrs(attrs))
+	}
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h rootAttrsHandler) withGroup(name string) rootAttrsHandler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h rootAttrsHandler) with(op func(slog.Handler) slog.Handler) rootAttrsHandler {
+	ops := append(h.ops[:len(h.ops):len(h.ops)], op)
+	return rootAttrsHandler{Handler: op(h.Handler), base: h.base, ops: ops}
+}
+
+// callerHandler adds the caller of each record to its attributes, outside of any group
+type callerHandler struct {
+	rootAttrsHandler
+	skip int
+}
+
+func newCallerHandler(handler slog.Handler, skip int) *callerHandler {
+	return &callerHandler{rootAttrsHandler: newRootAttrsHandler(handler), skip: skip}
+}
+
+func (h *callerHandler) Handle(ctx context.Context, record slog.Record) error {
+	frame, ok := h.caller(record.PC)
+	if !ok {
+		return h.Handler.Handle(ctx, record)
+	}
+	return h.handle(ctx, record,
+		slog.String(string(semconv.CodeFilepathKey), frame.File),
+		slog.Int(string(semconv.CodeLineNumberKey), frame.Line),
+		slog.String(string(semconv.CodeFunctionKey), frame.Function),
+	)
+}
+
+// caller resolves the frame of pc, or the frame skip levels above it. The skipped frames are
+// found on the current stack, since Handle runs within the logging call.
+func (h *callerHandler) caller(pc uintptr) (runtime.Frame, bool) {
+	if pc == 0 {
+		return runtime.Frame{}, false
+	}
+	if h.skip > 0 {
+		pcs := make([]uintptr, 64)
+		n := runtime.Callers(2, pcs)
+		target := pc
+		pc = 0
+		for i := 0; i < n; i++ {
+			if pcs[i] == target && i+h.skip < n {
+				pc = pcs[i+h.skip]
+				break
+			}
+		}
+		if pc == 0 {
+			return runtime.Frame{}, false
+		}
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return frame, frame.File != ""
+}
+
+func (h *callerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &callerHandler{rootAttrsHandler: h.withAttrs(attrs), skip: h.skip}
+}
+
+func (h *callerHandler) WithGroup(name string) slog.Handler {
+	return &callerHandler{rootAttrsHandler: h.withGroup(name), skip: h.skip}
+}
+
+// callerCore adds the caller of each zap entry to its fields
+type callerCore struct {
+	zapcore.Core
+}
+
+func (c *callerCore) With(fields []zapcore.Field) zapcore.Core {
+	return &callerCore{Core: c.Core.With(fields)}
+}
+
+func (c *callerCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *callerCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if entry.Caller.Defined {
+		fields = append(fields,
+			zap.String(string(semconv.CodeFilepathKey), entry.Caller.File),
+			zap.Int(string(semconv.CodeLineNumberKey), entry.Caller.Line),
+			zap.String(string(semconv.CodeFunctionKey), entry.Caller.Function),
+		)
+	}
+	return c.Core.Write(entry, fields)
+}
+
+// levelCore only enables the levels that level enables
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.level.Enabled(entry.Level) {
+		return c.Core.Check(entry, checked)
+	}
+	return checked
+}
+
+// fieldMappingCore rewrites zap fields into richer forms before they are converted to attributes
+type fieldMappingCore struct {
+	zapcore.Core
+}
+
+func (c *fieldMappingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &fieldMappingCore{Core: c.Core.With(mapZapFields(fields))}
+}
+
+func (c *fieldMappingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *fieldMappingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, mapZapFields(fields))
+}
+
+// mapZapFields returns fields with durations, times, and the error field rewritten
+func mapZapFields(fields []zapcore.Field) []zapcore.Field {
+	mapped := make([]zapcore.Field, 0, len(fields))
+	for _, field := range fields {
+		switch field.Type {
+		case zapcore.DurationType:
+			mapped = append(mapped, zap.String(field.Key, time.Duration(field.Integer).String()))
+		case zapcore.TimeType, zapcore.TimeFullType:
+			var t time.Time
+			if field.Type == zapcore.TimeFullType {
+				t = field.Interface.(time.Time)
+			} else {
+				t = time.Unix(0, field.Integer)
+				if loc, ok := field.Interface.(*time.Location); ok {
+					t = t.In(loc)
+				}
+			}
+			mapped = append(mapped, zap.String(field.Key, t.Format(time.RFC3339Nano)))
+		case zapcore.ErrorType:
+			err, ok := field.Interface.(error)
+			if !ok || field.Key != "error" {
+				mapped = append(mapped, field)
+				continue
+			}
+			message := err.Error()
+			mapped = append(mapped,
+				zap.String(string(semconv.ExceptionMessageKey), message),
+				zap.String(string(semconv.ExceptionTypeKey), fmt.Sprintf("%T", err)),
+			)
+			if formatter, ok := err.(fmt.Formatter); ok {
+				if verbose := fmt.Sprintf("%+v", formatter); verbose != message {
+					mapped = append(mapped, zap.String(string(semconv.ExceptionStacktraceKey), verbose))
+				}
+			}
+		default:
+			mapped = append(mapped, field)
+		}
+	}
+	return mapped
+}
@@ -0,0 +1,161 @@
+package iudex
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Fields carrying the span context in logs written outside of OTel, e.g. to stdout
+const (
+	TraceIDField    = "trace_id"
+	SpanIDField     = "span_id"
+	TraceFlagsField = "trace_flags"
+)
+
+// loggerKey is the context key of the logger stored by ContextWithLogger
+type loggerKey struct{}
+
+// defaultLogger is returned by LoggerFromContext when no logger was stored in the context
+var defaultLogger = sync.OnceValue(func() *slog.Logger {
+	return NewSlogLogger(tracerName)
+})
+
+// ContextWithLogger returns a copy of ctx that LoggerFromContext returns logger from
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger stored in ctx by ContextWithLogger, or a logger created by
+// NewSlogLogger, bound to ctx. Records logged without a context, e.g. with logger.Info, are
+// correlated with the span in ctx, so code that receives a context but calls plain logging
+// methods still has its logs linked to the trace.
+//
+//	logger := iudex.LoggerFromContext(ctx)
+//	logger.Info("charging card", "order.id", order.ID)
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	if !ok {
+		logger = defaultLogger()
+	}
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		return logger
+	}
+	handler := logger.Handler()
+	if bound, ok := handler.(*contextHandler); ok {
+		handler = bound.Handler
+	}
+	return slog.New(&contextHandler{Handler: handler, ctx: ctx})
+}
+
+// contextHandler handles records logged without a span in their context with its own context
+type contextHandler struct {
+	slog.Handler
+	ctx context.Context
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.Handler.Enabled(h.context(ctx), level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.Handler.Handle(h.context(ctx), record)
+}
+
+func (h *contextHandler) context(ctx context.Context) context.Context {
+	if ctx == nil || !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		return h.ctx
+	}
+	return ctx
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs), ctx: h.ctx}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name), ctx: h.ctx}
+}
+
+// traceHandler adds the span context of each record's context to its attributes, outside of any
+// group, for handlers that write logs outside of OTel
+type traceHandler struct {
+	rootAttrsHandler
+}
+
+func newTraceHandler(handler slog.Handler) *traceHandler {
+	return &traceHandler{rootAttrsHandler: newRootAttrsHandler(handler)}
+}
+
+func (h *traceHandler) Handle(ctx context.Context, record slog.Record) error {
+	spanContext := oteltrace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return h.Handler.Handle(ctx, record)
+	}
+	return h.handle(ctx, record,
+		slog.String(TraceIDField, spanContext.TraceID().String()),
+		slog.String(SpanIDField, spanContext.SpanID().String()),
+		slog.String(TraceFlagsField, spanContext.TraceFlags().String()),
+	)
+}
+
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{rootAttrsHandler: h.withAttrs(attrs)}
+}
+
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{rootAttrsHandler: h.withGroup(name)}
+}
+
+// traceCore replaces context fields, which otelzap correlates records with, by the span context
+// they carry, for cores that write logs outside of OTel
+type traceCore struct {
+	zapcore.Core
+}
+
+func (c *traceCore) With(fields []zapcore.Field) zapcore.Core {
+	return &traceCore{Core: c.Core.With(traceFields(fields))}
+}
+
+func (c *traceCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *traceCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, traceFields(fields))
+}
+
+// traceFields returns fields with context fields replaced by trace_id, span_id, and trace_flags
+func traceFields(fields []zapcore.Field) []zapcore.Field {
+	var replaced []zapcore.Field
+	for i, field := range fields {
+		ctx, ok := field.Interface.(context.Context)
+		if !ok {
+			if replaced != nil {
+				replaced = append(replaced, field)
+			}
+			continue
+		}
+		if replaced == nil {
+			replaced = append(make([]zapcore.Field, 0, len(fields)+2), fields[:i]...)
+		}
+		if spanContext := oteltrace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			replaced = append(replaced,
+				zap.String(TraceIDField, spanContext.TraceID().String()),
+				zap.String(SpanIDField, spanContext.SpanID().String()),
+				zap.String(TraceFlagsField, spanContext.TraceFlags().String()),
+			)
+		}
+	}
+	if replaced == nil {
+		return fields
+	}
+	return replaced
+}
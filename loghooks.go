@@ -0,0 +1,35 @@
+package iudex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogProcessorFunc inspects a log record before export. It may modify the record in place, e.g. to
+// add a region or hash a user ID, and returns false to drop it, e.g. for health check noise.
+type LogProcessorFunc func(ctx context.Context, record *log.Record) bool
+
+// logHookProcessor runs hooks on each record before handing the kept ones to the next processor
+type logHookProcessor struct {
+	log.Processor
+	hooks []LogProcessorFunc
+}
+
+// NewLogHookProcessor wraps next so hooks run, in order, on every record before it. A record
+// dropped by a hook is not passed to the hooks after it.
+func NewLogHookProcessor(next log.Processor, hooks ...LogProcessorFunc) log.Processor {
+	if len(hooks) == 0 {
+		return next
+	}
+	return &logHookProcessor{Processor: next, hooks: hooks}
+}
+
+func (p *logHookProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	for _, hook := range p.hooks {
+		if !hook(ctx, record) {
+			return nil
+		}
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
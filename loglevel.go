@@ -0,0 +1,88 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// minLogSeverity is the lowest severity exported, shared by every logger provider set up by SetupOTelSDK
+var minLogSeverity atomic.Int64
+
+// SetLogLevel changes the lowest severity of exported log records at runtime, e.g. to turn debug
+// logs on while investigating an incident. otellog.SeverityUndefined exports everything.
+func SetLogLevel(severity otellog.Severity) {
+	minLogSeverity.Store(int64(severity))
+}
+
+// LogLevel returns the lowest severity of exported log records
+func LogLevel() otellog.Severity {
+	return otellog.Severity(minLogSeverity.Load())
+}
+
+// ParseLogLevel parses a level name (trace, debug, info, warn, error, or fatal) as a severity
+func ParseLogLevel(level string) (otellog.Severity, error) {
+	switch strings.ToLower(level) {
+	case "trace":
+		return otellog.SeverityTrace, nil
+	case "debug":
+		return otellog.SeverityDebug, nil
+	case "info":
+		return otellog.SeverityInfo, nil
+	case "warn", "warning":
+		return otellog.SeverityWarn, nil
+	case "error":
+		return otellog.SeverityError, nil
+	case "fatal":
+		return otellog.SeverityFatal, nil
+	default:
+		return otellog.SeverityUndefined, fmt.Errorf("unsupported log level %q", level)
+	}
+}
+
+// severityFilterProcessor drops records below the current log level before they reach the next processor
+type severityFilterProcessor struct {
+	log.Processor
+}
+
+// newSeverityFilterProcessor wraps next so it only sees records at or above LogLevel.
+// Records without a severity are always passed on.
+func newSeverityFilterProcessor(next log.Processor) log.Processor {
+	return &severityFilterProcessor{Processor: next}
+}
+
+func (p *severityFilterProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if !p.enabled(record.Severity()) && !keepsRecords(ctx) {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+// Enabled lets the bridges skip building records that would be dropped. The SDK only asks when
+// every processor of the provider is a filter, so this must stay its only processor.
+func (p *severityFilterProcessor) Enabled(ctx context.Context, record otellog.Record) bool {
+	return p.enabled(record.Severity()) || keepsRecords(ctx)
+}
+
+func (p *severityFilterProcessor) enabled(severity otellog.Severity) bool {
+	return severity == otellog.SeverityUndefined || severity >= LogLevel()
+}
+
+// keepRecordsKey marks a context whose records bypass the log level, log sampling, and tenant limits
+type keepRecordsKey struct{}
+
+// withKeptRecords returns ctx with records that every filter keeps, for SDK events such as
+// heartbeats that must arrive whatever the log settings are
+func withKeptRecords(ctx context.Context) context.Context {
+	return context.WithValue(ctx, keepRecordsKey{}, true)
+}
+
+// keepsRecords reports whether records emitted with ctx bypass the filters
+func keepsRecords(ctx context.Context) bool {
+	keep, _ := ctx.Value(keepRecordsKey{}).(bool)
+	return keep
+}
@@ -0,0 +1,108 @@
+package iudex
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// collectingLogProcessor keeps a copy of every record it sees
+type collectingLogProcessor struct {
+	mu      sync.Mutex
+	records []log.Record
+}
+
+func (p *collectingLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, record.Clone())
+	return nil
+}
+
+func (p *collectingLogProcessor) Shutdown(context.Context) error   { return nil }
+func (p *collectingLogProcessor) ForceFlush(context.Context) error { return nil }
+
+func (p *collectingLogProcessor) bodies() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bodies := make([]string, len(p.records))
+	for i, record := range p.records {
+		bodies[i] = record.Body().AsString()
+	}
+	return bodies
+}
+
+// setLogLevel changes the log level until the test ends
+func setLogLevel(t *testing.T, severity otellog.Severity) {
+	t.Helper()
+	prev := LogLevel()
+	SetLogLevel(severity)
+	t.Cleanup(func() { SetLogLevel(prev) })
+}
+
+func newTestRecord(severity otellog.Severity, body string) otellog.Record {
+	var record otellog.Record
+	record.SetSeverity(severity)
+	record.SetBody(otellog.StringValue(body))
+	return record
+}
+
+func TestSeverityFilterProcessor(t *testing.T) {
+	setLogLevel(t, otellog.SeverityInfo)
+	collector := &collectingLogProcessor{}
+	provider := log.NewLoggerProvider(log.WithProcessor(newSeverityFilterProcessor(collector)))
+	logger := provider.Logger("test")
+
+	ctx := context.Background()
+	logger.Emit(ctx, newTestRecord(otellog.SeverityDebug, "debug"))
+	logger.Emit(ctx, newTestRecord(otellog.SeverityInfo, "info"))
+	logger.Emit(ctx, newTestRecord(otellog.SeverityError, "error"))
+	logger.Emit(ctx, newTestRecord(otellog.SeverityUndefined, "undefined"))
+	logger.Emit(withKeptRecords(ctx), newTestRecord(otellog.SeverityDebug, "kept"))
+
+	got := collector.bodies()
+	want := []string{"info", "error", "undefined", "kept"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSeverityFilterProcessorLevelChange(t *testing.T) {
+	setLogLevel(t, otellog.SeverityError)
+	collector := &collectingLogProcessor{}
+	provider := log.NewLoggerProvider(log.WithProcessor(newSeverityFilterProcessor(collector)))
+	logger := provider.Logger("test")
+
+	logger.Emit(context.Background(), newTestRecord(otellog.SeverityWarn, "before"))
+	SetLogLevel(otellog.SeverityWarn)
+	logger.Emit(context.Background(), newTestRecord(otellog.SeverityWarn, "after"))
+
+	if got := collector.bodies(); len(got) != 1 || got[0] != "after" {
+		t.Fatalf("got %v, want only the record after the level change", got)
+	}
+}
+
+func TestSeverityFilterProcessorEnabled(t *testing.T) {
+	setLogLevel(t, otellog.SeverityInfo)
+	provider := log.NewLoggerProvider(log.WithProcessor(newSeverityFilterProcessor(&collectingLogProcessor{})))
+	logger := provider.Logger("test")
+
+	ctx := context.Background()
+	if logger.Enabled(ctx, newTestRecord(otellog.SeverityDebug, "")) {
+		t.Error("debug records are enabled below the log level")
+	}
+	if !logger.Enabled(ctx, newTestRecord(otellog.SeverityWarn, "")) {
+		t.Error("warn records are disabled above the log level")
+	}
+	if !logger.Enabled(withKeptRecords(ctx), newTestRecord(otellog.SeverityDebug, "")) {
+		t.Error("kept records are disabled")
+	}
+}
@@ -0,0 +1,72 @@
+package iudex
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// LogrusHook forwards logrus entries to the global LoggerProvider
+type LogrusHook struct {
+	logger otellog.Logger
+	levels []logrus.Level
+}
+
+// NewLogrusHook creates a logrus hook that sends entries as OTel log records.
+// Add it to a logger with logger.AddHook(iudex.NewLogrusHook("main")).
+func NewLogrusHook(name string) *LogrusHook {
+	return &LogrusHook{
+		logger: GetLoggerProvider().Logger(name),
+		levels: logrus.AllLevels,
+	}
+}
+
+// Levels returns the logrus levels the hook fires for
+func (h *LogrusHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire converts the logrus entry to an OTel log record and emits it
+func (h *LogrusHook) Fire(entry *logrus.Entry) error {
+	record := otellog.Record{}
+	record.SetTimestamp(entry.Time)
+	record.SetBody(otellog.StringValue(entry.Message))
+	record.SetSeverity(logrusSeverity(entry.Level))
+	record.SetSeverityText(entry.Level.String())
+
+	attrs := make([]otellog.KeyValue, 0, len(entry.Data))
+	for key, value := range entry.Data {
+		attrs = append(attrs, otellog.KeyValue{Key: key, Value: toLogValue(value)})
+	}
+	record.AddAttributes(attrs...)
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h.logger.Emit(ctx, record)
+	return nil
+}
+
+// logrusSeverity maps logrus levels to OTel severities
+func logrusSeverity(level logrus.Level) otellog.Severity {
+	switch level {
+	case logrus.TraceLevel:
+		return otellog.SeverityTrace
+	case logrus.DebugLevel:
+		return otellog.SeverityDebug
+	case logrus.InfoLevel:
+		return otellog.SeverityInfo
+	case logrus.WarnLevel:
+		return otellog.SeverityWarn
+	case logrus.ErrorLevel:
+		return otellog.SeverityError
+	case logrus.FatalLevel:
+		return otellog.SeverityFatal
+	case logrus.PanicLevel:
+		return otellog.SeverityFatal4
+	default:
+		return otellog.SeverityUndefined
+	}
+}
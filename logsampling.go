@@ -0,0 +1,137 @@
+package iudex
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogSampledDroppedKey counts the records with the same message dropped by sampling since the previous kept one
+const LogSampledDroppedKey = "log.sampled.dropped"
+
+// LogSamplingConfig keeps repetitive log records in check. Within each Interval, the first First
+// records with the same severity and message are kept, then every Thereafter-th one.
+// Zero values default to 1s, 10 and 100.
+type LogSamplingConfig struct {
+	Interval   time.Duration
+	First      int
+	Thereafter int
+}
+
+// logSampleKey identifies a message template; for the bridges the body is the unformatted message
+type logSampleKey struct {
+	severity otellog.Severity
+	body     string
+}
+
+type logSampleCounter struct {
+	seen    int
+	dropped int
+}
+
+// logSamplingProcessor drops repeated records before they reach the next processor
+type logSamplingProcessor struct {
+	log.Processor
+	config LogSamplingConfig
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counters    map[logSampleKey]*logSampleCounter
+}
+
+// NewLogSamplingProcessor wraps next so it only sees a sample of repetitive records. Kept records
+// that follow dropped ones carry the number dropped in LogSampledDroppedKey.
+func NewLogSamplingProcessor(next log.Processor, config LogSamplingConfig) log.Processor {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.First <= 0 {
+		config.First = 10
+	}
+	if config.Thereafter <= 0 {
+		config.Thereafter = 100
+	}
+	return &logSamplingProcessor{Processor: next, config: config, counters: map[logSampleKey]*logSampleCounter{}}
+}
+
+func (p *logSamplingProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if keepsRecords(ctx) {
+		return p.Processor.OnEmit(ctx, record)
+	}
+	dropped, keep := p.sample(logSampleKey{severity: record.Severity(), body: record.Body().String()})
+	if !keep {
+		return nil
+	}
+	if dropped > 0 {
+		record.AddAttributes(otellog.Int(LogSampledDroppedKey, dropped))
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+// sample counts the record and reports whether to keep it, and how many were dropped before it
+func (p *logSamplingProcessor) sample(key logSampleKey) (dropped int, keep bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if now := time.Now(); now.Sub(p.windowStart) >= p.config.Interval {
+		// Start a new window, carrying over drop counts not reported yet
+		counters := map[logSampleKey]*logSampleCounter{}
+		for k, c := range p.counters {
+			if c.dropped > 0 {
+				counters[k] = &logSampleCounter{dropped: c.dropped}
+			}
+		}
+		p.counters = counters
+		p.windowStart = now
+	}
+
+	c, ok := p.counters[key]
+	if !ok {
+		c = &logSampleCounter{}
+		p.counters[key] = c
+	}
+	c.seen++
+	if c.seen > p.config.First && (c.seen-p.config.First)%p.config.Thereafter != 0 {
+		c.dropped++
+		return 0, false
+	}
+	dropped, c.dropped = c.dropped, 0
+	return dropped, true
+}
+
+// fanoutLogProcessor hands every record to each of its processors
+type fanoutLogProcessor struct {
+	processors []log.Processor
+}
+
+func newFanoutLogProcessor(processors ...log.Processor) log.Processor {
+	return &fanoutLogProcessor{processors: processors}
+}
+
+func (p *fanoutLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.OnEmit(ctx, record))
+	}
+	return err
+}
+
+func (p *fanoutLogProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.Shutdown(ctx))
+	}
+	return err
+}
+
+func (p *fanoutLogProcessor) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.ForceFlush(ctx))
+	}
+	return err
+}
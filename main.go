@@ -5,22 +5,30 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	internalLog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
+	lognoop "go.opentelemetry.io/otel/log/noop"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // InstrumentationConfig holds configuration for instrumentation
@@ -30,24 +38,153 @@ type InstrumentationConfig struct {
 	APIKey       *string
 	PublicAPIKey *string
 	Headers      *map[string]string
+	Protocol     *string
+	Insecure     *bool
+	Gzip         *bool // compress payloads, HTTP exporters only
+
+	// Proxy Configuration, HTTP exporters only. HTTPS_PROXY is honored when neither is set.
+	ProxyURL *string
+	Proxy    func(*http.Request) (*url.URL, error)
+
+	// Propagators names the context propagation formats, e.g. PropagatorB3. Defaults to W3C trace context and baggage.
+	Propagators *[]string
+	// XRay generates X-Ray compatible trace IDs and adds the X-Ray propagator
+	XRay *bool
+
+	// Debug prints telemetry to stdout instead of exporting it to IUDEX
+	Debug *bool
+	// Disabled installs noop providers and creates no exporters
+	Disabled *bool
+
+	// Sampling Configuration
+	Sampler          *string
+	SamplerRatio     *float64
+	SamplerRateLimit *float64
+	CustomSampler    trace.Sampler
+	RemoteSampling   *RemoteSamplingConfig
+	TailSampling     *TailSamplingConfig
+
+	// TenantLimits caps the spans and log records of each tenant set with WithTenant
+	TenantLimits *TenantLimitConfig
+
+	// Baggage members copied onto spans and log records, DefaultBaggageKeys when nil
+	BaggageKeys *[]string
+	// SendUserEmail sends the emails given to SetUser as user.email instead of hashing them
+	SendUserEmail *bool
+	// UserHashSecret keys the HMAC of the emails given to SetUser. Without it, emails are left out
+	// unless SendUserEmail is set.
+	UserHashSecret *string
+
+	// GlobalAttributes are added to every span and log record. SetGlobalAttribute changes them at runtime.
+	GlobalAttributes []Attribute
+
+	// SpanProcessors are registered in front of the export pipeline, after the built-in enrichment
+	SpanProcessors []trace.SpanProcessor
+
+	// ErrorHandler reports errors of the OpenTelemetry SDK, rate limited through slog.Default when nil.
+	// A nil ErrorHandler keeps an error handler the application already set with otel.SetErrorHandler.
+	ErrorHandler *ErrorHandlerConfig
+
+	// SpanStatus sets the status of HTTP server spans, DefaultSpanStatus when nil. SetSpanStatusFunc changes it at runtime.
+	SpanStatus SpanStatusFunc
+
+	// AttributeLimits truncates oversized spans and log records, the OpenTelemetry defaults when nil
+	AttributeLimits *AttributeLimits
+	// TruncateValues truncates string values longer than this many bytes, keeping a hash of the whole value
+	TruncateValues *int
+	// CardinalityGuard normalizes span names and attribute keys once there are too many distinct ones
+	CardinalityGuard *CardinalityGuardConfig
+
+	// Redaction Configuration
+	RedactionRules  []RedactionRule
+	AttributeFilter *AttributeFilter
+
+	// Additional OTLP endpoints that receive the same telemetry
+	ExportDestinations []ExportDestination
+
+	// Retry Configuration, the OpenTelemetry defaults when nil
+	TraceRetry *RetryConfig
+	LogRetry   *RetryConfig
+
+	// CircuitBreaker stops exporting to IUDEX after repeated failures and diverts telemetry to a fallback
+	CircuitBreaker *CircuitBreakerConfig
+
+	// DiskBuffer spools failed span and log exports to disk and replays them later
+	DiskBuffer *DiskBufferConfig
+
+	// Batching Configuration
+	Serverless *bool // export synchronously instead of batching
+	TraceBatch *BatchConfig
+	LogBatch   *BatchConfig
+
+	// Logs Configuration, the lowest severity exported. SetLogLevel changes it at runtime.
+	LogLevel    *internalLog.Severity
+	LogSampling *LogSamplingConfig
+	// LogHooks modify or drop log records before export, before redaction and attribute filtering
+	LogHooks []LogProcessorFunc
+
+	// Metrics Configuration
+	MetricInterval *time.Duration
+	SpanMetrics    *bool // derive request, error, and duration metrics from spans
+	TenantMetrics  *bool // add tenant.id to span and HTTP server metrics, one series per tenant
+	SelfTelemetry  *bool // report Stats as iudex.sdk.* metrics
+
+	// Profiling captures CPU and heap profiles periodically and uploads them
+	Profiling *ProfilingConfig
+	// ProfileTriggers polls IUDEX for on-demand profiles requested during incidents
+	ProfileTriggers *bool
+
+	// Heartbeat emits a periodic event and uptime metric so instances that died are noticed without traffic
+	Heartbeat *HeartbeatConfig
+
+	// ConfigWatcher reloads sampling, the log level, and redaction rules from a file or IUDEX at runtime
+	ConfigWatcher *ConfigWatcherConfig
 
 	// Attributes Configuration
-	ServiceName *string
-	InstanceID  *string
-	Env         *string
-	GitCommit   *string
-	GitHubURL   *string
+	ServiceName        *string
+	InstanceID         *string
+	Env                *string
+	GitCommit          *string
+	GitHubURL          *string
+	ResourceAttributes *map[string]string
+	ResourceDetectors  []resource.Detector
+	HostAttributes     *bool // host.name, os.type, process.pid, process.runtime.version, process.command_args
+	BuildInfo          *bool // service.version, go.module.path, and vcs.* from the build info of the binary
 }
 
+// invalidEnvSampler reports an unsupported OTEL_TRACES_SAMPLER once, however often the
+// defaults are computed
+var invalidEnvSampler sync.Once
+
 // getDefaultConfig generates the default configuration values
 func GetDefaultConfig() InstrumentationConfig {
-	defaultBaseURL := GetEnv("BASE_URL", nil)
+	defaultBaseURL := GetEnv("BASE_URL", GetEnv("OTEL_EXPORTER_OTLP_ENDPOINT", nil))
 	if defaultBaseURL == nil {
 		defaultBaseURL = StringPtr("api.iudex.ai")
 	}
+	defaultProtocol := GetEnv("PROTOCOL", getOTelProtocol())
+	if defaultProtocol == nil {
+		defaultProtocol = StringPtr(ProtocolHTTP)
+	}
+	defaultInsecure := getEnvBool("OTEL_EXPORTER_OTLP_INSECURE")
+	defaultGzip := getOTelCompression()
+	if defaultGzip == nil {
+		defaultGzip = BoolPtr(false)
+	}
+	defaultHeaders := getOTelKeyValues("OTEL_EXPORTER_OTLP_HEADERS")
+	defaultPropagators := getEnvList("OTEL_PROPAGATORS")
+	defaultXRay := getEnvBool("IUDEX_XRAY")
+	defaultDebug := BoolPtr(false)
+	if debug := GetEnv("IUDEX_DEBUG", nil); debug != nil {
+		defaultDebug = BoolPtr(*debug == "true" || *debug == "1")
+	}
+	defaultDisabled := getEnvBool("IUDEX_DISABLED")
+	if defaultDisabled == nil {
+		defaultDisabled = BoolPtr(false)
+	}
 	defaultAPIKey := GetEnv("API_KEY", nil)
 	defaultPublicAPIKey := GetEnv("PUBLIC_API_KEY", nil)
-	defaultServiceName := GetEnv("SERVICE_NAME", nil)
+	defaultServiceName := GetEnv("SERVICE_NAME", GetEnv("OTEL_SERVICE_NAME", nil))
 	if defaultServiceName == nil {
 		defaultServiceName = StringPtr("default-service")
 	}
@@ -57,15 +194,110 @@ func GetDefaultConfig() InstrumentationConfig {
 		defaultEnv = StringPtr("development")
 	}
 	defaultGitCommit := GetEnv("GIT_COMMIT", nil)
+	defaultResourceAttributes := getOTelKeyValues("OTEL_RESOURCE_ATTRIBUTES")
+	defaultHostAttributes := getEnvBool("IUDEX_HOST_ATTRIBUTES")
+	if defaultHostAttributes == nil {
+		defaultHostAttributes = BoolPtr(true)
+	}
+	defaultBuildInfo := getEnvBool("IUDEX_BUILD_INFO")
+	if defaultBuildInfo == nil {
+		defaultBuildInfo = BoolPtr(true)
+	}
+	var defaultDiskBuffer *DiskBufferConfig
+	if dir := GetEnv("IUDEX_DISK_BUFFER_DIR", nil); dir != nil {
+		defaultDiskBuffer = &DiskBufferConfig{Dir: *dir}
+	}
+	var defaultProfiling *ProfilingConfig
+	if profiling := getEnvBool("IUDEX_PROFILING"); profiling != nil && *profiling {
+		defaultProfiling = &ProfilingConfig{}
+	}
+	var defaultHeartbeat *HeartbeatConfig
+	if heartbeat := getEnvBool("IUDEX_HEARTBEAT"); heartbeat != nil && *heartbeat {
+		defaultHeartbeat = &HeartbeatConfig{}
+	}
+	defaultProfileTriggers := getEnvBool("IUDEX_PROFILE_TRIGGERS")
+	if defaultProfileTriggers == nil {
+		defaultProfileTriggers = BoolPtr(false)
+	}
+	defaultBaggageKeys := append([]string(nil), DefaultBaggageKeys...)
+	defaultSendUserEmail := getEnvBool("IUDEX_SEND_USER_EMAIL")
+	if defaultSendUserEmail == nil {
+		defaultSendUserEmail = BoolPtr(false)
+	}
+	defaultUserHashSecret := GetEnv("IUDEX_USER_HASH_SECRET", nil)
+	defaultSampler := GetEnv("OTEL_TRACES_SAMPLER", nil)
+	var defaultSamplerRatio, defaultSamplerRateLimit *float64
+	if defaultSampler != nil && *defaultSampler == SamplerRateLimited {
+		defaultSamplerRateLimit = getEnvFloat("OTEL_TRACES_SAMPLER_ARG")
+	} else {
+		defaultSamplerRatio = getEnvFloat("OTEL_TRACES_SAMPLER_ARG")
+	}
+	// Like the other OTel SDKs, an unknown sampler falls back to the default one
+	if defaultSampler != nil {
+		envSampler := InstrumentationConfig{Sampler: defaultSampler, SamplerRatio: defaultSamplerRatio, SamplerRateLimit: defaultSamplerRateLimit}
+		if _, err := NewSampler(envSampler); err != nil {
+			invalidEnvSampler.Do(func() {
+				otel.Handle(fmt.Errorf("OTEL_TRACES_SAMPLER: %w, using the default sampler", err))
+			})
+			defaultSampler, defaultSamplerRatio, defaultSamplerRateLimit = nil, nil, nil
+		}
+	}
+	_, onLambda := os.LookupEnv("AWS_LAMBDA_FUNCTION_NAME")
+	defaultServerless := BoolPtr(onLambda)
+	var defaultLogLevel *internalLog.Severity
+	if level := GetEnv("IUDEX_LOG_LEVEL", nil); level != nil {
+		if severity, err := ParseLogLevel(*level); err == nil {
+			defaultLogLevel = &severity
+		}
+	}
+	defaultSpanMetrics := getEnvBool("IUDEX_SPAN_METRICS")
+	if defaultSpanMetrics == nil {
+		defaultSpanMetrics = BoolPtr(false)
+	}
+	defaultSelfTelemetry := getEnvBool("IUDEX_SELF_TELEMETRY")
+	if defaultSelfTelemetry == nil {
+		defaultSelfTelemetry = BoolPtr(false)
+	}
+	defaultMetricInterval := getEnvMillis("OTEL_METRIC_EXPORT_INTERVAL")
+	if defaultMetricInterval == nil {
+		defaultMetricInterval = DurationPtr(time.Minute)
+	}
 
 	return InstrumentationConfig{
 		BaseURL:      defaultBaseURL,
+		Protocol:     defaultProtocol,
+		Insecure:     defaultInsecure,
+		Gzip:         defaultGzip,
+		Headers:      defaultHeaders,
+		Propagators:  defaultPropagators,
+		XRay:         defaultXRay,
+		Debug:        defaultDebug,
+		Disabled:     defaultDisabled,
 		APIKey:       defaultAPIKey,
 		PublicAPIKey: defaultPublicAPIKey,
 		ServiceName:  defaultServiceName,
 		InstanceID:   defaultInstanceID,
 		Env:          defaultEnv,
 		GitCommit:    defaultGitCommit,
+
+		ResourceAttributes: defaultResourceAttributes,
+		HostAttributes:     defaultHostAttributes,
+		BuildInfo:          defaultBuildInfo,
+		Sampler:            defaultSampler,
+		SamplerRatio:       defaultSamplerRatio,
+		SamplerRateLimit:   defaultSamplerRateLimit,
+		Serverless:         defaultServerless,
+		DiskBuffer:         defaultDiskBuffer,
+		Profiling:          defaultProfiling,
+		ProfileTriggers:    defaultProfileTriggers,
+		Heartbeat:          defaultHeartbeat,
+		BaggageKeys:        &defaultBaggageKeys,
+		SendUserEmail:      defaultSendUserEmail,
+		UserHashSecret:     defaultUserHashSecret,
+		LogLevel:           defaultLogLevel,
+		MetricInterval:     defaultMetricInterval,
+		SpanMetrics:        defaultSpanMetrics,
+		SelfTelemetry:      defaultSelfTelemetry,
 	}
 }
 
@@ -82,6 +314,21 @@ func StringPtr(s string) *string {
 	return &s
 }
 
+// BoolPtr returns a pointer to the given bool
+func BoolPtr(b bool) *bool {
+	return &b
+}
+
+// Float64Ptr returns a pointer to the given float64
+func Float64Ptr(f float64) *float64 {
+	return &f
+}
+
+// DurationPtr returns a pointer to the given duration
+func DurationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
 // If it does not return an error, make sure to call shutdown for proper cleanup.
 func SetupOTelSDK(ctx context.Context, config InstrumentationConfig) (shutdown func(context.Context) error, err error) {
@@ -105,26 +352,34 @@ func SetupOTelSDK(ctx context.Context, config InstrumentationConfig) (shutdown f
 	}
 
 	// Set default values if not provided
-	defaults := GetDefaultConfig()
-	if config.ServiceName == nil {
-		config.ServiceName = defaults.ServiceName
-	}
-	if config.InstanceID == nil {
-		config.InstanceID = defaults.InstanceID
+	config = applyDefaults(config)
+
+	// Report SDK errors, e.g. failed exports, rate limited rather than printed on every failure
+	installErrorHandler(config.ErrorHandler)
+
+	// Set up propagator.
+	otel.SetTextMapPropagator(NewPropagatorFromConfig(config))
+
+	if config.SpanStatus != nil {
+		SetSpanStatusFunc(config.SpanStatus)
 	}
-	if config.Env == nil {
-		config.Env = defaults.Env
+	if len(config.GlobalAttributes) > 0 {
+		SetGlobalAttributes(config.GlobalAttributes...)
 	}
-	if config.GitCommit == nil {
-		config.GitCommit = defaults.GitCommit
-	}
-	if config.BaseURL == nil {
-		config.BaseURL = defaults.BaseURL
+	sendUserEmail.Store(config.SendUserEmail != nil && *config.SendUserEmail)
+	if config.UserHashSecret != nil {
+		setUserHashSecret(*config.UserHashSecret)
+	} else {
+		setUserHashSecret("")
 	}
 
-	// Set up propagator.
-	prop := NewPropagator()
-	otel.SetTextMapPropagator(prop)
+	// Disabled mode installs noop providers. Context still propagates through the service.
+	if config.Disabled != nil && *config.Disabled {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		global.SetLoggerProvider(lognoop.NewLoggerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+		return
+	}
 
 	// Set up resource.
 	res, err := NewResource(ctx, config)
@@ -133,11 +388,14 @@ func SetupOTelSDK(ctx context.Context, config InstrumentationConfig) (shutdown f
 		return
 	}
 
-	// Set up headers.
-	headers, err := NewHeaders(config)
-	if err != nil {
-		handleErr(err)
-		return
+	// Set up headers. Debug mode prints telemetry locally and needs no API key.
+	headers := &map[string]string{}
+	if !isDebug(config) {
+		headers, err = NewHeaders(config)
+		if err != nil {
+			handleErr(err)
+			return
+		}
 	}
 
 	// Set up trace provider.
@@ -158,9 +416,163 @@ func SetupOTelSDK(ctx context.Context, config InstrumentationConfig) (shutdown f
 	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
 	global.SetLoggerProvider(loggerProvider)
 
+	// Set up meter provider.
+	meterProvider, err := NewMeterProvider(ctx, config, res, headers)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+	otel.SetMeterProvider(meterProvider)
+	if config.SelfTelemetry != nil && *config.SelfTelemetry {
+		if err = registerSelfTelemetry(meterProvider.Meter(tracerName)); err != nil {
+			handleErr(err)
+			return
+		}
+	}
+
+	// Set up profiling.
+	stopProfiling, err := setupProfiling(config, res, headers)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	if stopProfiling != nil {
+		shutdownFuncs = append(shutdownFuncs, stopProfiling)
+	}
+
+	// Set up the heartbeat.
+	stopHeartbeat, err := setupHeartbeat(config, res, meterProvider.Meter(tracerName))
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	if stopHeartbeat != nil {
+		shutdownFuncs = append(shutdownFuncs, stopHeartbeat)
+	}
+
+	// Set up remote sampling.
+	stopRemoteSampling, err := setupRemoteSampling(config, res, headers)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	if stopRemoteSampling != nil {
+		shutdownFuncs = append(shutdownFuncs, stopRemoteSampling)
+	}
+
+	// Set up config reloading.
+	stopConfigWatcher, err := setupConfigWatcher(config, res, headers)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+	if stopConfigWatcher != nil {
+		shutdownFuncs = append(shutdownFuncs, stopConfigWatcher)
+	}
+
 	return
 }
 
+// applyDefaults fills the fields that are not set with the environment defaults
+func applyDefaults(config InstrumentationConfig) InstrumentationConfig {
+	defaults := GetDefaultConfig()
+	if config.ServiceName == nil {
+		config.ServiceName = defaults.ServiceName
+	}
+	if config.InstanceID == nil {
+		config.InstanceID = defaults.InstanceID
+	}
+	if config.Env == nil {
+		config.Env = defaults.Env
+	}
+	if config.GitCommit == nil {
+		config.GitCommit = defaults.GitCommit
+	}
+	if config.BaseURL == nil {
+		config.BaseURL = defaults.BaseURL
+	}
+	if config.Protocol == nil {
+		config.Protocol = defaults.Protocol
+	}
+	if config.Insecure == nil {
+		config.Insecure = defaults.Insecure
+	}
+	if config.Gzip == nil {
+		config.Gzip = defaults.Gzip
+	}
+	if config.Headers == nil {
+		config.Headers = defaults.Headers
+	}
+	if config.ResourceAttributes == nil {
+		config.ResourceAttributes = defaults.ResourceAttributes
+	}
+	if config.HostAttributes == nil {
+		config.HostAttributes = defaults.HostAttributes
+	}
+	if config.BuildInfo == nil {
+		config.BuildInfo = defaults.BuildInfo
+	}
+	if config.Sampler == nil {
+		config.Sampler = defaults.Sampler
+	}
+	if config.SamplerRatio == nil {
+		config.SamplerRatio = defaults.SamplerRatio
+	}
+	if config.SamplerRateLimit == nil {
+		config.SamplerRateLimit = defaults.SamplerRateLimit
+	}
+	if config.Debug == nil {
+		config.Debug = defaults.Debug
+	}
+	if config.Disabled == nil {
+		config.Disabled = defaults.Disabled
+	}
+	if config.Propagators == nil {
+		config.Propagators = defaults.Propagators
+	}
+	if config.XRay == nil {
+		config.XRay = defaults.XRay
+	}
+	if config.Serverless == nil {
+		config.Serverless = defaults.Serverless
+	}
+	if config.LogLevel == nil {
+		config.LogLevel = defaults.LogLevel
+	}
+	if config.MetricInterval == nil {
+		config.MetricInterval = defaults.MetricInterval
+	}
+	if config.SpanMetrics == nil {
+		config.SpanMetrics = defaults.SpanMetrics
+	}
+	if config.SelfTelemetry == nil {
+		config.SelfTelemetry = defaults.SelfTelemetry
+	}
+	if config.BaggageKeys == nil {
+		config.BaggageKeys = defaults.BaggageKeys
+	}
+	if config.SendUserEmail == nil {
+		config.SendUserEmail = defaults.SendUserEmail
+	}
+	if config.UserHashSecret == nil {
+		config.UserHashSecret = defaults.UserHashSecret
+	}
+	if config.DiskBuffer == nil {
+		config.DiskBuffer = defaults.DiskBuffer
+	}
+	if config.Profiling == nil {
+		config.Profiling = defaults.Profiling
+	}
+	if config.ProfileTriggers == nil {
+		config.ProfileTriggers = defaults.ProfileTriggers
+	}
+	if config.Heartbeat == nil {
+		config.Heartbeat = defaults.Heartbeat
+	}
+	return config
+}
+
 func NewPropagator() propagation.TextMapPropagator {
 	return propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
@@ -169,8 +581,11 @@ func NewPropagator() propagation.TextMapPropagator {
 }
 
 func NewResource(ctx context.Context, config InstrumentationConfig) (*resource.Resource, error) {
-	// Create resource with service information
+	// Create resource with service information. Build info comes first so configured values win.
 	attributes := []attribute.KeyValue{}
+	if config.BuildInfo != nil && *config.BuildInfo {
+		attributes = append(attributes, buildInfoAttributes(config)...)
+	}
 	if config.ServiceName != nil {
 		attributes = append(attributes, attribute.String("service.name", *config.ServiceName))
 	}
@@ -186,10 +601,35 @@ func NewResource(ctx context.Context, config InstrumentationConfig) (*resource.R
 	if config.GitHubURL != nil {
 		attributes = append(attributes, attribute.String("github.url", *config.GitHubURL))
 	}
+	if config.ResourceAttributes != nil {
+		for key, value := range *config.ResourceAttributes {
+			attributes = append(attributes, attribute.String(key, value))
+		}
+	}
 
-	res, err := resource.New(ctx, resource.WithAttributes(attributes...))
-	if err != nil {
+	// Detectors run first so the configured attributes take precedence
+	opts := []resource.Option{}
+	if config.HostAttributes != nil && *config.HostAttributes {
+		opts = append(opts,
+			resource.WithHost(),
+			resource.WithOSType(),
+			resource.WithProcessPID(),
+			resource.WithProcessRuntimeName(),
+			resource.WithProcessRuntimeVersion(),
+			resource.WithProcessCommandArgs(),
+		)
+	}
+	opts = append(opts,
+		resource.WithDetectors(config.ResourceDetectors...),
+		resource.WithAttributes(attributes...),
+	)
+
+	res, err := resource.New(ctx, opts...)
+	if err != nil && res == nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
+	} else if err != nil {
+		// A detector failed, keep what the others found
+		otel.Handle(err)
 	}
 
 	return res, nil
@@ -201,6 +641,11 @@ func NewHeaders(config InstrumentationConfig) (*map[string]string, error) {
 	}
 
 	headers := map[string]string{}
+	if config.Headers != nil {
+		for key, value := range *config.Headers {
+			headers[key] = value
+		}
+	}
 
 	if config.PublicAPIKey != nil {
 		headers["x-write-only-api-key"] = *config.PublicAPIKey
@@ -212,59 +657,274 @@ func NewHeaders(config InstrumentationConfig) (*map[string]string, error) {
 }
 
 func NewTraceProvider(ctx context.Context, config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (*trace.TracerProvider, error) {
-	baseURL := "api.iudex.ai"
-	if config.BaseURL != nil {
-		baseURL = *config.BaseURL
+	sampler, err := NewSampler(config)
+	if err != nil {
+		return nil, err
 	}
+	SetSampler(sampler)
+	setSamplingConfig(config)
 
-	traceExporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint(baseURL),
-		otlptracehttp.WithHeaders(*headers),
-	)
+	traceExporter, err := newTraceExporter(ctx, config, headers)
+	if err != nil {
+		return nil, err
+	}
+	traceExporter, err = withTraceCircuitBreaker(ctx, config, traceExporter)
+	if err != nil {
+		return nil, err
+	}
+	traceExporter, err = withTraceDiskBuffer(config, headers, traceExporter)
+	if err != nil {
+		return nil, err
+	}
+	destinationExporters, err := destinationTraceExporters(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
-	traceProvider := trace.NewTracerProvider(
-		trace.WithBatcher(traceExporter,
-			trace.WithBatchTimeout(time.Second)),
+	activeTenantLimiter.Store(nil)
+	tenantMetrics.Store(config.TenantMetrics != nil && *config.TenantMetrics)
+	var providerSampler trace.Sampler = runtimeSampler{}
+	if config.TenantLimits != nil && config.TenantLimits.SpansPerSecond > 0 {
+		providerSampler = newTenantLimitSampler(providerSampler, config.TenantLimits.SpansPerSecond)
+	}
+	// Span metrics count the spans the head sampler drops as well, so they are recorded but not sampled
+	spanMetricsEnabled := config.SpanMetrics != nil && *config.SpanMetrics
+	if spanMetricsEnabled {
+		providerSampler = RecordingSampler(providerSampler)
+	}
+	providerOptions := []trace.TracerProviderOption{
 		trace.WithResource(res),
-	)
+		trace.WithSampler(providerSampler),
+	}
+	if isXRay(config) {
+		providerOptions = append(providerOptions, trace.WithIDGenerator(xray.NewIDGenerator()))
+	}
+	if config.AttributeLimits != nil {
+		providerOptions = append(providerOptions, trace.WithRawSpanLimits(config.AttributeLimits.spanLimits()))
+	}
+	providerOptions = append(providerOptions, trace.WithSpanProcessor(NewGlobalAttributeSpanProcessor()))
+	if config.BaggageKeys != nil && len(*config.BaggageKeys) > 0 {
+		providerOptions = append(providerOptions, trace.WithSpanProcessor(NewBaggageSpanProcessor(*config.BaggageKeys...)))
+	}
+	if config.SendUserEmail != nil && *config.SendUserEmail {
+		providerOptions = append(providerOptions, trace.WithSpanProcessor(userEmailSpanProcessor{}))
+	}
+	// Span metrics see every span, before head and tail sampling. The global meter provider
+	// is installed after this one and the meter picks it up once it is.
+	if spanMetricsEnabled {
+		keys := spanMetricAttributeKeys
+		if tenantMetrics.Load() {
+			keys = append(slices.Clip(keys), TenantIDKey)
+		}
+		spanMetrics, err := newSpanMetricsProcessor(otel.Meter(tracerName), keys)
+		if err != nil {
+			return nil, err
+		}
+		providerOptions = append(providerOptions, trace.WithSpanProcessor(spanMetrics))
+	}
+	// Custom processors see every span the head sampler keeps, before tail sampling and redaction
+	for _, processor := range config.SpanProcessors {
+		if spanMetricsEnabled {
+			processor = sampledSpanProcessor{SpanProcessor: processor}
+		}
+		providerOptions = append(providerOptions, trace.WithSpanProcessor(processor))
+	}
+	// Every exporter gets its own processor so destinations fail independently
+	var exportProcessors []trace.SpanProcessor
+	var queues []*exportQueue
+	for _, exp := range append([]trace.SpanExporter{traceExporter}, destinationExporters...) {
+		// Serverless runtimes can freeze between invocations, so export spans as soon as they end
+		if config.Serverless != nil && *config.Serverless {
+			exportProcessors = append(exportProcessors, trace.NewSimpleSpanProcessor(&countingSpanExporter{SpanExporter: exp}))
+		} else {
+			queue := newExportQueue(config.TraceBatch, &droppedSpans)
+			queues = append(queues, queue)
+			batch := trace.NewBatchSpanProcessor(&countingSpanExporter{SpanExporter: exp, queue: queue}, spanBatchOptions(config.TraceBatch)...)
+			exportProcessors = append(exportProcessors, &queueSpanProcessor{SpanProcessor: batch, queue: queue})
+		}
+	}
+	spanQueues.Store(&queues)
+
+	// Sampling, redaction, and filtering happen once per span, in front of all exporters
+	spanProcessor := newFanoutSpanProcessor(exportProcessors...)
+	if config.TailSampling != nil {
+		spanProcessor = NewTailSamplingSpanProcessor(spanProcessor, *config.TailSampling)
+	}
+	// Truncation runs after redaction so nothing is hashed before it is redacted
+	if config.TruncateValues != nil {
+		spanProcessor = NewTruncatingSpanProcessor(spanProcessor, *config.TruncateValues)
+	}
+	if config.CardinalityGuard != nil {
+		spanProcessor = NewCardinalityGuardSpanProcessor(spanProcessor, *config.CardinalityGuard)
+	}
+	// Redaction rules can change at runtime, so the processor is installed even without any
+	SetRedactionRules(config.RedactionRules...)
+	spanProcessor = &redactingSpanProcessor{SpanProcessor: spanProcessor, rules: RedactionRules}
+	if config.AttributeFilter != nil {
+		spanProcessor = NewAttributeFilterSpanProcessor(spanProcessor, *config.AttributeFilter)
+	}
+	if config.GitHubURL != nil {
+		spanProcessor = NewGitHubLinkSpanProcessor(spanProcessor, *config.GitHubURL, gitCommit(config))
+	}
+	if spanMetricsEnabled {
+		spanProcessor = sampledSpanProcessor{SpanProcessor: spanProcessor}
+	}
+	providerOptions = append(providerOptions, trace.WithSpanProcessor(spanProcessor))
+
+	traceProvider := trace.NewTracerProvider(providerOptions...)
 	return traceProvider, nil
 }
 
 func newLoggerProvider(ctx context.Context, config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (*log.LoggerProvider, error) {
-	baseURL := "api.iudex.ai"
-	if config.BaseURL != nil {
-		baseURL = *config.BaseURL
+	logExporter, err := newLogExporter(ctx, config, headers)
+	if err != nil {
+		return nil, err
 	}
-
-	logExporter, err := otlploghttp.New(ctx,
-		otlploghttp.WithEndpoint(baseURL),
-		otlploghttp.WithHeaders(*headers),
-	)
+	logExporter, err = withLogCircuitBreaker(ctx, config, logExporter)
+	if err != nil {
+		return nil, err
+	}
+	logExporter, err = withLogDiskBuffer(config, headers, logExporter)
+	if err != nil {
+		return nil, err
+	}
+	destinationExporters, err := destinationLogExporters(ctx, config)
 	if err != nil {
 		return nil, err
 	}
 
-	processor := log.NewBatchProcessor(logExporter)
-	loggerProvider := log.NewLoggerProvider(
+	if config.LogLevel != nil {
+		SetLogLevel(*config.LogLevel)
+	}
+
+	providerOptions := []log.LoggerProviderOption{
 		log.WithResource(res),
-		log.WithProcessor(processor),
-	)
+	}
+	if config.AttributeLimits != nil {
+		providerOptions = append(providerOptions, config.AttributeLimits.logLimitOptions()...)
+	}
+	enrichers := []log.Processor{NewGlobalAttributeLogProcessor()}
+	if config.BaggageKeys != nil && len(*config.BaggageKeys) > 0 {
+		enrichers = append(enrichers, NewBaggageLogProcessor(*config.BaggageKeys...))
+	}
+	if config.SendUserEmail != nil && *config.SendUserEmail {
+		enrichers = append(enrichers, userEmailLogProcessor{})
+	}
+	if config.GitHubURL != nil {
+		if processor := NewGitHubLinkLogProcessor(*config.GitHubURL, gitCommit(config)); processor != nil {
+			enrichers = append(enrichers, processor)
+		}
+	}
+	// Every exporter gets its own processor so destinations fail independently
+	var exportProcessors []log.Processor
+	var queues []*exportQueue
+	for _, exp := range append([]log.Exporter{logExporter}, destinationExporters...) {
+		if config.Serverless != nil && *config.Serverless {
+			exportProcessors = append(exportProcessors, log.NewSimpleProcessor(&countingLogExporter{Exporter: exp}))
+		} else {
+			queue := newExportQueue(config.LogBatch, &droppedLogs)
+			queues = append(queues, queue)
+			batch := log.NewBatchProcessor(&countingLogExporter{Exporter: exp, queue: queue}, logBatchOptions(config.LogBatch)...)
+			exportProcessors = append(exportProcessors, &queueLogProcessor{Processor: batch, queue: queue})
+		}
+	}
+	logQueues.Store(&queues)
+	// Level and sampling decisions are made once per record, in front of all exporters
+	var processor log.Processor = newFanoutLogProcessor(exportProcessors...)
+	if config.LogSampling != nil {
+		processor = NewLogSamplingProcessor(processor, *config.LogSampling)
+	}
+	if config.TenantLimits != nil && config.TenantLimits.LogsPerSecond > 0 {
+		processor = newTenantLimitLogProcessor(processor, config.TenantLimits.LogsPerSecond)
+	}
+	// Filtering, redaction, and truncation run after the hooks, so they also cover what hooks add
+	var scrubbers []log.Processor
+	if config.AttributeFilter != nil {
+		scrubbers = append(scrubbers, NewAttributeFilterLogProcessor(*config.AttributeFilter))
+	}
+	SetRedactionRules(config.RedactionRules...)
+	scrubbers = append(scrubbers, &redactingLogProcessor{rules: RedactionRules})
+	if config.TruncateValues != nil {
+		scrubbers = append(scrubbers, NewTruncatingLogProcessor(*config.TruncateValues))
+	}
+	processor = newFanoutLogProcessor(append(scrubbers, processor)...)
+	// Hooks run before sampling so dropped records do not count towards it
+	processor = NewLogHookProcessor(processor, config.LogHooks...)
+	// The severity filter is the only processor of the provider, so the bridges skip building
+	// records it would drop and nothing is done for them
+	processor = newSeverityFilterProcessor(newFanoutLogProcessor(append(enrichers, processor)...))
+	providerOptions = append(providerOptions, log.WithProcessor(processor))
+	loggerProvider := log.NewLoggerProvider(providerOptions...)
 	return loggerProvider, nil
 }
 
+func NewMeterProvider(ctx context.Context, config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (*metric.MeterProvider, error) {
+	interval := time.Minute
+	if config.MetricInterval != nil {
+		interval = *config.MetricInterval
+	}
+
+	metricExporter, err := newMetricExporter(ctx, config, headers)
+	if err != nil {
+		return nil, err
+	}
+	destinationExporters, err := destinationMetricExporters(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	providerOptions := []metric.Option{metric.WithResource(res)}
+	for _, exp := range append([]metric.Exporter{metricExporter}, destinationExporters...) {
+		providerOptions = append(providerOptions, metric.WithReader(metric.NewPeriodicReader(exp,
+			metric.WithInterval(interval))))
+	}
+
+	meterProvider := metric.NewMeterProvider(providerOptions...)
+	return meterProvider, nil
+}
+
 func GetLoggerProvider() internalLog.LoggerProvider {
 	return global.GetLoggerProvider()
 }
 
-func NewSlogLogger(name string) *slog.Logger {
+func NewSlogLogger(name string, opts ...LoggerOption) *slog.Logger {
+	config := newLoggerConfig(opts)
 	provider := GetLoggerProvider()
-	return otelslog.NewLogger(name, otelslog.WithLoggerProvider(provider))
+	var handler slog.Handler = otelslog.NewHandler(name, otelslog.WithLoggerProvider(provider))
+	if config.caller {
+		handler = newCallerHandler(handler, config.callerSkip)
+	}
+	if len(config.slogTee) > 0 {
+		handlers := fanoutHandler{handler}
+		for _, tee := range config.slogTee {
+			handlers = append(handlers, newTraceHandler(tee))
+		}
+		handler = handlers
+	}
+	return slog.New(handler)
 }
 
-func NewZapLogger(name string) *zap.Logger {
+func NewZapLogger(name string, opts ...LoggerOption) *zap.Logger {
+	config := newLoggerConfig(opts)
 	provider := GetLoggerProvider()
-	return zap.New(otelzap.NewCore(name, otelzap.WithLoggerProvider(provider)))
+	var core zapcore.Core = otelzap.NewCore(name, otelzap.WithLoggerProvider(provider))
+	if config.zapFields {
+		core = &fieldMappingCore{Core: core}
+	}
+	if config.zapLevel != nil {
+		core = &levelCore{Core: core, level: config.zapLevel}
+	}
+	var zapOpts []zap.Option
+	if config.caller {
+		core = &callerCore{Core: core}
+		zapOpts = append(zapOpts, zap.AddCaller(), zap.AddCallerSkip(config.callerSkip))
+	}
+	if len(config.zapTee) > 0 {
+		cores := []zapcore.Core{core}
+		for _, tee := range config.zapTee {
+			cores = append(cores, &traceCore{Core: tee})
+		}
+		core = zapcore.NewTee(cores...)
+	}
+	return zap.New(core, zapOpts...)
 }
@@ -0,0 +1,148 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+)
+
+// metricRegistry holds the instruments returned by Counter, Histogram, and Gauge by kind and name,
+// so calling a helper inline on every request returns the same instrument
+var metricRegistry sync.Map
+
+// metricName applies IUDEX naming conventions: lowercase, dot-separated namespaces, and
+// underscores within a namespace, so "Checkout Orders-Placed" becomes "checkout_orders_placed"
+func metricName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	var b strings.Builder
+	underscore := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return b.String()
+}
+
+// meter returns the IUDEX meter from the installed meter provider
+func meter() metric.Meter {
+	return otel.Meter(tracerName)
+}
+
+// CounterMetric is a monotonic sum, e.g. of orders placed or bytes sent
+type CounterMetric struct {
+	name string
+	once sync.Once
+	inst metric.Float64Counter
+}
+
+// Counter returns the counter with the given name, creating it on first use. It can be called
+// before Setup, e.g. in a package-level var.
+//
+//	iudex.Counter("orders.placed").Inc(ctx, iudex.Attr("plan", "pro"))
+func Counter(name string) *CounterMetric {
+	name = metricName(name)
+	m, _ := metricRegistry.LoadOrStore("counter:"+name, &CounterMetric{name: name})
+	return m.(*CounterMetric)
+}
+
+func (c *CounterMetric) instrument() metric.Float64Counter {
+	c.once.Do(func() {
+		inst, err := meter().Float64Counter(c.name)
+		if err != nil {
+			otel.Handle(fmt.Errorf("metrics: counter %s: %w", c.name, err))
+			inst = metricnoop.Float64Counter{}
+		}
+		c.inst = inst
+	})
+	return c.inst
+}
+
+// Add adds n, which must not be negative
+func (c *CounterMetric) Add(ctx context.Context, n float64, attrs ...Attribute) {
+	c.instrument().Add(ctx, n, metric.WithAttributes(attrs...))
+}
+
+// Inc adds 1
+func (c *CounterMetric) Inc(ctx context.Context, attrs ...Attribute) {
+	c.Add(ctx, 1, attrs...)
+}
+
+// HistogramMetric records a distribution of values, e.g. of request durations or payload sizes
+type HistogramMetric struct {
+	name string
+	unit string
+	once sync.Once
+	inst metric.Float64Histogram
+}
+
+// Histogram returns the histogram with the given name, creating it on first use. unit is a UCUM
+// unit such as "ms", "s", or "By", or "" for none. A histogram keeps the unit it was first
+// requested with.
+//
+//	iudex.Histogram("checkout.duration", "ms").Record(ctx, 42)
+func Histogram(name, unit string) *HistogramMetric {
+	name = metricName(name)
+	m, _ := metricRegistry.LoadOrStore("histogram:"+name, &HistogramMetric{name: name, unit: unit})
+	return m.(*HistogramMetric)
+}
+
+func (h *HistogramMetric) instrument() metric.Float64Histogram {
+	h.once.Do(func() {
+		inst, err := meter().Float64Histogram(h.name, metric.WithUnit(h.unit))
+		if err != nil {
+			otel.Handle(fmt.Errorf("metrics: histogram %s: %w", h.name, err))
+			inst = metricnoop.Float64Histogram{}
+		}
+		h.inst = inst
+	})
+	return h.inst
+}
+
+// Record records a value
+func (h *HistogramMetric) Record(ctx context.Context, value float64, attrs ...Attribute) {
+	h.instrument().Record(ctx, value, metric.WithAttributes(attrs...))
+}
+
+// GaugeMetric records the current value of something, e.g. a queue depth or cache size
+type GaugeMetric struct {
+	name string
+	once sync.Once
+	inst metric.Float64Gauge
+}
+
+// Gauge returns the gauge with the given name, creating it on first use. Each export reports
+// the last value recorded for each set of attributes.
+//
+//	iudex.Gauge("jobs.queued").Record(ctx, float64(queue.Len()))
+func Gauge(name string) *GaugeMetric {
+	name = metricName(name)
+	m, _ := metricRegistry.LoadOrStore("gauge:"+name, &GaugeMetric{name: name})
+	return m.(*GaugeMetric)
+}
+
+func (g *GaugeMetric) instrument() metric.Float64Gauge {
+	g.once.Do(func() {
+		inst, err := meter().Float64Gauge(g.name)
+		if err != nil {
+			otel.Handle(fmt.Errorf("metrics: gauge %s: %w", g.name, err))
+			inst = metricnoop.Float64Gauge{}
+		}
+		g.inst = inst
+	})
+	return g.inst
+}
+
+// Record sets the current value
+func (g *GaugeMetric) Record(ctx context.Context, value float64, attrs ...Attribute) {
+	g.instrument().Record(ctx, value, metric.WithAttributes(attrs...))
+}
@@ -0,0 +1,534 @@
+package iudex
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the instrumentation set up by Setup
+type Option func(*InstrumentationConfig)
+
+// Setup bootstraps the OpenTelemetry pipeline from the given options.
+// Options that are not provided fall back to the environment defaults.
+// If it does not return an error, make sure to call shutdown for proper cleanup.
+func Setup(ctx context.Context, opts ...Option) (shutdown func(context.Context) error, err error) {
+	return SetupOTelSDK(ctx, NewConfig(opts...))
+}
+
+// NewConfig builds an InstrumentationConfig from the given options
+func NewConfig(opts ...Option) InstrumentationConfig {
+	config := InstrumentationConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithEndpoint sets where telemetry is exported to, either a host[:port] or a full
+// http(s) URL whose path prefixes the OTLP paths, e.g. http://localhost:4318
+func WithEndpoint(endpoint string) Option {
+	return func(c *InstrumentationConfig) {
+		c.BaseURL = &endpoint
+	}
+}
+
+// WithAPIKey sets the private IUDEX API key
+func WithAPIKey(key string) Option {
+	return func(c *InstrumentationConfig) {
+		c.APIKey = &key
+	}
+}
+
+// WithPublicAPIKey sets the public write-only IUDEX API key
+func WithPublicAPIKey(key string) Option {
+	return func(c *InstrumentationConfig) {
+		c.PublicAPIKey = &key
+	}
+}
+
+// WithProtocol sets the OTLP transport, either ProtocolHTTP or ProtocolGRPC
+func WithProtocol(protocol string) Option {
+	return func(c *InstrumentationConfig) {
+		c.Protocol = &protocol
+	}
+}
+
+// WithHeaders adds headers sent with every export request
+func WithHeaders(headers map[string]string) Option {
+	return func(c *InstrumentationConfig) {
+		c.Headers = &headers
+	}
+}
+
+// WithInsecure exports over plain HTTP (or an insecure gRPC connection), e.g. to a local collector
+func WithInsecure() Option {
+	return func(c *InstrumentationConfig) {
+		c.Insecure = BoolPtr(true)
+	}
+}
+
+// WithGzip enables or disables gzip compression of the HTTP export payloads
+func WithGzip(enabled bool) Option {
+	return func(c *InstrumentationConfig) {
+		c.Gzip = &enabled
+	}
+}
+
+// WithProxyURL sends exports through the given HTTP proxy
+func WithProxyURL(proxyURL string) Option {
+	return func(c *InstrumentationConfig) {
+		c.ProxyURL = &proxyURL
+	}
+}
+
+// WithProxy sets the function choosing the proxy for each export request, see http.Transport.Proxy
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(c *InstrumentationConfig) {
+		c.Proxy = proxy
+	}
+}
+
+// WithExportDestination also sends all telemetry to another OTLP endpoint, such as an OTel Collector
+func WithExportDestination(dest ExportDestination) Option {
+	return func(c *InstrumentationConfig) {
+		c.ExportDestinations = append(c.ExportDestinations, dest)
+	}
+}
+
+// WithDiskBuffer spools spans and logs that fail to export under dir, keeping at most maxBytes
+// per signal (0 for DefaultDiskBufferMaxBytes), and replays them once IUDEX is reachable again
+func WithDiskBuffer(dir string, maxBytes int64) Option {
+	return func(c *InstrumentationConfig) {
+		c.DiskBuffer = &DiskBufferConfig{Dir: dir, MaxBytes: maxBytes}
+	}
+}
+
+// WithCircuitBreaker stops exporting spans and logs to IUDEX after repeated failures, sending them
+// to the configured fallback, or dropping them, until a probe export succeeds again
+//
+//	iudex.WithCircuitBreaker(iudex.CircuitBreakerConfig{FallbackFile: "/var/log/iudex-fallback.jsonl"})
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.CircuitBreaker = &config
+	}
+}
+
+// WithPropagators sets the context propagation formats by name, e.g. PropagatorTraceContext and
+// PropagatorB3, replacing the default of W3C trace context and baggage. All of them are injected,
+// and when a request carries several formats the last one listed wins.
+func WithPropagators(names ...string) Option {
+	return func(c *InstrumentationConfig) {
+		c.Propagators = &names
+	}
+}
+
+// WithXRay makes trace IDs compatible with AWS X-Ray and propagates the X-Amzn-Trace-Id header,
+// so traces started by ALB, API Gateway, or other X-Ray instrumented services continue in IUDEX
+func WithXRay() Option {
+	return func(c *InstrumentationConfig) {
+		c.XRay = BoolPtr(true)
+	}
+}
+
+// WithDisabled turns instrumentation off: Setup installs noop providers, creates no exporters,
+// and needs no API key. Instrumented code keeps working at close to zero cost.
+func WithDisabled() Option {
+	return func(c *InstrumentationConfig) {
+		c.Disabled = BoolPtr(true)
+	}
+}
+
+// WithDebug pretty-prints telemetry to stdout instead of exporting it, no API key required
+func WithDebug() Option {
+	return func(c *InstrumentationConfig) {
+		c.Debug = BoolPtr(true)
+	}
+}
+
+// WithSampler sets a custom trace sampler, overriding the other sampling options
+func WithSampler(sampler trace.Sampler) Option {
+	return func(c *InstrumentationConfig) {
+		c.CustomSampler = sampler
+	}
+}
+
+// WithSamplerType sets the sampler by name, e.g. SamplerParentBasedRatio or SamplerRateLimited.
+// The OTEL_TRACES_SAMPLER names always_on, always_off, traceidratio and parentbased_* are supported.
+func WithSamplerType(sampler string) Option {
+	return func(c *InstrumentationConfig) {
+		c.Sampler = &sampler
+	}
+}
+
+// WithSamplingRatio samples the given fraction of new traces, respecting parent decisions
+func WithSamplingRatio(ratio float64) Option {
+	return func(c *InstrumentationConfig) {
+		c.Sampler = StringPtr(SamplerParentBasedRatio)
+		c.SamplerRatio = &ratio
+	}
+}
+
+// WithSamplingRateLimit samples at most perSecond new traces per second, respecting parent decisions
+func WithSamplingRateLimit(perSecond float64) Option {
+	return func(c *InstrumentationConfig) {
+		c.Sampler = StringPtr(SamplerRateLimited)
+		c.SamplerRateLimit = &perSecond
+	}
+}
+
+// WithBaggageKeys sets which baggage members are copied onto every span and log record,
+// replacing DefaultBaggageKeys. Call it without keys to copy none.
+func WithBaggageKeys(keys ...string) Option {
+	return func(c *InstrumentationConfig) {
+		c.BaggageKeys = &keys
+	}
+}
+
+// WithUserEmails sends the emails given to SetUser as user.email, instead of only their hash.
+// Emails are added to the spans and log records of this service, not to the baggage.
+func WithUserEmails() Option {
+	return func(c *InstrumentationConfig) {
+		c.SendUserEmail = BoolPtr(true)
+	}
+}
+
+// WithUserHashSecret sets the secret that keys the user.hash HMAC of the emails given to SetUser.
+// Hashes match only between services and releases that share the secret.
+func WithUserHashSecret(secret string) Option {
+	return func(c *InstrumentationConfig) {
+		c.UserHashSecret = &secret
+	}
+}
+
+// WithTailSampling buffers the spans of each trace until it completes and only exports traces
+// with errors, slow traces, and a baseline ratio of the rest
+func WithTailSampling(sampling TailSamplingConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.TailSampling = &sampling
+	}
+}
+
+// WithRedaction scrubs span and log attribute values matching any of the rules before export
+func WithRedaction(rules ...RedactionRule) Option {
+	return func(c *InstrumentationConfig) {
+		c.RedactionRules = append(c.RedactionRules, rules...)
+	}
+}
+
+// WithAttributeLimits truncates string attribute values and caps the number of attributes, events,
+// and links of spans and log records before export
+//
+//	iudex.WithAttributeLimits(iudex.AttributeLimits{ValueLength: 4096})
+func WithAttributeLimits(limits AttributeLimits) Option {
+	return func(c *InstrumentationConfig) {
+		c.AttributeLimits = &limits
+	}
+}
+
+// WithValueTruncation truncates string attribute values and log bodies longer than maxLength bytes.
+// Unlike the ValueLength of WithAttributeLimits, truncated values end with a marker holding their
+// original length and a hash of the whole value, so equal values still compare equal.
+func WithValueTruncation(maxLength int) Option {
+	return func(c *InstrumentationConfig) {
+		c.TruncateValues = &maxLength
+	}
+}
+
+// WithRemoteSampling samples root spans at the rates set for the service in IUDEX, by route or
+// span name, fetched every config.Interval. Other spans use the configured sampler.
+func WithRemoteSampling(config RemoteSamplingConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.RemoteSampling = &config
+	}
+}
+
+// WithConfigWatcher reloads the sampler, the log level, and the redaction rules at runtime when
+// the config file at config.Path or the config of the service in IUDEX changes
+//
+//	iudex.WithConfigWatcher(iudex.ConfigWatcherConfig{Path: "/etc/iudex/iudex.yaml", Remote: true})
+func WithConfigWatcher(config ConfigWatcherConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.ConfigWatcher = &config
+	}
+}
+
+// WithTenantLimits caps the traces and log records of each tenant set with WithTenant, so one
+// customer's traffic cannot consume the whole telemetry budget
+//
+//	iudex.WithTenantLimits(iudex.TenantLimitConfig{SpansPerSecond: 10, LogsPerSecond: 100})
+func WithTenantLimits(config TenantLimitConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.TenantLimits = &config
+	}
+}
+
+// WithCardinalityGuard normalizes span names and attribute keys like "GET /users/42" to
+// "GET /users/{n}" once more distinct ones than the budgets of config end within a window
+//
+//	iudex.WithCardinalityGuard(iudex.CardinalityGuardConfig{SpanNames: 500})
+func WithCardinalityGuard(config CardinalityGuardConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.CardinalityGuard = &config
+	}
+}
+
+// WithAllowedAttributes only exports span and log attributes whose keys match one of the patterns.
+// Patterns may use * as a wildcard.
+func WithAllowedAttributes(patterns ...string) Option {
+	return func(c *InstrumentationConfig) {
+		if c.AttributeFilter == nil {
+			c.AttributeFilter = &AttributeFilter{}
+		}
+		c.AttributeFilter.Allow = append(c.AttributeFilter.Allow, patterns...)
+	}
+}
+
+// WithDeniedAttributes drops span and log attributes whose keys match one of the patterns before export.
+// Patterns may use * as a wildcard.
+func WithDeniedAttributes(patterns ...string) Option {
+	return func(c *InstrumentationConfig) {
+		if c.AttributeFilter == nil {
+			c.AttributeFilter = &AttributeFilter{}
+		}
+		c.AttributeFilter.Deny = append(c.AttributeFilter.Deny, patterns...)
+	}
+}
+
+// WithServerless exports spans and logs synchronously so nothing is lost when the runtime freezes
+func WithServerless() Option {
+	return func(c *InstrumentationConfig) {
+		c.Serverless = BoolPtr(true)
+	}
+}
+
+// WithTraceBatch tunes the batch processor used for spans
+func WithTraceBatch(batch BatchConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.TraceBatch = &batch
+	}
+}
+
+// WithLogBatch tunes the batch processor used for log records
+func WithLogBatch(batch BatchConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.LogBatch = &batch
+	}
+}
+
+// WithTraceRetry tunes how failed span exports are retried
+func WithTraceRetry(retry RetryConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.TraceRetry = &retry
+	}
+}
+
+// WithLogRetry tunes how failed log exports are retried
+func WithLogRetry(retry RetryConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.LogRetry = &retry
+	}
+}
+
+// WithGlobalAttributes adds attrs to every span and log record, e.g. a deploy ID. Use
+// SetGlobalAttribute to change them while the service runs.
+func WithGlobalAttributes(attrs ...Attribute) Option {
+	return func(c *InstrumentationConfig) {
+		c.GlobalAttributes = append(c.GlobalAttributes, attrs...)
+	}
+}
+
+// WithSpanStatus sets how the bundled HTTP middleware decide the status of request spans from
+// the response status code and handler error, e.g. to treat 429 responses as errors
+func WithSpanStatus(fn SpanStatusFunc) Option {
+	return func(c *InstrumentationConfig) {
+		c.SpanStatus = fn
+	}
+}
+
+// WithLogLevel drops log records below severity before export, e.g. otellog.SeverityInfo to
+// suppress debug logs. Use SetLogLevel to change it while the service runs.
+func WithLogLevel(severity otellog.Severity) Option {
+	return func(c *InstrumentationConfig) {
+		c.LogLevel = &severity
+	}
+}
+
+// WithLogSampling samples repetitive log records, e.g. from hot loops, before export
+func WithLogSampling(sampling LogSamplingConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.LogSampling = &sampling
+	}
+}
+
+// WithSelfTelemetry reports the export pipeline counters returned by Stats as iudex.sdk.* metrics,
+// to alert on dropped telemetry
+func WithSelfTelemetry() Option {
+	return func(c *InstrumentationConfig) {
+		c.SelfTelemetry = BoolPtr(true)
+	}
+}
+
+// WithErrorHandler sets how errors of the OpenTelemetry SDK, e.g. failed exports, are reported.
+// Without it they are logged with slog.Default, each distinct error at most once a minute.
+//
+//	iudex.WithErrorHandler(iudex.ErrorHandlerConfig{
+//		Handler:       func(err error, repeated int) { logger.Warn("telemetry error", "error", err) },
+//		EscalateAfter: 10 * time.Minute,
+//		Escalate:      func(err error, since time.Duration) { alert(err) },
+//	})
+func WithErrorHandler(config ErrorHandlerConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.ErrorHandler = &config
+	}
+}
+
+// WithSpanProcessor registers processor with the tracer provider, e.g. to enrich spans in OnStart.
+// Processors run in the order they are added, after the built-in enrichment and before export, and
+// see every span the head sampler keeps, before tail sampling and redaction. They are shut down
+// with the provider.
+func WithSpanProcessor(processor trace.SpanProcessor) Option {
+	return func(c *InstrumentationConfig) {
+		c.SpanProcessors = append(c.SpanProcessors, processor)
+	}
+}
+
+// WithSpanEndHook calls fn with every span as it ends, like a span processor with only OnEnd
+//
+//	iudex.WithSpanEndHook(func(span trace.ReadOnlySpan) {
+//		if span.Status().Code == codes.Error {
+//			errorCount.Add(1)
+//		}
+//	})
+func WithSpanEndHook(fn SpanEndFunc) Option {
+	return WithSpanProcessor(NewSpanEndHookProcessor(fn))
+}
+
+// WithLogHook runs fn on every log record before export, to modify records in place or drop them
+// by returning false. Hooks run in the order they are added.
+//
+//	iudex.WithLogHook(func(ctx context.Context, record *log.Record) bool {
+//		record.AddAttributes(otellog.String("cloud.region", region))
+//		return record.Body().AsString() != "health check"
+//	})
+func WithLogHook(fn LogProcessorFunc) Option {
+	return func(c *InstrumentationConfig) {
+		c.LogHooks = append(c.LogHooks, fn)
+	}
+}
+
+// WithMetricInterval sets how often metrics are exported
+func WithMetricInterval(interval time.Duration) Option {
+	return func(c *InstrumentationConfig) {
+		c.MetricInterval = &interval
+	}
+}
+
+// WithSpanMetrics derives request rate, error, and duration metrics from server and client spans,
+// so dashboards stay accurate when traces are sampled
+func WithSpanMetrics() Option {
+	return func(c *InstrumentationConfig) {
+		c.SpanMetrics = BoolPtr(true)
+	}
+}
+
+// WithTenantMetrics adds the tenant set with WithTenant to the span metrics and the HTTP server
+// metrics. Every tenant adds a series to each of them, so use it only with a bounded set of tenants.
+func WithTenantMetrics() Option {
+	return func(c *InstrumentationConfig) {
+		c.TenantMetrics = BoolPtr(true)
+	}
+}
+
+// WithProfiling periodically captures CPU and heap profiles and uploads them to IUDEX, where
+// they are correlated with the traces of the same service. It requires the HTTP protocol.
+func WithProfiling(profiling ProfilingConfig) Option {
+	return func(c *InstrumentationConfig) {
+		c.Profiling = &profiling
+	}
+}
+
+// WithProfileTriggers polls IUDEX for goroutine, block, mutex, CPU, or heap profiles requested
+// on demand, e.g. during an incident. It requires the HTTP protocol.
+func WithProfileTriggers() Option {
+	return func(c *InstrumentationConfig) {
+		c.ProfileTriggers = BoolPtr(true)
+	}
+}
+
+// WithHeartbeat emits a heartbeat event every interval, 1m when zero, and reports the uptime as a
+// metric, so IUDEX notices instances that stopped even when they served no traffic
+func WithHeartbeat(interval time.Duration) Option {
+	return func(c *InstrumentationConfig) {
+		c.Heartbeat = &HeartbeatConfig{Interval: interval}
+	}
+}
+
+// WithServiceName sets the service.name resource attribute
+func WithServiceName(name string) Option {
+	return func(c *InstrumentationConfig) {
+		c.ServiceName = &name
+	}
+}
+
+// WithInstanceID sets the service.instance.id resource attribute
+func WithInstanceID(id string) Option {
+	return func(c *InstrumentationConfig) {
+		c.InstanceID = &id
+	}
+}
+
+// WithEnv sets the env resource attribute
+func WithEnv(env string) Option {
+	return func(c *InstrumentationConfig) {
+		c.Env = &env
+	}
+}
+
+// WithGitCommit sets the git.commit resource attribute
+func WithGitCommit(commit string) Option {
+	return func(c *InstrumentationConfig) {
+		c.GitCommit = &commit
+	}
+}
+
+// WithResourceAttributes adds extra resource attributes
+func WithResourceAttributes(attrs map[string]string) Option {
+	return func(c *InstrumentationConfig) {
+		c.ResourceAttributes = &attrs
+	}
+}
+
+// WithResourceDetectors adds resource attributes found by detectors, such as the cloud detectors in iudexdetectors
+func WithResourceDetectors(detectors ...resource.Detector) Option {
+	return func(c *InstrumentationConfig) {
+		c.ResourceDetectors = append(c.ResourceDetectors, detectors...)
+	}
+}
+
+// WithBuildInfo controls whether service.version, go.module.path, and the VCS revision of the
+// binary are read from its build info and added to the resource
+func WithBuildInfo(enabled bool) Option {
+	return func(c *InstrumentationConfig) {
+		c.BuildInfo = &enabled
+	}
+}
+
+// WithHostAttributes controls whether host and process attributes, including the command line arguments, are added to the resource
+func WithHostAttributes(enabled bool) Option {
+	return func(c *InstrumentationConfig) {
+		c.HostAttributes = &enabled
+	}
+}
+
+// WithGitHubURL sets the github.url resource attribute
+func WithGitHubURL(url string) Option {
+	return func(c *InstrumentationConfig) {
+		c.GitHubURL = &url
+	}
+}
@@ -0,0 +1,466 @@
+package iudex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// otelFileConfig is the subset of the OpenTelemetry declarative configuration schema that maps
+// onto InstrumentationConfig, see https://github.com/open-telemetry/opentelemetry-configuration
+type otelFileConfig struct {
+	FileFormat string `yaml:"file_format"`
+	Disabled   *bool  `yaml:"disabled"`
+
+	Resource *struct {
+		Attributes []struct {
+			Name  string `yaml:"name"`
+			Value any    `yaml:"value"`
+			Type  string `yaml:"type"`
+		} `yaml:"attributes"`
+		AttributesList string `yaml:"attributes_list"`
+		SchemaURL      string `yaml:"schema_url"`
+	} `yaml:"resource"`
+
+	AttributeLimits *otelLimits `yaml:"attribute_limits"`
+
+	Propagator *struct {
+		Composite     []string `yaml:"composite"`
+		CompositeList string   `yaml:"composite_list"`
+	} `yaml:"propagator"`
+
+	TracerProvider *struct {
+		Processors []otelProcessor `yaml:"processors"`
+		Limits     *otelLimits     `yaml:"limits"`
+		Sampler    *otelSampler    `yaml:"sampler"`
+	} `yaml:"tracer_provider"`
+
+	LoggerProvider *struct {
+		Processors []otelProcessor `yaml:"processors"`
+		Limits     *otelLimits     `yaml:"limits"`
+	} `yaml:"logger_provider"`
+
+	MeterProvider *struct {
+		Readers []struct {
+			Periodic *struct {
+				Interval *int         `yaml:"interval"`
+				Timeout  *int         `yaml:"timeout"`
+				Exporter otelExporter `yaml:"exporter"`
+			} `yaml:"periodic"`
+		} `yaml:"readers"`
+	} `yaml:"meter_provider"`
+
+	// Instrumentation settings are language specific and not used by the SDK
+	Instrumentation any `yaml:"instrumentation"`
+}
+
+type otelLimits struct {
+	AttributeValueLengthLimit *int `yaml:"attribute_value_length_limit"`
+	AttributeCountLimit       *int `yaml:"attribute_count_limit"`
+	EventCountLimit           *int `yaml:"event_count_limit"`
+	LinkCountLimit            *int `yaml:"link_count_limit"`
+	EventAttributeCountLimit  *int `yaml:"event_attribute_count_limit"`
+	LinkAttributeCountLimit   *int `yaml:"link_attribute_count_limit"`
+}
+
+// otelProcessor is a batch or simple span or log record processor. Durations are in milliseconds.
+type otelProcessor struct {
+	Batch *struct {
+		ScheduleDelay      *int         `yaml:"schedule_delay"`
+		ExportTimeout      *int         `yaml:"export_timeout"`
+		MaxQueueSize       *int         `yaml:"max_queue_size"`
+		MaxExportBatchSize *int         `yaml:"max_export_batch_size"`
+		Exporter           otelExporter `yaml:"exporter"`
+	} `yaml:"batch"`
+	Simple *struct {
+		Exporter otelExporter `yaml:"exporter"`
+	} `yaml:"simple"`
+}
+
+// otelExporter is an OTLP or console exporter. Newer schema versions name OTLP exporters by
+// transport instead of setting a protocol.
+type otelExporter struct {
+	OTLP     *otelOTLPExporter `yaml:"otlp"`
+	OTLPHTTP *otelOTLPExporter `yaml:"otlp_http"`
+	OTLPGRPC *otelOTLPExporter `yaml:"otlp_grpc"`
+	Console  *struct{}         `yaml:"console"`
+}
+
+type otelOTLPExporter struct {
+	Protocol string `yaml:"protocol"`
+	Endpoint string `yaml:"endpoint"`
+	Headers  []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"headers"`
+	HeadersList string `yaml:"headers_list"`
+	Compression string `yaml:"compression"`
+	Timeout     *int   `yaml:"timeout"`
+	Insecure    *bool  `yaml:"insecure"`
+}
+
+type otelSampler struct {
+	AlwaysOn          *struct{} `yaml:"always_on"`
+	AlwaysOff         *struct{} `yaml:"always_off"`
+	TraceIDRatioBased *struct {
+		Ratio *float64 `yaml:"ratio"`
+	} `yaml:"trace_id_ratio_based"`
+	ParentBased *struct {
+		Root *otelSampler `yaml:"root"`
+	} `yaml:"parent_based"`
+}
+
+// isOTelConfig reports whether data is an OpenTelemetry declarative configuration file, which
+// always sets file_format
+func isOTelConfig(data []byte) bool {
+	var header struct {
+		FileFormat *string `yaml:"file_format"`
+	}
+	return yaml.Unmarshal(data, &header) == nil && header.FileFormat != nil
+}
+
+// otelEnvReference matches the environment variable references of declarative configuration,
+// ${VAR}, ${env:VAR}, and ${VAR:-default}, and the $$ escape
+var otelEnvReference = regexp.MustCompile(`\$\$|\$\{(?:env:)?([a-zA-Z_][a-zA-Z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandOTelEnv substitutes environment variable references in data. Unset variables without a
+// default expand to nothing.
+func expandOTelEnv(data []byte) []byte {
+	return otelEnvReference.ReplaceAllFunc(data, func(ref []byte) []byte {
+		if string(ref) == "$$" {
+			return []byte("$")
+		}
+		match := otelEnvReference.FindSubmatch(ref)
+		if value, ok := os.LookupEnv(string(match[1])); ok && value != "" {
+			return []byte(value)
+		}
+		return match[2]
+	})
+}
+
+// parseOTelConfig decodes an OpenTelemetry declarative configuration file. Settings IUDEX cannot
+// honor are rejected rather than ignored.
+func parseOTelConfig(data []byte) (InstrumentationConfig, error) {
+	var file otelFileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(expandOTelEnv(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return InstrumentationConfig{}, err
+	}
+	if file.FileFormat == "" {
+		return InstrumentationConfig{}, errors.New("file_format is required")
+	}
+
+	config := InstrumentationConfig{Disabled: file.Disabled}
+	if err := file.applyResource(&config); err != nil {
+		return InstrumentationConfig{}, err
+	}
+	if file.Propagator != nil {
+		propagators := file.Propagator.Composite
+		if len(propagators) == 0 && file.Propagator.CompositeList != "" {
+			for _, name := range strings.Split(file.Propagator.CompositeList, ",") {
+				propagators = append(propagators, strings.TrimSpace(name))
+			}
+		}
+		if len(propagators) > 0 {
+			config.Propagators = &propagators
+		}
+	}
+	file.applyLimits(&config)
+
+	exporters := &otelExporters{}
+	if tp := file.TracerProvider; tp != nil {
+		batch, err := exporters.addProcessors(&config, tp.Processors)
+		if err != nil {
+			return InstrumentationConfig{}, fmt.Errorf("tracer_provider: %w", err)
+		}
+		config.TraceBatch = batch
+		if tp.Sampler != nil {
+			sampler, ratio, err := tp.Sampler.name()
+			if err != nil {
+				return InstrumentationConfig{}, fmt.Errorf("tracer_provider: %w", err)
+			}
+			config.Sampler, config.SamplerRatio = &sampler, ratio
+		}
+	}
+	if lp := file.LoggerProvider; lp != nil {
+		batch, err := exporters.addProcessors(&config, lp.Processors)
+		if err != nil {
+			return InstrumentationConfig{}, fmt.Errorf("logger_provider: %w", err)
+		}
+		config.LogBatch = batch
+	}
+	if mp := file.MeterProvider; mp != nil {
+		for _, reader := range mp.Readers {
+			if reader.Periodic == nil {
+				return InstrumentationConfig{}, errors.New("meter_provider: only periodic readers are supported")
+			}
+			if reader.Periodic.Interval != nil {
+				config.MetricInterval = DurationPtr(time.Duration(*reader.Periodic.Interval) * time.Millisecond)
+			}
+			if err := exporters.add(&config, reader.Periodic.Exporter); err != nil {
+				return InstrumentationConfig{}, fmt.Errorf("meter_provider: %w", err)
+			}
+		}
+	}
+	return config, nil
+}
+
+// applyResource sets the service name, instance ID, and environment from their semantic
+// convention attributes, and the other attributes as resource attributes
+func (file *otelFileConfig) applyResource(config *InstrumentationConfig) error {
+	if file.Resource == nil {
+		return nil
+	}
+	attrs := map[string]string{}
+	// attributes_list uses the OTEL_RESOURCE_ATTRIBUTES format, and attributes take precedence
+	for _, pair := range strings.Split(file.Resource.AttributesList, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if decoded, err := url.PathUnescape(value); err == nil {
+			value = decoded
+		}
+		attrs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	for _, attr := range file.Resource.Attributes {
+		if attr.Name == "" {
+			return errors.New("resource: attribute without a name")
+		}
+		switch value := attr.Value.(type) {
+		case []any:
+			return fmt.Errorf("resource: array attribute %s is not supported", attr.Name)
+		case nil:
+			attrs[attr.Name] = ""
+		default:
+			attrs[attr.Name] = fmt.Sprint(value)
+		}
+	}
+
+	for key, field := range map[string]**string{
+		"service.name":                &config.ServiceName,
+		"service.instance.id":         &config.InstanceID,
+		"deployment.environment":      &config.Env,
+		"deployment.environment.name": &config.Env,
+	} {
+		if value, ok := attrs[key]; ok {
+			*field = StringPtr(value)
+			delete(attrs, key)
+		}
+	}
+	if len(attrs) > 0 {
+		config.ResourceAttributes = &attrs
+	}
+	return nil
+}
+
+// applyLimits merges the general attribute limits with those of the tracer and logger providers
+func (file *otelFileConfig) applyLimits(config *InstrumentationConfig) {
+	limits := AttributeLimits{}
+	set := false
+	apply := func(from *int, to ...*int) {
+		if from == nil {
+			return
+		}
+		for _, field := range to {
+			*field = *from
+		}
+		set = true
+	}
+	if l := file.AttributeLimits; l != nil {
+		apply(l.AttributeValueLengthLimit, &limits.ValueLength)
+		apply(l.AttributeCountLimit, &limits.SpanAttributeCount, &limits.LogAttributeCount)
+	}
+	if file.TracerProvider != nil && file.TracerProvider.Limits != nil {
+		l := file.TracerProvider.Limits
+		apply(l.AttributeValueLengthLimit, &limits.ValueLength)
+		apply(l.AttributeCountLimit, &limits.SpanAttributeCount)
+		apply(l.EventCountLimit, &limits.EventCount)
+		apply(l.LinkCountLimit, &limits.LinkCount)
+		apply(l.EventAttributeCountLimit, &limits.AttributePerEventCount)
+		apply(l.LinkAttributeCountLimit, &limits.AttributePerLinkCount)
+	}
+	if file.LoggerProvider != nil && file.LoggerProvider.Limits != nil {
+		l := file.LoggerProvider.Limits
+		apply(l.AttributeValueLengthLimit, &limits.ValueLength)
+		apply(l.AttributeCountLimit, &limits.LogAttributeCount)
+	}
+	if set {
+		config.AttributeLimits = &limits
+	}
+}
+
+// name converts the sampler to one of the Sampler* names, with its ratio if it has one
+func (s *otelSampler) name() (string, *float64, error) {
+	switch {
+	case s.AlwaysOn != nil:
+		return SamplerAlwaysOn, nil, nil
+	case s.AlwaysOff != nil:
+		return SamplerAlwaysOff, nil, nil
+	case s.TraceIDRatioBased != nil:
+		return SamplerTraceIDRatio, s.TraceIDRatioBased.Ratio, nil
+	case s.ParentBased != nil:
+		root := s.ParentBased.Root
+		switch {
+		case root == nil || root.AlwaysOn != nil:
+			return SamplerParentBasedAlwaysOn, nil, nil
+		case root.AlwaysOff != nil:
+			return SamplerParentBasedAlwaysOff, nil, nil
+		case root.TraceIDRatioBased != nil:
+			return SamplerParentBasedRatio, root.TraceIDRatioBased.Ratio, nil
+		}
+		return "", nil, errors.New("unsupported parent_based root sampler")
+	}
+	return "", nil, errors.New("sampler must set one of always_on, always_off, trace_id_ratio_based, or parent_based")
+}
+
+var errConsoleWithOTLP = errors.New("console exporters cannot be combined with OTLP exporters")
+
+// otelExporters collects the exporters of all providers. The first OTLP exporter becomes the
+// IUDEX endpoint and the others export destinations, which receive every signal.
+type otelExporters struct {
+	seen    map[string]bool
+	console bool
+}
+
+// addProcessors adds the exporters of processors and returns the batch settings of the first
+// batch processor. Simple processors export synchronously, as in serverless mode.
+func (e *otelExporters) addProcessors(config *InstrumentationConfig, processors []otelProcessor) (*BatchConfig, error) {
+	var batch *BatchConfig
+	for _, p := range processors {
+		switch {
+		case p.Batch != nil:
+			if err := e.add(config, p.Batch.Exporter); err != nil {
+				return nil, err
+			}
+			if batch != nil {
+				continue
+			}
+			batch = &BatchConfig{}
+			if p.Batch.ScheduleDelay != nil {
+				batch.Timeout = time.Duration(*p.Batch.ScheduleDelay) * time.Millisecond
+			}
+			if p.Batch.MaxQueueSize != nil {
+				batch.MaxQueueSize = *p.Batch.MaxQueueSize
+			}
+			if p.Batch.MaxExportBatchSize != nil {
+				batch.MaxExportBatchSize = *p.Batch.MaxExportBatchSize
+			}
+			timeout := p.Batch.ExportTimeout
+			if timeout == nil {
+				timeout = p.Batch.Exporter.timeout()
+			}
+			if timeout != nil {
+				batch.ExportTimeout = time.Duration(*timeout) * time.Millisecond
+			}
+		case p.Simple != nil:
+			if err := e.add(config, p.Simple.Exporter); err != nil {
+				return nil, err
+			}
+			config.Serverless = BoolPtr(true)
+		default:
+			return nil, errors.New("processor must be batch or simple")
+		}
+	}
+	return batch, nil
+}
+
+// add sets the IUDEX endpoint from the first OTLP exporter and adds later ones as export
+// destinations. A console exporter enables debug output, which replaces the OTLP exporters of
+// every signal, so it cannot be combined with them.
+func (e *otelExporters) add(config *InstrumentationConfig, exporter otelExporter) error {
+	if exporter.Console != nil {
+		if e.seen != nil {
+			return errConsoleWithOTLP
+		}
+		e.console = true
+		config.Debug = BoolPtr(true)
+		return nil
+	}
+	if e.console {
+		return errConsoleWithOTLP
+	}
+	otlp, protocol := exporter.OTLP, ""
+	switch {
+	case exporter.OTLPHTTP != nil:
+		otlp, protocol = exporter.OTLPHTTP, "http/protobuf"
+	case exporter.OTLPGRPC != nil:
+		otlp, protocol = exporter.OTLPGRPC, "grpc"
+	case otlp == nil:
+		return errors.New("exporter must be otlp, otlp_http, otlp_grpc, or console")
+	default:
+		protocol = otlp.Protocol
+	}
+
+	dest := ExportDestination{Endpoint: otlpBaseURL(otlp.Endpoint)}
+	switch protocol {
+	case "", "http/protobuf":
+		dest.Protocol = ProtocolHTTP
+	case "grpc":
+		dest.Protocol = ProtocolGRPC
+	default:
+		return fmt.Errorf("unsupported OTLP protocol %q", protocol)
+	}
+	if otlp.Compression != "" && otlp.Compression != "none" && otlp.Compression != "gzip" {
+		return fmt.Errorf("unsupported OTLP compression %q", otlp.Compression)
+	}
+	dest.Insecure = otlp.Insecure != nil && *otlp.Insecure
+	if otlp.HeadersList != "" || len(otlp.Headers) > 0 {
+		dest.Headers = map[string]string{}
+		for _, pair := range strings.Split(otlp.HeadersList, ",") {
+			if key, value, ok := strings.Cut(pair, "="); ok {
+				dest.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		for _, header := range otlp.Headers {
+			dest.Headers[header.Name] = header.Value
+		}
+	}
+
+	if e.seen == nil {
+		e.seen = map[string]bool{}
+		config.BaseURL = StringPtr(dest.Endpoint)
+		config.Protocol = StringPtr(dest.Protocol)
+		config.Insecure = BoolPtr(dest.Insecure)
+		config.Gzip = BoolPtr(otlp.Compression == "gzip")
+		if dest.Headers != nil {
+			config.Headers = &dest.Headers
+		}
+	}
+	key := dest.Protocol + " " + dest.Endpoint
+	if e.seen[key] {
+		return nil
+	}
+	if len(e.seen) > 0 {
+		config.ExportDestinations = append(config.ExportDestinations, dest)
+	}
+	e.seen[key] = true
+	return nil
+}
+
+// timeout returns the export timeout of the OTLP exporter, in milliseconds, if it has one
+func (e otelExporter) timeout() *int {
+	for _, otlp := range []*otelOTLPExporter{e.OTLP, e.OTLPHTTP, e.OTLPGRPC} {
+		if otlp != nil {
+			return otlp.Timeout
+		}
+	}
+	return nil
+}
+
+// otlpBaseURL strips the signal path from an OTLP endpoint, since BaseURL prefixes the signal paths
+func otlpBaseURL(endpoint string) string {
+	for _, path := range []string{"/v1/traces", "/v1/logs", "/v1/metrics"} {
+		endpoint = strings.TrimSuffix(endpoint, path)
+	}
+	return strings.TrimSuffix(endpoint, "/")
+}
@@ -0,0 +1,250 @@
+package iudex
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// scopeKey groups telemetry by the resource and instrumentation scope that produced it
+type scopeKey struct {
+	resource attribute.Distinct
+	scope    instrumentation.Scope
+}
+
+// encodeSpans marshals spans into an OTLP/HTTP trace export request body
+func encodeSpans(spans []trace.ReadOnlySpan) ([]byte, error) {
+	resourceSpans := map[attribute.Distinct]*tracepb.ResourceSpans{}
+	scopeSpans := map[scopeKey]*tracepb.ScopeSpans{}
+	request := &coltracepb.ExportTraceServiceRequest{}
+
+	for _, span := range spans {
+		res := span.Resource()
+		key := scopeKey{resource: res.Equivalent(), scope: span.InstrumentationScope()}
+
+		rs, ok := resourceSpans[key.resource]
+		if !ok {
+			rs = &tracepb.ResourceSpans{Resource: encodeResource(res), SchemaUrl: res.SchemaURL()}
+			resourceSpans[key.resource] = rs
+			request.ResourceSpans = append(request.ResourceSpans, rs)
+		}
+		ss, ok := scopeSpans[key]
+		if !ok {
+			ss = &tracepb.ScopeSpans{Scope: encodeScope(key.scope), SchemaUrl: key.scope.SchemaURL}
+			scopeSpans[key] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, encodeSpan(span))
+	}
+	return proto.Marshal(request)
+}
+
+func encodeSpan(span trace.ReadOnlySpan) *tracepb.Span {
+	sc := span.SpanContext()
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+	s := &tracepb.Span{
+		TraceId:                traceID[:],
+		SpanId:                 spanID[:],
+		TraceState:             sc.TraceState().String(),
+		Flags:                  uint32(sc.TraceFlags()),
+		Name:                   span.Name(),
+		Kind:                   tracepb.Span_SpanKind(span.SpanKind()),
+		StartTimeUnixNano:      unixNano(span.StartTime()),
+		EndTimeUnixNano:        unixNano(span.EndTime()),
+		Attributes:             encodeAttributes(span.Attributes()),
+		DroppedAttributesCount: uint32(span.DroppedAttributes()),
+		DroppedEventsCount:     uint32(span.DroppedEvents()),
+		DroppedLinksCount:      uint32(span.DroppedLinks()),
+		Status:                 &tracepb.Status{Message: span.Status().Description},
+	}
+	if parent := span.Parent(); parent.HasSpanID() {
+		parentID := parent.SpanID()
+		s.ParentSpanId = parentID[:]
+	}
+	// codes.Ok and codes.Error are numbered the other way around in OTLP
+	switch span.Status().Code {
+	case codes.Ok:
+		s.Status.Code = tracepb.Status_STATUS_CODE_OK
+	case codes.Error:
+		s.Status.Code = tracepb.Status_STATUS_CODE_ERROR
+	}
+	for _, event := range span.Events() {
+		s.Events = append(s.Events, &tracepb.Span_Event{
+			TimeUnixNano:           unixNano(event.Time),
+			Name:                   event.Name,
+			Attributes:             encodeAttributes(event.Attributes),
+			DroppedAttributesCount: uint32(event.DroppedAttributeCount),
+		})
+	}
+	for _, link := range span.Links() {
+		linkTraceID, linkSpanID := link.SpanContext.TraceID(), link.SpanContext.SpanID()
+		s.Links = append(s.Links, &tracepb.Span_Link{
+			TraceId:                linkTraceID[:],
+			SpanId:                 linkSpanID[:],
+			TraceState:             link.SpanContext.TraceState().String(),
+			Flags:                  uint32(link.SpanContext.TraceFlags()),
+			Attributes:             encodeAttributes(link.Attributes),
+			DroppedAttributesCount: uint32(link.DroppedAttributeCount),
+		})
+	}
+	return s
+}
+
+// encodeLogs marshals records into an OTLP/HTTP logs export request body
+func encodeLogs(records []log.Record) ([]byte, error) {
+	resourceLogs := map[attribute.Distinct]*logspb.ResourceLogs{}
+	scopeLogs := map[scopeKey]*logspb.ScopeLogs{}
+	request := &collogspb.ExportLogsServiceRequest{}
+
+	for i := range records {
+		record := &records[i]
+		res := record.Resource()
+		key := scopeKey{resource: res.Equivalent(), scope: record.InstrumentationScope()}
+
+		rl, ok := resourceLogs[key.resource]
+		if !ok {
+			rl = &logspb.ResourceLogs{Resource: encodeResource(&res), SchemaUrl: res.SchemaURL()}
+			resourceLogs[key.resource] = rl
+			request.ResourceLogs = append(request.ResourceLogs, rl)
+		}
+		sl, ok := scopeLogs[key]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: encodeScope(key.scope), SchemaUrl: key.scope.SchemaURL}
+			scopeLogs[key] = sl
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, encodeLogRecord(record))
+	}
+	return proto.Marshal(request)
+}
+
+func encodeLogRecord(record *log.Record) *logspb.LogRecord {
+	lr := &logspb.LogRecord{
+		TimeUnixNano:           unixNano(record.Timestamp()),
+		ObservedTimeUnixNano:   unixNano(record.ObservedTimestamp()),
+		SeverityNumber:         logspb.SeverityNumber(record.Severity()),
+		SeverityText:           record.SeverityText(),
+		Body:                   encodeLogValue(record.Body()),
+		DroppedAttributesCount: uint32(record.DroppedAttributes()),
+		Flags:                  uint32(record.TraceFlags()),
+	}
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		lr.Attributes = append(lr.Attributes, &commonpb.KeyValue{Key: kv.Key, Value: encodeLogValue(kv.Value)})
+		return true
+	})
+	if traceID := record.TraceID(); traceID.IsValid() {
+		lr.TraceId = traceID[:]
+	}
+	if spanID := record.SpanID(); spanID.IsValid() {
+		lr.SpanId = spanID[:]
+	}
+	return lr
+}
+
+func encodeLogValue(v otellog.Value) *commonpb.AnyValue {
+	switch v.Kind() {
+	case otellog.KindBool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case otellog.KindInt64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case otellog.KindFloat64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case otellog.KindString:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case otellog.KindBytes:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v.AsBytes()}}
+	case otellog.KindSlice:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsSlice()))
+		for _, item := range v.AsSlice() {
+			values = append(values, encodeLogValue(item))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case otellog.KindMap:
+		kvs := make([]*commonpb.KeyValue, 0, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			kvs = append(kvs, &commonpb.KeyValue{Key: kv.Key, Value: encodeLogValue(kv.Value)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	default:
+		return nil
+	}
+}
+
+func encodeResource(res *resource.Resource) *resourcepb.Resource {
+	return &resourcepb.Resource{Attributes: encodeAttributes(res.Attributes())}
+}
+
+func encodeScope(scope instrumentation.Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: scope.Name, Version: scope.Version}
+}
+
+func encodeAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, &commonpb.KeyValue{Key: string(attr.Key), Value: encodeAttributeValue(attr.Value)})
+	}
+	return kvs
+}
+
+func encodeAttributeValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		return encodeArray(v.AsBoolSlice(), func(b bool) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: b}}
+		})
+	case attribute.INT64SLICE:
+		return encodeArray(v.AsInt64Slice(), func(i int64) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}}
+		})
+	case attribute.FLOAT64SLICE:
+		return encodeArray(v.AsFloat64Slice(), func(f float64) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}}
+		})
+	case attribute.STRINGSLICE:
+		return encodeArray(v.AsStringSlice(), func(s string) *commonpb.AnyValue {
+			return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+		})
+	default:
+		return nil
+	}
+}
+
+func encodeArray[T any](items []T, encode func(T) *commonpb.AnyValue) *commonpb.AnyValue {
+	values := make([]*commonpb.AnyValue, len(items))
+	for i, item := range items {
+		values[i] = encode(item)
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+// unixNano converts t for OTLP, where the zero time is encoded as 0
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
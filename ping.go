@@ -0,0 +1,193 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// API key types reported by Ping
+const (
+	KeyTypePublic  = "public"
+	KeyTypePrivate = "private"
+)
+
+// Reasons a Ping fails, wrapped by PingError
+var (
+	ErrMissingAPIKey       = errors.New("no IUDEX API key configured")
+	ErrInvalidAPIKey       = errors.New("IUDEX API key was rejected")
+	ErrEndpointUnreachable = errors.New("IUDEX endpoint is unreachable")
+	ErrUnexpectedResponse  = errors.New("unexpected response from IUDEX endpoint")
+)
+
+// PingResult describes a successful Ping
+type PingResult struct {
+	Endpoint string
+	Protocol string
+	KeyType  string // KeyTypePublic or KeyTypePrivate
+	Latency  time.Duration
+}
+
+// PingError is returned by Ping. Use errors.Is with ErrMissingAPIKey, ErrInvalidAPIKey,
+// ErrEndpointUnreachable, or ErrUnexpectedResponse to tell the failures apart.
+type PingError struct {
+	Endpoint string
+	KeyType  string
+	// Status is the HTTP status or gRPC code returned by the endpoint, if it responded
+	Status string
+	Err    error
+	// Cause is the underlying transport error, if any
+	Cause error
+}
+
+func (e *PingError) Error() string {
+	msg := fmt.Sprintf("ping %s: %v", e.Endpoint, e.Err)
+	if e.Status != "" {
+		msg += " (" + e.Status + ")"
+	}
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+	return msg
+}
+
+func (e *PingError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// pingTimeout bounds Ping when its context has no deadline
+const pingTimeout = 10 * time.Second
+
+// Ping sends an empty trace export to the configured endpoint to check that it is reachable
+// and accepts the API key, so misconfiguration surfaces at startup instead of as missing
+// telemetry. Unset fields fall back to the environment defaults, as in SetupOTelSDK.
+// In debug mode nothing is exported and Ping returns immediately. Without a deadline on ctx,
+// Ping gives up after 10s.
+func Ping(ctx context.Context, config InstrumentationConfig) (PingResult, error) {
+	config = applyDefaults(config)
+	if isDebug(config) {
+		return PingResult{}, nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+	}
+
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return PingResult{}, err
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return PingResult{}, err
+	}
+	result := PingResult{Endpoint: ep.host + ep.path, Protocol: protocol}
+	pingErr := &PingError{Endpoint: result.Endpoint}
+
+	headers, err := NewHeaders(config)
+	if err != nil {
+		pingErr.Err, pingErr.Cause = ErrMissingAPIKey, err
+		return result, pingErr
+	}
+	if config.PublicAPIKey != nil {
+		result.KeyType = KeyTypePublic
+	} else {
+		result.KeyType = KeyTypePrivate
+	}
+	pingErr.KeyType = result.KeyType
+
+	start := time.Now()
+	if protocol == ProtocolGRPC {
+		err = pingGRPC(ctx, ep, *headers, pingErr)
+	} else {
+		err = pingHTTP(ctx, config, ep, *headers, pingErr)
+	}
+	result.Latency = time.Since(start)
+	return result, err
+}
+
+// pingHTTP posts an empty OTLP/HTTP trace export request
+func pingHTTP(ctx context.Context, config InstrumentationConfig, ep endpoint, headers map[string]string, pingErr *PingError) error {
+	proxy, err := getProxy(config)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+	client := &http.Client{Transport: transport}
+
+	// An empty ExportTraceServiceRequest encodes to zero bytes
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, otlpHTTPURL(ep, "/v1/traces"), bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		pingErr.Err, pingErr.Cause = ErrEndpointUnreachable, err
+		return pingErr
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		pingErr.Err = ErrInvalidAPIKey
+	default:
+		pingErr.Err = ErrUnexpectedResponse
+	}
+	pingErr.Status = resp.Status
+	return pingErr
+}
+
+// pingGRPC calls the OTLP trace service with an empty export request
+func pingGRPC(ctx context.Context, ep endpoint, headers map[string]string, pingErr *PingError) error {
+	creds := credentials.NewTLS(nil)
+	if ep.insecure {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(ep.host, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(headers))
+	_, err = coltracepb.NewTraceServiceClient(conn).Export(ctx, &coltracepb.ExportTraceServiceRequest{})
+	if err == nil {
+		return nil
+	}
+
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		pingErr.Err = ErrInvalidAPIKey
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		pingErr.Err, pingErr.Cause = ErrEndpointUnreachable, err
+	default:
+		pingErr.Err = ErrUnexpectedResponse
+	}
+	pingErr.Status = st.Code().String()
+	return pingErr
+}
@@ -0,0 +1,280 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Profile types
+const (
+	ProfileTypeCPU  = "cpu"
+	ProfileTypeHeap = "heap"
+)
+
+// ProfilingConfig configures continuous profiling. Zero durations default to a profile every
+// minute with 10 seconds of CPU sampling.
+type ProfilingConfig struct {
+	// Interval is how often profiles are captured
+	Interval time.Duration
+	// CPUDuration is how long each CPU profile samples for
+	CPUDuration time.Duration
+	DisableCPU  bool
+	DisableHeap bool
+}
+
+// profileUploadTimeout bounds each profile upload
+const profileUploadTimeout = 30 * time.Second
+
+// profileUploader uploads profiles to IUDEX. Profiles are labeled with the resource attributes
+// shared by traces, so IUDEX can correlate them with the traces of the same service and time window.
+type profileUploader struct {
+	url      string
+	headers  map[string]string
+	resource map[string]string
+	client   *http.Client
+}
+
+// activeProfileUploader is the uploader of the SDK set up last, used by CaptureProfile
+var activeProfileUploader atomic.Pointer[profileUploader]
+
+func newProfileUploader(endpointURL string, headers map[string]string, res *resource.Resource, proxy func(*http.Request) (*url.URL, error)) *profileUploader {
+	attrs := map[string]string{}
+	for _, attr := range res.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+	return &profileUploader{
+		url:      endpointURL,
+		headers:  headers,
+		resource: attrs,
+		client:   &http.Client{Transport: transport, Timeout: profileUploadTimeout},
+	}
+}
+
+// profiler periodically captures pprof profiles and uploads them to IUDEX
+type profiler struct {
+	config   ProfilingConfig
+	uploader *profileUploader
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newProfiler starts profiling in the background
+func newProfiler(config ProfilingConfig, uploader *profileUploader) *profiler {
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	if config.CPUDuration <= 0 {
+		config.CPUDuration = 10 * time.Second
+	}
+	config.CPUDuration = min(config.CPUDuration, config.Interval)
+
+	p := &profiler{
+		config:   config,
+		uploader: uploader,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *profiler) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		if !p.config.DisableCPU {
+			p.captureCPU()
+		}
+		if !p.config.DisableHeap {
+			p.captureHeap()
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// captureCPU samples the CPU for CPUDuration, or until the profiler stops
+func (p *profiler) captureCPU() {
+	var buf bytes.Buffer
+	start := time.Now()
+	// Fails when the application is already taking a CPU profile, e.g. through net/http/pprof
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		otel.Handle(fmt.Errorf("profiler: %w", err))
+		return
+	}
+	timer := time.NewTimer(p.config.CPUDuration)
+	select {
+	case <-p.stop:
+	case <-timer.C:
+	}
+	timer.Stop()
+	pprof.StopCPUProfile()
+
+	p.uploader.upload(profileMetadata{Type: ProfileTypeCPU, Start: start, End: time.Now()}, buf.Bytes())
+}
+
+func (p *profiler) captureHeap() {
+	var buf bytes.Buffer
+	now := time.Now()
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		otel.Handle(fmt.Errorf("profiler: %w", err))
+		return
+	}
+	p.uploader.upload(profileMetadata{Type: ProfileTypeHeap, Start: now, End: now}, buf.Bytes())
+}
+
+// profileMetadata describes an uploaded profile
+type profileMetadata struct {
+	Type     string            `json:"type"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	Resource map[string]string `json:"resource"`
+	// On-demand profiles name what they were captured for
+	TriggerID  string   `json:"trigger_id,omitempty"`
+	IncidentID string   `json:"incident_id,omitempty"`
+	TraceIDs   []string `json:"trace_ids,omitempty"`
+}
+
+// upload sends a profile, reporting failures through the OTel error handler.
+// Profiles are dropped when the upload fails.
+func (u *profileUploader) upload(metadata profileMetadata, profile []byte) {
+	if err := u.send(metadata, profile); err != nil {
+		otel.Handle(fmt.Errorf("profiler: upload %s profile: %w", metadata.Type, err))
+	}
+}
+
+// send posts a gzipped pprof profile with its metadata as a multipart form
+func (u *profileUploader) send(metadata profileMetadata, profile []byte) error {
+	metadata.Resource = u.resource
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="metadata"`)
+	header.Set("Content-Type", "application/json")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(metadata); err != nil {
+		return err
+	}
+	part, err = form.CreateFormFile("profile", metadata.Type+".pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.url, &body)
+	if err != nil {
+		return err
+	}
+	for key, value := range u.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+// shutdown stops profiling, ending a CPU profile in progress early so it is still uploaded
+func (p *profiler) shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setupProfiling creates the profile uploader used by CaptureProfile and starts continuous
+// profiling and the remote trigger poller when they are configured. It returns a function that
+// stops them, or nil. Profiles are only uploaded over the HTTP protocol, so with gRPC the
+// uploader is left unset unless profiling was asked for.
+func setupProfiling(config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (func(context.Context) error, error) {
+	activeProfileUploader.Store(nil)
+	if isDebug(config) {
+		return nil, nil
+	}
+	triggers := config.ProfileTriggers != nil && *config.ProfileTriggers
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	if protocol != ProtocolHTTP {
+		if config.Profiling != nil || triggers {
+			return nil, fmt.Errorf("profiling requires the %q protocol", ProtocolHTTP)
+		}
+		return nil, nil
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	uploader := newProfileUploader(otlpHTTPURL(ep, "/v1/profiles"), *headers, res, proxy)
+	activeProfileUploader.Store(uploader)
+
+	var shutdownFuncs []func(context.Context) error
+	if config.Profiling != nil {
+		shutdownFuncs = append(shutdownFuncs, newProfiler(*config.Profiling, uploader).shutdown)
+	}
+	if triggers {
+		shutdownFuncs = append(shutdownFuncs, newProfileTriggerPoller(uploader, profileTriggerPollInterval).shutdown)
+	}
+	if len(shutdownFuncs) == 0 {
+		return nil, nil
+	}
+	return func(ctx context.Context) error {
+		var err error
+		for _, fn := range shutdownFuncs {
+			err = errors.Join(err, fn(ctx))
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,306 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// On-demand profile types, in addition to ProfileTypeCPU and ProfileTypeHeap
+const (
+	ProfileTypeGoroutine = "goroutine"
+	ProfileTypeBlock     = "block"
+	ProfileTypeMutex     = "mutex"
+)
+
+// Errors returned by CaptureProfile
+var (
+	ErrProfilingUnavailable = errors.New("profiles can only be uploaded after Setup with the HTTP protocol")
+	ErrProfileInProgress    = errors.New("another on-demand profile is being captured")
+	ErrUnknownProfileType   = errors.New("unknown profile type")
+)
+
+// maxProfileDuration bounds how long an on-demand profile samples for
+const maxProfileDuration = 60 * time.Second
+
+// ProfileRequest describes an on-demand profile
+type ProfileRequest struct {
+	// Type is one of the ProfileType constants
+	Type string
+	// Duration is how long CPU, block, and mutex profiles sample for, 5s by default and at most 60s.
+	// Goroutine and heap profiles are snapshots.
+	Duration time.Duration
+	// IncidentID and TraceIDs correlate the profile with an incident and its traces. TraceIDs
+	// defaults to the trace of the span in the context passed to CaptureProfile.
+	IncidentID string
+	TraceIDs   []string
+	// TriggerID is set for profiles triggered remotely by IUDEX
+	TriggerID string
+}
+
+// onDemandMu allows one on-demand profile at a time, since profiling rates are process-wide
+var onDemandMu sync.Mutex
+
+// CaptureProfile captures one profile and uploads it to IUDEX, e.g. while an incident is in
+// progress. It blocks for the duration of the profile and fails with ErrProfileInProgress
+// while another on-demand profile is captured.
+func CaptureProfile(ctx context.Context, req ProfileRequest) error {
+	uploader := activeProfileUploader.Load()
+	if uploader == nil {
+		return ErrProfilingUnavailable
+	}
+	if !onDemandMu.TryLock() {
+		return ErrProfileInProgress
+	}
+	defer onDemandMu.Unlock()
+
+	if req.Duration <= 0 {
+		req.Duration = 5 * time.Second
+	}
+	req.Duration = min(req.Duration, maxProfileDuration)
+	if req.TraceIDs == nil {
+		if sc := oteltrace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			req.TraceIDs = []string{sc.TraceID().String()}
+		}
+	}
+
+	start := time.Now()
+	profile, err := captureProfile(ctx, req.Type, req.Duration)
+	if err != nil {
+		return err
+	}
+	return uploader.send(profileMetadata{
+		Type:       req.Type,
+		Start:      start,
+		End:        time.Now(),
+		TriggerID:  req.TriggerID,
+		IncidentID: req.IncidentID,
+		TraceIDs:   req.TraceIDs,
+	}, profile)
+}
+
+// captureProfile returns a gzipped pprof profile of the given type
+func captureProfile(ctx context.Context, profileType string, duration time.Duration) ([]byte, error) {
+	var buf bytes.Buffer
+	switch profileType {
+	case ProfileTypeGoroutine, ProfileTypeHeap:
+		if err := pprof.Lookup(profileType).WriteTo(&buf, 0); err != nil {
+			return nil, err
+		}
+	case ProfileTypeCPU:
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, err
+		}
+		sleepContext(ctx, duration)
+		pprof.StopCPUProfile()
+	case ProfileTypeBlock:
+		// There is no way to read the block profile rate, so it is turned off again afterwards
+		runtime.SetBlockProfileRate(1)
+		sleepContext(ctx, duration)
+		err := pprof.Lookup(profileType).WriteTo(&buf, 0)
+		runtime.SetBlockProfileRate(0)
+		if err != nil {
+			return nil, err
+		}
+	case ProfileTypeMutex:
+		previous := runtime.SetMutexProfileFraction(1)
+		sleepContext(ctx, duration)
+		err := pprof.Lookup(profileType).WriteTo(&buf, 0)
+		runtime.SetMutexProfileFraction(previous)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownProfileType, profileType)
+	}
+	return buf.Bytes(), nil
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// ProfileHandler serves a local admin endpoint that captures a profile with CaptureProfile.
+// POST it with the type, seconds, incident, and trace_id (repeatable) query parameters:
+//
+//	mux.Handle("/admin/profile", iudex.ProfileHandler())
+//	curl -X POST 'localhost:8080/admin/profile?type=goroutine&incident=INC-42'
+//
+// Mount it on an internal port only.
+func ProfileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		req := ProfileRequest{
+			Type:       query.Get("type"),
+			IncidentID: query.Get("incident"),
+			TraceIDs:   query["trace_id"],
+		}
+		if seconds := query.Get("seconds"); seconds != "" {
+			n, err := strconv.ParseFloat(seconds, 64)
+			if err != nil {
+				http.Error(w, "invalid seconds", http.StatusBadRequest)
+				return
+			}
+			req.Duration = time.Duration(n * float64(time.Second))
+		}
+
+		err := CaptureProfile(r.Context(), req)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, ErrUnknownProfileType):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, ErrProfileInProgress):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, ErrProfilingUnavailable):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// profileTriggerPollInterval is how often IUDEX is asked for pending profile triggers
+const profileTriggerPollInterval = 30 * time.Second
+
+// profileTrigger is a profile requested by IUDEX
+type profileTrigger struct {
+	ID              string   `json:"id"`
+	Type            string   `json:"type"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	IncidentID      string   `json:"incident_id"`
+	TraceIDs        []string `json:"trace_ids"`
+}
+
+// profileTriggerPoller asks IUDEX for pending profile triggers and captures them
+type profileTriggerPoller struct {
+	uploader *profileUploader
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newProfileTriggerPoller(uploader *profileUploader, interval time.Duration) *profileTriggerPoller {
+	p := &profileTriggerPoller{
+		uploader: uploader,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+func (p *profileTriggerPoller) loop() {
+	defer close(p.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		triggers, err := p.poll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				otel.Handle(fmt.Errorf("profile triggers: %w", err))
+			}
+			continue
+		}
+		for _, trigger := range triggers {
+			err := CaptureProfile(ctx, ProfileRequest{
+				Type:       trigger.Type,
+				Duration:   time.Duration(trigger.DurationSeconds * float64(time.Second)),
+				IncidentID: trigger.IncidentID,
+				TraceIDs:   trigger.TraceIDs,
+				TriggerID:  trigger.ID,
+			})
+			if err != nil && ctx.Err() == nil {
+				otel.Handle(fmt.Errorf("profile trigger %s: %w", trigger.ID, err))
+			}
+		}
+	}
+}
+
+// poll fetches the pending triggers for this service
+func (p *profileTriggerPoller) poll(ctx context.Context) ([]profileTrigger, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.uploader.url+"/triggers", nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range p.uploader.headers {
+		req.Header.Set(key, value)
+	}
+	query := req.URL.Query()
+	for _, key := range []string{"service.name", "service.instance.id", "env"} {
+		if value, ok := p.uploader.resource[key]; ok {
+			query.Set(key, value)
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+
+	resp, err := p.uploader.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	var body struct {
+		Triggers []profileTrigger `json:"triggers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Triggers, nil
+}
+
+func (p *profileTriggerPoller) shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Supported propagators, named as in OTEL_PROPAGATORS
+const (
+	PropagatorTraceContext = "tracecontext"
+	PropagatorBaggage      = "baggage"
+	PropagatorB3           = "b3"      // single b3 header
+	PropagatorB3Multi      = "b3multi" // X-B3-* headers
+	PropagatorJaeger       = "jaeger"
+	PropagatorXRay         = "xray"
+	PropagatorNone         = "none"
+)
+
+// NewPropagatorFromConfig composes the configured propagators, in order. Without configured
+// propagators it uses W3C trace context and baggage, like NewPropagator. Unsupported propagators
+// are reported through the OTel error handler and skipped.
+func NewPropagatorFromConfig(config InstrumentationConfig) propagation.TextMapPropagator {
+	names := []string{PropagatorTraceContext, PropagatorBaggage}
+	if config.Propagators != nil && len(*config.Propagators) > 0 {
+		names = *config.Propagators
+	}
+	// X-Ray mode also accepts and forwards the trace header set by ALB and API Gateway
+	if isXRay(config) && !slices.Contains(names, PropagatorXRay) {
+		names = append(slices.Clip(names), PropagatorXRay)
+	}
+
+	propagators := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{})
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{})
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		case PropagatorXRay:
+			propagators = append(propagators, xray.Propagator{})
+		case PropagatorNone:
+		default:
+			otel.Handle(fmt.Errorf("unsupported propagator %q, skipping it", name))
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+func isXRay(config InstrumentationConfig) bool {
+	return config.XRay != nil && *config.XRay
+}
+
+// Carrier holds propagated trace context and baggage, e.g. the headers of a message
+type Carrier = propagation.TextMapCarrier
+
+// MapCarrier carries trace context in a map[string]string, e.g. a field of a JSON job payload
+type MapCarrier = propagation.MapCarrier
+
+// ValuesCarrier carries trace context in a map[string][]string, e.g. url.Values or the headers
+// of a custom protocol. Unlike propagation.HeaderCarrier, keys are used as is.
+type ValuesCarrier map[string][]string
+
+// Get returns the first value of key
+func (c ValuesCarrier) Get(key string) string {
+	if values := c[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set replaces the values of key with value
+func (c ValuesCarrier) Set(key, value string) {
+	c[key] = []string{value}
+}
+
+// Keys returns the keys in the carrier
+func (c ValuesCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Inject writes the trace context and baggage of ctx into carrier with the configured
+// propagators, for protocols without built-in instrumentation such as webhooks or Redis queues
+func Inject(ctx context.Context, carrier Carrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx with the trace context and baggage read from carrier, so spans started
+// from it continue the sender's trace
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// InjectMap returns the trace context and baggage of ctx as a map, to embed in a payload
+func InjectMap(ctx context.Context) map[string]string {
+	carrier := MapCarrier{}
+	Inject(ctx, carrier)
+	return carrier
+}
@@ -0,0 +1,72 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// panicFlushTimeout bounds how long a crashing process waits for telemetry to export
+const panicFlushTimeout = 5 * time.Second
+
+// Recover records a panic in flight on the span in ctx, emits an error log with the stack trace,
+// flushes all telemetry, and re-panics. It must be deferred directly: defer iudex.Recover(ctx).
+func Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		recordPanic(ctx, r, nil)
+		panic(r)
+	}
+}
+
+// HTTPRecoverMiddleware recovers panics in handler, records them like Recover, and responds with
+// 500 unless the handler already started the response. Wrap it with HTTPMiddleware so the panic
+// is recorded on the request span: iudex.HTTPMiddleware(iudex.HTTPRecoverMiddleware(mux)).
+func HTTPRecoverMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler is how handlers abort a response on purpose
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			recordPanic(r.Context(), rec, nil)
+			if rw.status == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// recordPanic records the recovered value as an escaped exception and flushes telemetry,
+// since the process may be about to exit. end, if not nil, is called before the flush, e.g. to
+// end the span so it is flushed too.
+func recordPanic(ctx context.Context, rec any, end func()) {
+	err, ok := rec.(error)
+	if !ok {
+		err = fmt.Errorf("panic: %v", rec)
+	}
+	RecordError(ctx, err,
+		WithErrorLog(),
+		WithErrorAttributes(semconv.ExceptionEscaped(true)),
+		WithStackSkip(2),
+	)
+	if end != nil {
+		end()
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), panicFlushTimeout)
+	defer cancel()
+	if err := ForceFlush(flushCtx); err != nil {
+		otel.Handle(err)
+	}
+}
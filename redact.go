@@ -0,0 +1,208 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const redactedValue = "[REDACTED]"
+
+// RedactionRule replaces the parts of attribute values that match Pattern
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Built-in redaction rules
+var (
+	RedactEmails       = RedactionRule{Name: "email", Pattern: regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)}
+	RedactCreditCards  = RedactionRule{Name: "credit_card", Pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)}
+	RedactBearerTokens = RedactionRule{Name: "bearer_token", Pattern: regexp.MustCompile(`(?i)bearer\s+[a-zA-Z0-9\-._~+/]+=*`)}
+)
+
+// NewRedactionRule creates a rule that redacts text matching the given regular expression
+func NewRedactionRule(name, pattern string) (RedactionRule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RedactionRule{}, fmt.Errorf("invalid redaction pattern for %q: %w", name, err)
+	}
+	return RedactionRule{Name: name, Pattern: re}, nil
+}
+
+// redact applies every rule to s
+func redact(rules []RedactionRule, s string) string {
+	for _, rule := range rules {
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = redactedValue
+		}
+		s = rule.Pattern.ReplaceAllLiteralString(s, replacement)
+	}
+	return s
+}
+
+// redactAttributes returns attrs with string values redacted
+func redactAttributes(rules []RedactionRule, attrs []attribute.KeyValue) []attribute.KeyValue {
+	redacted := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			redacted[i] = attribute.String(string(attr.Key), redact(rules, attr.Value.AsString()))
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for j, v := range values {
+				values[j] = redact(rules, v)
+			}
+			redacted[i] = attribute.StringSlice(string(attr.Key), values)
+		default:
+			redacted[i] = attr
+		}
+	}
+	return redacted
+}
+
+// redactLogValue returns v with string values redacted, recursing into slices and maps
+func redactLogValue(rules []RedactionRule, v otellog.Value) otellog.Value {
+	switch v.Kind() {
+	case otellog.KindString:
+		return otellog.StringValue(redact(rules, v.AsString()))
+	case otellog.KindSlice:
+		values := v.AsSlice()
+		redacted := make([]otellog.Value, len(values))
+		for i, item := range values {
+			redacted[i] = redactLogValue(rules, item)
+		}
+		return otellog.SliceValue(redacted...)
+	case otellog.KindMap:
+		kvs := v.AsMap()
+		redacted := make([]otellog.KeyValue, len(kvs))
+		for i, kv := range kvs {
+			redacted[i] = otellog.KeyValue{Key: kv.Key, Value: redactLogValue(rules, kv.Value)}
+		}
+		return otellog.MapValue(redacted...)
+	default:
+		return v
+	}
+}
+
+// activeRedactionRules are the rules of the redaction processors set up by SetupOTelSDK
+var activeRedactionRules atomic.Pointer[[]RedactionRule]
+
+// SetRedactionRules replaces the redaction rules of the SDK at runtime. Spans and log records
+// exported afterwards are redacted with the new rules.
+func SetRedactionRules(rules ...RedactionRule) {
+	activeRedactionRules.Store(&rules)
+}
+
+// RedactionRules returns the current redaction rules of the SDK
+func RedactionRules() []RedactionRule {
+	if rules := activeRedactionRules.Load(); rules != nil {
+		return *rules
+	}
+	return nil
+}
+
+// redactingSpanProcessor redacts span names, status descriptions, attributes, and events before
+// handing spans to the next processor
+type redactingSpanProcessor struct {
+	trace.SpanProcessor
+	rules func() []RedactionRule
+}
+
+// NewRedactingSpanProcessor wraps next so the spans it exports have their names, status
+// descriptions, and attributes redacted
+func NewRedactingSpanProcessor(next trace.SpanProcessor, rules ...RedactionRule) trace.SpanProcessor {
+	return &redactingSpanProcessor{SpanProcessor: next, rules: func() []RedactionRule { return rules }}
+}
+
+func (p *redactingSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	rules := p.rules()
+	if len(rules) == 0 {
+		p.SpanProcessor.OnEnd(s)
+		return
+	}
+	p.SpanProcessor.OnEnd(&redactedSpan{ReadOnlySpan: s, rules: rules})
+}
+
+// redactedSpan overrides the text carrying parts of a ReadOnlySpan
+type redactedSpan struct {
+	trace.ReadOnlySpan
+	rules []RedactionRule
+}
+
+func (s *redactedSpan) Name() string {
+	return redact(s.rules, s.ReadOnlySpan.Name())
+}
+
+func (s *redactedSpan) Status() trace.Status {
+	status := s.ReadOnlySpan.Status()
+	status.Description = redact(s.rules, status.Description)
+	return status
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return redactAttributes(s.rules, s.ReadOnlySpan.Attributes())
+}
+
+func (s *redactedSpan) Events() []trace.Event {
+	events := s.ReadOnlySpan.Events()
+	redacted := make([]trace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = redactAttributes(s.rules, event.Attributes)
+		redacted[i] = event
+	}
+	return redacted
+}
+
+func (s *redactedSpan) Links() []trace.Link {
+	links := s.ReadOnlySpan.Links()
+	redacted := make([]trace.Link, len(links))
+	for i, link := range links {
+		link.Attributes = redactAttributes(s.rules, link.Attributes)
+		redacted[i] = link
+	}
+	return redacted
+}
+
+// redactingLogProcessor redacts log bodies and attributes in place.
+// It must be registered before the exporting processor.
+type redactingLogProcessor struct {
+	rules func() []RedactionRule
+}
+
+// NewRedactingLogProcessor creates a log processor that redacts records before they are exported
+func NewRedactingLogProcessor(rules ...RedactionRule) log.Processor {
+	return &redactingLogProcessor{rules: func() []RedactionRule { return rules }}
+}
+
+func (p *redactingLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	rules := p.rules()
+	if len(rules) == 0 {
+		return nil
+	}
+	record.SetBody(redactLogValue(rules, record.Body()))
+
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs = append(attrs, otellog.KeyValue{Key: kv.Key, Value: redactLogValue(rules, kv.Value)})
+		return true
+	})
+	record.SetAttributes(attrs...)
+	return nil
+}
+
+func (p *redactingLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *redactingLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
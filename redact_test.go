@@ -0,0 +1,167 @@
+package iudex
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestRedact(t *testing.T) {
+	rules := []RedactionRule{RedactEmails, RedactCreditCards, RedactBearerTokens}
+	tests := []struct {
+		in, want string
+	}{
+		{"contact jane.doe@example.com now", "contact [REDACTED] now"},
+		{"card 4111 1111 1111 1111", "card [REDACTED]"},
+		{"Authorization: Bearer abc.def-123", "Authorization: [REDACTED]"},
+		{"order 42 shipped", "order 42 shipped"},
+	}
+	for _, tt := range tests {
+		if got := redact(rules, tt.in); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	custom := RedactionRule{Name: "email", Pattern: RedactEmails.Pattern, Replacement: "<email>"}
+	if got := redact([]RedactionRule{custom}, "a@b.io"); got != "<email>" {
+		t.Errorf("got %q, want the custom replacement", got)
+	}
+}
+
+func TestNewRedactionRule(t *testing.T) {
+	rule, err := NewRedactionRule("ssn", `\d{3}-\d{2}-\d{4}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := redact([]RedactionRule{rule}, "ssn 123-45-6789"); got != "ssn [REDACTED]" {
+		t.Errorf("got %q", got)
+	}
+	if _, err := NewRedactionRule("bad", "("); err == nil {
+		t.Error("want an error for an invalid pattern")
+	}
+}
+
+func TestRedactingSpanProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(NewRedactingSpanProcessor(recorder, RedactEmails)))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	_, span := provider.Tracer("test").Start(context.Background(), "invite jane@example.com",
+		oteltrace.WithAttributes(
+			attribute.String("user.email", "jane@example.com"),
+			attribute.StringSlice("cc", []string{"a@example.com", "plain"}),
+			attribute.Int("count", 2),
+		),
+		oteltrace.WithLinks(oteltrace.Link{
+			SpanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: [16]byte{1}, SpanID: [8]byte{1}}),
+			Attributes:  []attribute.KeyValue{attribute.String("from", "bob@example.com")},
+		}),
+	)
+	span.AddEvent("sent", oteltrace.WithAttributes(attribute.String("to", "jane@example.com")))
+	span.SetStatus(codes.Error, "no mailbox for jane@example.com")
+	span.End()
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans", len(ended))
+	}
+	s := ended[0]
+	if s.Name() != "invite [REDACTED]" {
+		t.Errorf("got name %q", s.Name())
+	}
+	if s.Status().Description != "no mailbox for [REDACTED]" {
+		t.Errorf("got status description %q", s.Status().Description)
+	}
+	attrs := attribute.NewSet(s.Attributes()...)
+	if v, _ := attrs.Value("user.email"); v.AsString() != "[REDACTED]" {
+		t.Errorf("got user.email %q", v.AsString())
+	}
+	if v, _ := attrs.Value("cc"); v.AsStringSlice()[0] != "[REDACTED]" || v.AsStringSlice()[1] != "plain" {
+		t.Errorf("got cc %v", v.AsStringSlice())
+	}
+	if v, _ := attrs.Value("count"); v.AsInt64() != 2 {
+		t.Errorf("got count %v", v.AsInt64())
+	}
+	if v := s.Events()[0].Attributes[0].Value.AsString(); v != "[REDACTED]" {
+		t.Errorf("got event attribute %q", v)
+	}
+	if v := s.Links()[0].Attributes[0].Value.AsString(); v != "[REDACTED]" {
+		t.Errorf("got link attribute %q", v)
+	}
+}
+
+func TestRedactingLogProcessor(t *testing.T) {
+	collector := &collectingLogProcessor{}
+	provider := log.NewLoggerProvider(
+		log.WithProcessor(NewRedactingLogProcessor(RedactEmails)),
+		log.WithProcessor(collector),
+	)
+
+	var record otellog.Record
+	record.SetBody(otellog.StringValue("signup from jane@example.com"))
+	record.AddAttributes(
+		otellog.String("email", "jane@example.com"),
+		otellog.Map("user",
+			otellog.String("email", "jane@example.com"),
+			otellog.Slice("aliases", otellog.StringValue("j@example.com")),
+		),
+		otellog.Int("age", 30),
+	)
+	provider.Logger("test").Emit(context.Background(), record)
+
+	if len(collector.records) != 1 {
+		t.Fatalf("got %d records", len(collector.records))
+	}
+	got := collector.records[0]
+	if body := got.Body().AsString(); body != "signup from [REDACTED]" {
+		t.Errorf("got body %q", body)
+	}
+	attrs := map[string]otellog.Value{}
+	got.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	if v := attrs["email"].AsString(); v != "[REDACTED]" {
+		t.Errorf("got email %q", v)
+	}
+	user := attrs["user"].AsMap()
+	if v := user[0].Value.AsString(); v != "[REDACTED]" {
+		t.Errorf("got user.email %q", v)
+	}
+	if v := user[1].Value.AsSlice()[0].AsString(); v != "[REDACTED]" {
+		t.Errorf("got user.aliases %q", v)
+	}
+	if v := attrs["age"].AsInt64(); v != 30 {
+		t.Errorf("got age %d", v)
+	}
+}
+
+func TestRedactingSpanProcessorRuntimeRules(t *testing.T) {
+	prev := RedactionRules()
+	t.Cleanup(func() { SetRedactionRules(prev...) })
+
+	recorder := tracetest.NewSpanRecorder()
+	processor := &redactingSpanProcessor{SpanProcessor: recorder, rules: RedactionRules}
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(processor))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+	tracer := provider.Tracer("test")
+
+	SetRedactionRules()
+	_, span := tracer.Start(context.Background(), "jane@example.com")
+	span.End()
+	SetRedactionRules(RedactEmails)
+	_, span = tracer.Start(context.Background(), "jane@example.com")
+	span.End()
+
+	ended := recorder.Ended()
+	if ended[0].Name() != "jane@example.com" || ended[1].Name() != "[REDACTED]" {
+		t.Errorf("got names %q and %q", ended[0].Name(), ended[1].Name())
+	}
+}
@@ -0,0 +1,219 @@
+package iudex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// defaultRemoteSamplingInterval is how often IUDEX is asked for sampling rates
+const defaultRemoteSamplingInterval = time.Minute
+
+// RemoteSamplingConfig fetches sampling rates for the service from IUDEX, so the cost of a whole
+// fleet can be controlled centrally. Rates apply to root spans, by route or span name. Spans that
+// no rate covers, and spans with a parent, are sampled by the configured sampler.
+type RemoteSamplingConfig struct {
+	// Interval is how often the rates are fetched, 1m by default
+	Interval time.Duration
+}
+
+// SamplingRule is a sampling rate set in IUDEX. A rule matches root spans whose route matches
+// Route, a template like /users/{id} where {name...} matches the rest of the path, and whose name
+// is SpanName. Empty fields match any span.
+type SamplingRule struct {
+	Route    string  `json:"route"`
+	SpanName string  `json:"span_name"`
+	Ratio    float64 `json:"ratio"`
+}
+
+// samplingRules are the rates fetched from IUDEX, with a sampler for each rule
+type samplingRules struct {
+	DefaultRatio *float64       `json:"default_ratio"`
+	Rules        []SamplingRule `json:"rules"`
+
+	samplers       []trace.Sampler
+	defaultSampler trace.Sampler
+}
+
+// activeSamplingRules are the remote sampling rates applied by the runtime sampler, nil without
+var activeSamplingRules atomic.Pointer[samplingRules]
+
+// SamplingRules returns the sampling rates fetched from IUDEX, in the order they are matched
+func SamplingRules() []SamplingRule {
+	if rules := activeSamplingRules.Load(); rules != nil {
+		return rules.Rules
+	}
+	return nil
+}
+
+// parseSamplingRules decodes the rates returned by IUDEX
+func parseSamplingRules(data []byte) (*samplingRules, error) {
+	rules := &samplingRules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules.Rules {
+		if rule.Ratio < 0 || rule.Ratio > 1 {
+			return nil, fmt.Errorf("sampling ratio %g of route %q is not between 0 and 1", rule.Ratio, rule.Route)
+		}
+		rules.samplers = append(rules.samplers, trace.TraceIDRatioBased(rule.Ratio))
+	}
+	if rules.DefaultRatio != nil {
+		if *rules.DefaultRatio < 0 || *rules.DefaultRatio > 1 {
+			return nil, fmt.Errorf("default sampling ratio %g is not between 0 and 1", *rules.DefaultRatio)
+		}
+		rules.defaultSampler = trace.TraceIDRatioBased(*rules.DefaultRatio)
+	}
+	return rules, nil
+}
+
+// sampler returns the sampler of the first rule matching a root span, the default sampler when
+// none does, or nil for spans with a parent and when there is no default
+func (r *samplingRules) sampler(p trace.SamplingParameters) trace.Sampler {
+	if oteltrace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return nil
+	}
+	var route, path string
+	for _, attr := range p.Attributes {
+		switch attr.Key {
+		case semconv.HTTPRouteKey:
+			route = attr.Value.AsString()
+		case semconv.URLPathKey, "http.target":
+			path, _, _ = strings.Cut(attr.Value.AsString(), "?")
+		}
+	}
+	for i, rule := range r.Rules {
+		if rule.SpanName != "" && rule.SpanName != p.Name {
+			continue
+		}
+		if rule.Route != "" && rule.Route != route && !matchRoute(rule.Route, path) {
+			continue
+		}
+		return r.samplers[i]
+	}
+	return r.defaultSampler
+}
+
+// matchRoute reports whether path matches a route template, in which {name} matches one segment
+// and a final {name...} the rest of the path
+func matchRoute(template, path string) bool {
+	if path == "" {
+		return false
+	}
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "...}") {
+			return i == len(templateSegments)-1
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(templateSegments) == len(pathSegments)
+}
+
+// remoteSamplingPoller fetches the sampling rates of the service from IUDEX
+type remoteSamplingPoller struct {
+	source   *remoteConfigSource
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// setupRemoteSampling starts fetching sampling rates when remote sampling is configured and
+// returns a function that stops it, or nil
+func setupRemoteSampling(config InstrumentationConfig, res *resource.Resource, headers *map[string]string) (func(context.Context) error, error) {
+	activeSamplingRules.Store(nil)
+	if config.RemoteSampling == nil || isDebug(config) {
+		return nil, nil
+	}
+	source, err := newRemoteConfigSource(config, res, headers, "/v1/sampling")
+	if err != nil {
+		return nil, fmt.Errorf("remote sampling: %w", err)
+	}
+	p := &remoteSamplingPoller{
+		source:   source,
+		interval: config.RemoteSampling.Interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if p.interval <= 0 {
+		p.interval = defaultRemoteSamplingInterval
+	}
+	go p.loop()
+	return p.shutdown, nil
+}
+
+func (p *remoteSamplingPoller) loop() {
+	defer close(p.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		// The last rates are kept while IUDEX cannot be reached
+		if err := p.poll(ctx); err != nil && ctx.Err() == nil {
+			otel.Handle(fmt.Errorf("remote sampling: %w", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *remoteSamplingPoller) poll(ctx context.Context) error {
+	data, err := p.source.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		activeSamplingRules.Store(nil)
+		return nil
+	}
+	rules, err := parseSamplingRules(data)
+	if err != nil {
+		return err
+	}
+	activeSamplingRules.Store(rules)
+	return nil
+}
+
+func (p *remoteSamplingPoller) shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
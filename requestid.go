@@ -0,0 +1,163 @@
+package iudex
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// RequestIDKey is the attribute and baggage key of the request ID, copied onto spans and log
+// records by default
+const RequestIDKey = attribute.Key("request.id")
+
+// Headers carrying the request and session IDs by default
+const (
+	DefaultRequestIDHeader = "X-Request-Id"
+	DefaultSessionIDHeader = "X-Session-Id"
+)
+
+// maxIDLength bounds IDs read from headers. Longer or malformed IDs are replaced.
+const maxIDLength = 128
+
+// RequestIDConfig configures HTTPRequestIDMiddleware and HTTPRequestIDTransport
+type RequestIDConfig struct {
+	// RequestHeader carries the request ID, DefaultRequestIDHeader when empty
+	RequestHeader string
+	// SessionHeader carries the session ID, DefaultSessionIDHeader when empty
+	SessionHeader string
+	// NoSession leaves the session ID alone, e.g. when it comes from a cookie set with SetSessionID
+	NoSession bool
+	// Generate creates missing request IDs, a UUIDv7 by default
+	Generate func() string
+}
+
+func (c RequestIDConfig) withDefaults() RequestIDConfig {
+	if c.RequestHeader == "" {
+		c.RequestHeader = DefaultRequestIDHeader
+	}
+	if c.SessionHeader == "" {
+		c.SessionHeader = DefaultSessionIDHeader
+	}
+	if c.Generate == nil {
+		c.Generate = newID
+	}
+	return c
+}
+
+// newID returns a UUIDv7, which sorts by creation time
+func newID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// validID reports whether an ID read from a header is safe to use as a baggage value and header
+func validID(id string) bool {
+	if id == "" || len(id) > maxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c <= ' ' || c > '~' || c == ',' || c == ';' || c == '\\' || c == '"' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDFromContext returns the request ID set by HTTPRequestIDMiddleware or EnsureRequestID,
+// or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(string(RequestIDKey)).Value()
+}
+
+// SessionIDFromContext returns the session ID set by HTTPRequestIDMiddleware or SetSessionID, or
+// an empty string
+func SessionIDFromContext(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(string(SessionIDKey)).Value()
+}
+
+// EnsureRequestID returns the request ID in ctx, generating one when there is none, e.g. for
+// jobs and consumers that do not go through HTTPRequestIDMiddleware
+func EnsureRequestID(ctx context.Context) (context.Context, string) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return ctx, id
+	}
+	id := newID()
+	return SetAttribute(ctx, string(RequestIDKey), id), id
+}
+
+// HTTPRequestIDMiddleware gives every request a request ID, and a session ID when the client sent
+// one. IDs are read from the request headers, then from the baggage of the caller, and a missing
+// request ID is generated. A missing session ID is left empty, as a new one per request would not
+// identify a session. Both are set as baggage, so spans and log records carry request.id and
+// session.id here and in downstream services, and are returned in the response headers, so
+// support can search for the ID shown to a customer. Add it inside HTTPMiddleware so the request
+// span carries the IDs too.
+func HTTPRequestIDMiddleware(handler http.Handler, config RequestIDConfig) http.Handler {
+	config = config.withDefaults()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requestID := r.Header.Get(config.RequestHeader)
+		if !validID(requestID) {
+			requestID = RequestIDFromContext(ctx)
+		}
+		if !validID(requestID) {
+			requestID = config.Generate()
+		}
+		ctx = SetAttribute(ctx, string(RequestIDKey), requestID)
+		w.Header().Set(config.RequestHeader, requestID)
+
+		if !config.NoSession {
+			sessionID := r.Header.Get(config.SessionHeader)
+			if !validID(sessionID) {
+				sessionID = SessionIDFromContext(ctx)
+			}
+			if validID(sessionID) {
+				ctx = SetSessionID(ctx, sessionID)
+				w.Header().Set(config.SessionHeader, sessionID)
+			}
+		}
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// HTTPRequestIDTransport wraps rt so outgoing requests carry the request and session IDs of
+// their context in headers, for downstream services that do not read baggage. Headers already
+// set are kept. A nil rt wraps http.DefaultTransport.
+func HTTPRequestIDTransport(rt http.RoundTripper, config RequestIDConfig) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &requestIDTransport{rt: rt, config: config.withDefaults()}
+}
+
+type requestIDTransport struct {
+	rt     http.RoundTripper
+	config RequestIDConfig
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFromContext(req.Context())
+	sessionID := SessionIDFromContext(req.Context())
+	if t.config.NoSession {
+		sessionID = ""
+	}
+	setRequestID := requestID != "" && req.Header.Get(t.config.RequestHeader) == ""
+	setSessionID := sessionID != "" && req.Header.Get(t.config.SessionHeader) == ""
+	if setRequestID || setSessionID {
+		// RoundTrippers must not modify the request they are given
+		req = req.Clone(req.Context())
+		if setRequestID {
+			req.Header.Set(t.config.RequestHeader, requestID)
+		}
+		if setSessionID {
+			req.Header.Set(t.config.SessionHeader, sessionID)
+		}
+	}
+	return t.rt.RoundTrip(req)
+}
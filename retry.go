@@ -0,0 +1,84 @@
+package iudex
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+)
+
+// OpenTelemetry exporter retry defaults
+const (
+	defaultRetryInitialInterval = 5 * time.Second
+	defaultRetryMaxInterval     = 30 * time.Second
+	defaultRetryMaxElapsedTime  = time.Minute
+)
+
+// RetryConfig tunes how an exporter retries failed exports with exponential backoff.
+// Zero durations keep the OpenTelemetry defaults of 5s, 30s, and 1m.
+type RetryConfig struct {
+	// Disabled drops a batch after its first failed export
+	Disabled bool
+	// InitialInterval is the wait before the first retry
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between retries
+	MaxInterval time.Duration
+	// MaxElapsedTime is how long a batch is retried before it is dropped
+	MaxElapsedTime time.Duration
+}
+
+// resolved fills the zero durations with the defaults
+func (r RetryConfig) resolved() RetryConfig {
+	if r.InitialInterval <= 0 {
+		r.InitialInterval = defaultRetryInitialInterval
+	}
+	if r.MaxInterval <= 0 {
+		r.MaxInterval = defaultRetryMaxInterval
+	}
+	if r.MaxElapsedTime <= 0 {
+		r.MaxElapsedTime = defaultRetryMaxElapsedTime
+	}
+	return r
+}
+
+func (r RetryConfig) traceHTTP() otlptracehttp.RetryConfig {
+	r = r.resolved()
+	return otlptracehttp.RetryConfig{
+		Enabled:         !r.Disabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	}
+}
+
+func (r RetryConfig) traceGRPC() otlptracegrpc.RetryConfig {
+	r = r.resolved()
+	return otlptracegrpc.RetryConfig{
+		Enabled:         !r.Disabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	}
+}
+
+func (r RetryConfig) logHTTP() otlploghttp.RetryConfig {
+	r = r.resolved()
+	return otlploghttp.RetryConfig{
+		Enabled:         !r.Disabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	}
+}
+
+func (r RetryConfig) logGRPC() otlploggrpc.RetryConfig {
+	r = r.resolved()
+	return otlploggrpc.RetryConfig{
+		Enabled:         !r.Disabled,
+		InitialInterval: r.InitialInterval,
+		MaxInterval:     r.MaxInterval,
+		MaxElapsedTime:  r.MaxElapsedTime,
+	}
+}
@@ -0,0 +1,153 @@
+package iudex
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Supported sampler types
+const (
+	SamplerAlwaysOn             = "always_on"
+	SamplerAlwaysOff            = "always_off"
+	SamplerTraceIDRatio         = "traceidratio"
+	SamplerParentBasedAlwaysOn  = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff = "parentbased_always_off"
+	SamplerParentBasedRatio     = "parentbased_traceidratio"
+	SamplerRateLimited          = "rate_limited"
+)
+
+// NewSampler creates the trace sampler described by the config.
+// A CustomSampler takes precedence over the other sampler settings.
+func NewSampler(config InstrumentationConfig) (trace.Sampler, error) {
+	if config.CustomSampler != nil {
+		return config.CustomSampler, nil
+	}
+	if config.Sampler == nil {
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	}
+
+	switch *config.Sampler {
+	case SamplerAlwaysOn:
+		return trace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return trace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		return trace.TraceIDRatioBased(getSamplerRatio(config)), nil
+	case SamplerParentBasedAlwaysOn:
+		return trace.ParentBased(trace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return trace.ParentBased(trace.NeverSample()), nil
+	case SamplerParentBasedRatio:
+		return trace.ParentBased(trace.TraceIDRatioBased(getSamplerRatio(config))), nil
+	case SamplerRateLimited:
+		if config.SamplerRateLimit == nil || *config.SamplerRateLimit <= 0 {
+			return nil, fmt.Errorf("sampler %q requires a positive SamplerRateLimit", SamplerRateLimited)
+		}
+		return trace.ParentBased(NewRateLimitedSampler(*config.SamplerRateLimit)), nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler %q", *config.Sampler)
+	}
+}
+
+// activeSampler is the sampler of every tracer provider set up by SetupOTelSDK
+var activeSampler atomic.Pointer[trace.Sampler]
+
+// SetSampler changes the head sampler at runtime, e.g. to lower the sampling ratio while traffic
+// spikes. Spans already started keep their sampling decision. A nil sampler restores the default,
+// ParentBased(AlwaysSample()).
+func SetSampler(sampler trace.Sampler) {
+	if sampler == nil {
+		sampler = trace.ParentBased(trace.AlwaysSample())
+	}
+	activeSampler.Store(&sampler)
+}
+
+// runtimeSampler applies the remote sampling rates to the root spans they cover, and delegates
+// to the sampler set by SetSampler otherwise
+type runtimeSampler struct{}
+
+func (runtimeSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	if rules := activeSamplingRules.Load(); rules != nil {
+		if sampler := rules.sampler(p); sampler != nil {
+			return sampler.ShouldSample(p)
+		}
+	}
+	return (*activeSampler.Load()).ShouldSample(p)
+}
+
+func (runtimeSampler) Description() string {
+	return (*activeSampler.Load()).Description()
+}
+
+func getSamplerRatio(config InstrumentationConfig) float64 {
+	if config.SamplerRatio != nil {
+		return *config.SamplerRatio
+	}
+	return 1.0
+}
+
+// rateLimitedSampler samples at most a fixed number of traces per second using a token bucket
+type rateLimitedSampler struct {
+	mu         sync.Mutex
+	perSecond  float64
+	burst      float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewRateLimitedSampler returns a sampler that samples at most perSecond new traces per second
+func NewRateLimitedSampler(perSecond float64) trace.Sampler {
+	return newRateLimitedSampler(perSecond)
+}
+
+func newRateLimitedSampler(perSecond float64) *rateLimitedSampler {
+	burst := perSecond
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitedSampler{
+		perSecond:  perSecond,
+		burst:      burst,
+		tokens:     burst,
+		lastRefill: time.Now(),
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	decision := trace.Drop
+	if s.take() {
+		decision = trace.RecordAndSample
+	}
+	return trace.SamplingResult{
+		Decision:   decision,
+		Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitedSampler) Description() string {
+	return fmt.Sprintf("RateLimitedSampler{%g}", s.perSecond)
+}
+
+// take reports whether a token was available, refilling the bucket based on elapsed time
+func (s *rateLimitedSampler) take() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.tokens += now.Sub(s.lastRefill).Seconds() * s.perSecond
+	if s.tokens > s.burst {
+		s.tokens = s.burst
+	}
+	s.lastRefill = now
+
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
@@ -0,0 +1,80 @@
+package iudex
+
+import (
+	"net/http"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Messaging operation types for MessagingAttrs
+const (
+	MessagingPublish = "publish"
+	MessagingReceive = "receive"
+	MessagingProcess = "process"
+)
+
+// HTTPAttrs returns the semantic convention attributes of r: the method, scheme, path, server
+// address, user agent, body size, and the http.ServeMux pattern that matched it. It works for
+// requests received and sent. The query string is left out, since it often carries secrets.
+//
+//	span.SetAttributes(iudex.HTTPAttrs(r)...)
+func HTTPAttrs(r *http.Request) []Attribute {
+	scheme := r.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	attrs := []Attribute{
+		semconv.HTTPRequestMethodKey.String(r.Method),
+		semconv.URLScheme(scheme),
+		semconv.URLPath(r.URL.Path),
+		semconv.ServerAddress(host),
+		semconv.UserAgentOriginal(r.UserAgent()),
+	}
+	if r.ContentLength > 0 {
+		attrs = append(attrs, semconv.HTTPRequestBodySize(int(r.ContentLength)))
+	}
+	if route := muxRoute(r.Pattern); route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(route))
+	}
+	return attrs
+}
+
+// DBAttrs returns the semantic convention attributes of a database call: db.system, such as
+// "postgresql", db.query.text, and db.operation.name, the leading keyword of stmt. The query
+// text is redacted like any other attribute.
+//
+//	ctx, span := iudex.StartSpan(ctx, "SELECT users", iudex.DBAttrs("postgresql", query)...)
+func DBAttrs(system, stmt string) []Attribute {
+	attrs := []Attribute{semconv.DBSystemKey.String(system)}
+	if fields := strings.Fields(stmt); len(fields) > 0 {
+		attrs = append(attrs,
+			semconv.DBOperationName(strings.ToUpper(fields[0])),
+			semconv.DBQueryText(stmt),
+		)
+	}
+	return attrs
+}
+
+// MessagingAttrs returns the semantic convention attributes of a messaging operation:
+// messaging.system, such as "kafka", messaging.operation.type, one of MessagingPublish,
+// MessagingReceive, and MessagingProcess, and messaging.destination.name, the queue or topic.
+//
+//	ctx, span := iudex.StartSpan(ctx, "orders publish", iudex.MessagingAttrs("kafka", iudex.MessagingPublish, "orders")...)
+func MessagingAttrs(system, operation, destination string) []Attribute {
+	attrs := []Attribute{
+		semconv.MessagingSystemKey.String(system),
+		semconv.MessagingOperationTypeKey.String(operation),
+	}
+	if destination != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(destination))
+	}
+	return attrs
+}
@@ -0,0 +1,80 @@
+package iudex
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ShutdownOnSignal calls shutdown when the process receives SIGINT or SIGTERM, giving it at most
+// timeout to flush, logs how many spans and log records were dropped, and then re-raises the
+// signal so the process exits as it would have without the handler. Call the returned stop
+// function to unregister the handler, e.g. when the application shuts down on its own.
+// Applications that handle these signals themselves should call shutdown directly instead.
+func ShutdownOnSignal(shutdown func(context.Context) error, timeout time.Duration) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-signals:
+		case <-done:
+			return
+		}
+		signal.Stop(signals)
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := shutdown(ctx)
+		cancel()
+
+		logger := slog.Default()
+		attrs := []any{"signal", sig.String(), "dropped_spans", droppedSpans.Load(), "dropped_logs", droppedLogs.Load()}
+		if err != nil {
+			logger.Error("iudex: telemetry shutdown failed", append(attrs, "error", err)...)
+		} else {
+			logger.Info("iudex: telemetry shut down", attrs...)
+		}
+
+		// With the handler gone the signal takes its default action
+		if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+			os.Exit(1)
+		}
+	}()
+
+	var once atomic.Bool
+	return func() {
+		if once.CompareAndSwap(false, true) {
+			signal.Stop(signals)
+			close(done)
+		}
+	}
+}
+
+// SetupWithGracefulShutdown is Setup followed by ShutdownOnSignal, so telemetry is flushed within
+// timeout when the process is stopped. The returned shutdown can still be deferred for normal exits.
+func SetupWithGracefulShutdown(ctx context.Context, timeout time.Duration, opts ...Option) (func(context.Context) error, error) {
+	shutdown, err := Setup(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signal handler and a deferred call may race, so only the first one shuts down
+	var once sync.Once
+	var shutdownErr error
+	shutdownOnce := func(ctx context.Context) error {
+		once.Do(func() { shutdownErr = shutdown(ctx) })
+		return shutdownErr
+	}
+	stop := ShutdownOnSignal(shutdownOnce, timeout)
+	return func(ctx context.Context) error {
+		stop()
+		return shutdownOnce(ctx)
+	}, nil
+}
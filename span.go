@@ -0,0 +1,102 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/iudexai/iudex-go"
+
+// Attribute is a key-value pair attached to spans
+type Attribute = attribute.KeyValue
+
+// Attr creates a span attribute, converting value to the closest attribute type
+func Attr(key string, value any) Attribute {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case []bool:
+		return attribute.BoolSlice(key, v)
+	case []int:
+		return attribute.IntSlice(key, v)
+	case []int64:
+		return attribute.Int64Slice(key, v)
+	case []float64:
+		return attribute.Float64Slice(key, v)
+	case fmt.Stringer:
+		return attribute.Stringer(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+// Link connects a span to a span of another trace, or of the same trace that is not its parent
+type Link = oteltrace.Link
+
+// Tracer returns the IUDEX tracer from the installed tracer provider
+func Tracer() oteltrace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// StartSpan starts a span as a child of any span in ctx. The caller must end the returned span.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, oteltrace.Span) {
+	return Tracer().Start(ctx, name, oteltrace.WithAttributes(attrs...))
+}
+
+// LinkFrom links to the span in ctx, e.g. the context of a message picked up by a batch. The
+// link is dropped by StartSpanWithLinks when ctx has no span.
+func LinkFrom(ctx context.Context, attrs ...Attribute) Link {
+	return oteltrace.LinkFromContext(ctx, attrs...)
+}
+
+// StartSpanWithLinks starts a span as a child of any span in ctx that links to other spans, so
+// causality is kept when work merges many requests or splits one into many. The caller must end
+// the returned span.
+func StartSpanWithLinks(ctx context.Context, name string, links []Link, attrs ...Attribute) (context.Context, oteltrace.Span) {
+	valid := make([]Link, 0, len(links))
+	for _, link := range links {
+		if link.SpanContext.IsValid() {
+			valid = append(valid, link)
+		}
+	}
+	return Tracer().Start(ctx, name, oteltrace.WithLinks(valid...), oteltrace.WithAttributes(attrs...))
+}
+
+// WithSpan runs fn inside a new span, recording a returned error or panic on the span before ending it
+func WithSpan(ctx context.Context, name string, fn func(context.Context) error, attrs ...Attribute) (err error) {
+	ctx, span := StartSpan(ctx, name, attrs...)
+	defer func() {
+		if r := recover(); r != nil {
+			recordSpanError(span, fmt.Errorf("panic: %v", r))
+			span.End()
+			panic(r)
+		}
+		if err != nil {
+			recordSpanError(span, err)
+		}
+		span.End()
+	}()
+
+	return fn(ctx)
+}
+
+// recordSpanError records err on span and marks the span as failed
+func recordSpanError(span oteltrace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
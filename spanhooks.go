@@ -0,0 +1,34 @@
+package iudex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpanEndFunc is called with every span as it ends, e.g. to record local metrics
+type SpanEndFunc func(span trace.ReadOnlySpan)
+
+// spanEndHookProcessor calls a SpanEndFunc for every ended span
+type spanEndHookProcessor struct {
+	fn SpanEndFunc
+}
+
+// NewSpanEndHookProcessor creates a span processor that calls fn with every span as it ends
+func NewSpanEndHookProcessor(fn SpanEndFunc) trace.SpanProcessor {
+	return spanEndHookProcessor{fn: fn}
+}
+
+func (spanEndHookProcessor) OnStart(context.Context, trace.ReadWriteSpan) {}
+
+func (p spanEndHookProcessor) OnEnd(s trace.ReadOnlySpan) {
+	p.fn(s)
+}
+
+func (spanEndHookProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (spanEndHookProcessor) ForceFlush(context.Context) error {
+	return nil
+}
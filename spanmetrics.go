@@ -0,0 +1,157 @@
+package iudex
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Span metric attributes
+const (
+	SpanNameKey       = attribute.Key("span.name")
+	SpanKindKey       = attribute.Key("span.kind")
+	SpanStatusCodeKey = attribute.Key("status.code")
+)
+
+// spanMetricAttributeKeys are the span attributes copied onto span metrics. They are low cardinality
+// by convention, unlike e.g. url.full.
+var spanMetricAttributeKeys = []attribute.Key{
+	semconv.HTTPRequestMethodKey,
+	semconv.HTTPRouteKey,
+	semconv.HTTPResponseStatusCodeKey,
+	semconv.RPCSystemKey,
+	semconv.RPCServiceKey,
+	semconv.RPCMethodKey,
+	semconv.DBSystemKey,
+	semconv.MessagingSystemKey,
+}
+
+// spanDurationBuckets are the HTTP semantic convention buckets, in seconds
+var spanDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// spanMetricsProcessor records request rate, errors, and duration of server and client spans
+type spanMetricsProcessor struct {
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+	keys     []attribute.Key
+}
+
+// NewSpanMetricsProcessor creates a span processor that derives RED metrics from ending server,
+// client, producer, and consumer spans: the span.calls counter and the span.duration histogram,
+// both keyed by span name, kind, status, and route-like attributes. Register it ahead of any
+// tail sampling so the metrics count every span, and wrap the sampler with RecordingSampler so
+// they include the spans the head sampler drops.
+func NewSpanMetricsProcessor(meter metric.Meter) (trace.SpanProcessor, error) {
+	return newSpanMetricsProcessor(meter, spanMetricAttributeKeys)
+}
+
+// newSpanMetricsProcessor creates a span metrics processor that copies the span attributes of keys
+func newSpanMetricsProcessor(meter metric.Meter, keys []attribute.Key) (trace.SpanProcessor, error) {
+	calls, err := meter.Int64Counter("span.calls",
+		metric.WithDescription("Number of server and client spans"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("span.duration",
+		metric.WithDescription("Duration of server and client spans"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(spanDurationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &spanMetricsProcessor{calls: calls, duration: duration, keys: keys}, nil
+}
+
+func (p *spanMetricsProcessor) OnStart(context.Context, trace.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(s trace.ReadOnlySpan) {
+	kind := s.SpanKind()
+	if kind == oteltrace.SpanKindInternal || kind == oteltrace.SpanKindUnspecified {
+		return
+	}
+
+	attrs := make([]attribute.KeyValue, 0, 3+len(p.keys))
+	attrs = append(attrs,
+		SpanNameKey.String(s.Name()),
+		SpanKindKey.String(kind.String()),
+		SpanStatusCodeKey.String(spanStatusCode(s.Status().Code)),
+	)
+	for _, attr := range s.Attributes() {
+		for _, key := range p.keys {
+			if attr.Key == key {
+				attrs = append(attrs, attr)
+				break
+			}
+		}
+	}
+
+	ctx := context.Background()
+	opt := metric.WithAttributes(attrs...)
+	p.calls.Add(ctx, 1, opt)
+	p.duration.Record(ctx, s.EndTime().Sub(s.StartTime()).Seconds(), opt)
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// spanStatusCode names the status code like the OTLP STATUS_CODE_* values
+func spanStatusCode(code codes.Code) string {
+	switch code {
+	case codes.Ok:
+		return "ok"
+	case codes.Error:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// RecordingSampler wraps a sampler so the spans it drops are still recorded, but not sampled.
+// Span processors such as the span metrics one then see every span, while the batch processors
+// export the sampled ones only.
+func RecordingSampler(sampler trace.Sampler) trace.Sampler {
+	return recordingSampler{Sampler: sampler}
+}
+
+type recordingSampler struct {
+	trace.Sampler
+}
+
+func (s recordingSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	result := s.Sampler.ShouldSample(p)
+	if result.Decision == trace.Drop {
+		result.Decision = trace.RecordOnly
+	}
+	return result
+}
+
+// sampledSpanProcessor hands only sampled spans to the processor it wraps, hiding the spans
+// recordingSampler records just for span metrics
+type sampledSpanProcessor struct {
+	trace.SpanProcessor
+}
+
+func (p sampledSpanProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	if s.SpanContext().IsSampled() {
+		p.SpanProcessor.OnStart(parent, s)
+	}
+}
+
+func (p sampledSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.SpanProcessor.OnEnd(s)
+	}
+}
@@ -0,0 +1,89 @@
+package iudex
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// SpanStatusFunc decides the status of a server span from the HTTP response status code and the
+// error returned by the handler, nil when there is none. It returns the span status code and,
+// for codes.Error, its description.
+type SpanStatusFunc func(status int, err error) (codes.Code, string)
+
+// spanStatusFunc is the mapping used by the bundled middleware, DefaultSpanStatus when nil
+var spanStatusFunc atomic.Pointer[SpanStatusFunc]
+
+// SetSpanStatusFunc changes how the bundled HTTP middleware set the status of request spans,
+// e.g. to treat 429 responses as errors. A nil fn restores DefaultSpanStatus.
+//
+//	iudex.SetSpanStatusFunc(func(status int, err error) (codes.Code, string) {
+//		if status == http.StatusTooManyRequests {
+//			return codes.Error, http.StatusText(status)
+//		}
+//		return iudex.DefaultSpanStatus(status, err)
+//	})
+func SetSpanStatusFunc(fn SpanStatusFunc) {
+	if fn == nil {
+		spanStatusFunc.Store(nil)
+		return
+	}
+	spanStatusFunc.Store(&fn)
+}
+
+// DefaultSpanStatus follows the semantic conventions for server spans: 5xx responses fail the span,
+// described by the handler error when there is one, and every other response leaves the status
+// unset. Handler errors are still recorded as exceptions, so errors behind 4xx responses such as
+// a not found error remain visible without failing the span.
+func DefaultSpanStatus(status int, err error) (codes.Code, string) {
+	if status < http.StatusInternalServerError {
+		return codes.Unset, ""
+	}
+	if err != nil {
+		return codes.Error, err.Error()
+	}
+	return codes.Error, http.StatusText(status)
+}
+
+// spanStatus maps a response with the current SpanStatusFunc
+func spanStatus(status int, err error) (codes.Code, string) {
+	if fn := spanStatusFunc.Load(); fn != nil {
+		return (*fn)(status, err)
+	}
+	return DefaultSpanStatus(status, err)
+}
+
+// spanStatusHandler applies the SpanStatusFunc to the spans of HTTPMiddleware. otelhttp sets the
+// default status once the handler returns, and a span keeps the highest of the statuses set on it
+// (Ok over Error over Unset), so responses that otelhttp would fail but the mapping leaves unset
+// are marked Ok to keep them from failing.
+func spanStatusHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if spanStatusFunc.Load() == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rw := &responseRecorder{ResponseWriter: w}
+		handler.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span := oteltrace.SpanFromContext(r.Context())
+		code, description := spanStatus(status, nil)
+		if code == codes.Unset && status >= http.StatusInternalServerError && !failed(span) {
+			code = codes.Ok
+		}
+		span.SetStatus(code, description)
+	})
+}
+
+// failed reports whether span was already marked as failed, e.g. by RecordError in the handler
+func failed(span oteltrace.Span) bool {
+	ro, ok := span.(sdktrace.ReadOnlySpan)
+	return ok && ro.Status().Code == codes.Error
+}
@@ -0,0 +1,416 @@
+package iudex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultDiskBufferMaxBytes caps the disk buffer when DiskBufferConfig.MaxBytes is not set
+const DefaultDiskBufferMaxBytes = 64 << 20
+
+// Replay backoff bounds of the disk buffer
+const (
+	spoolMinBackoff    = 5 * time.Second
+	spoolMaxBackoff    = 5 * time.Minute
+	spoolReplayTimeout = 10 * time.Second
+)
+
+// DiskBufferConfig spools spans and logs that fail to export to disk and replays them
+// once the endpoint is reachable again. Only the IUDEX endpoint over ProtocolHTTP is buffered.
+type DiskBufferConfig struct {
+	// Dir holds the buffered payloads, one subdirectory per signal
+	Dir string
+	// MaxBytes caps the size of each signal's buffer, dropping the oldest payloads first.
+	// Defaults to DefaultDiskBufferMaxBytes.
+	MaxBytes int64
+}
+
+// diskSpool is a directory of encoded OTLP export requests waiting to be sent, replayed
+// in the order they were written
+type diskSpool struct {
+	dir      string
+	maxBytes int64
+	url      string
+	headers  map[string]string
+	gzip     bool
+	client   *http.Client
+
+	mu   sync.Mutex // guards the files in dir
+	seq  atomic.Uint64
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newDiskSpool creates the spool directory and starts replaying whatever it already holds,
+// e.g. payloads left behind by a previous run
+func newDiskSpool(dir string, maxBytes int64, endpointURL string, headers map[string]string, gzip bool, proxy func(*http.Request) (*url.URL, error)) (*diskSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("disk buffer: %w", err)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultDiskBufferMaxBytes
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+
+	s := &diskSpool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		url:      endpointURL,
+		headers:  headers,
+		gzip:     gzip,
+		client:   &http.Client{Transport: transport, Timeout: spoolReplayTimeout},
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go s.replayLoop()
+	return s, nil
+}
+
+// write stores payload for replay, evicting the oldest payloads to stay under the size cap
+func (s *diskSpool) write(payload []byte) error {
+	if int64(len(payload)) > s.maxBytes {
+		return fmt.Errorf("disk buffer: payload of %d bytes exceeds the %d byte cap", len(payload), s.maxBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Names sort in write order; the sequence number breaks ties within a clock tick
+	name := fmt.Sprintf("%020d-%06d.pb", time.Now().UnixNano(), s.seq.Add(1)%1e6)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := os.WriteFile(tmp, payload, 0o600); err != nil {
+		return fmt.Errorf("disk buffer: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("disk buffer: %w", err)
+	}
+	return s.evict()
+}
+
+// evict removes the oldest payloads until the spool fits under the size cap. Callers hold mu.
+func (s *diskSpool) evict() error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, file := range files {
+		total += file.size
+	}
+	for _, file := range files {
+		if total <= s.maxBytes {
+			break
+		}
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("disk buffer: %w", err)
+		}
+		total -= file.size
+	}
+	return nil
+}
+
+type spoolFile struct {
+	path string
+	size int64
+}
+
+// files lists the buffered payloads, oldest first
+func (s *diskSpool) files() ([]spoolFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("disk buffer: %w", err)
+	}
+	files := make([]spoolFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pb") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, spoolFile{path: filepath.Join(s.dir, entry.Name()), size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// notify wakes the replay loop, e.g. after an export succeeded and the endpoint is reachable again
+func (s *diskSpool) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// replayLoop sends buffered payloads until the spool is empty, backing off exponentially
+// while the endpoint stays unreachable
+func (s *diskSpool) replayLoop() {
+	defer close(s.done)
+
+	backoff := spoolMinBackoff
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		if s.replay() {
+			backoff = spoolMinBackoff
+		} else {
+			backoff = min(backoff*2, spoolMaxBackoff)
+		}
+		timer.Reset(backoff)
+	}
+}
+
+// replay sends the buffered payloads in order, stopping at the first retryable failure.
+// It reports whether the spool was drained.
+func (s *diskSpool) replay() bool {
+	s.mu.Lock()
+	files, err := s.files()
+	s.mu.Unlock()
+	if err != nil {
+		otel.Handle(err)
+		return false
+	}
+
+	for _, file := range files {
+		select {
+		case <-s.stop:
+			return false
+		default:
+		}
+
+		payload, err := os.ReadFile(file.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // evicted in the meantime
+		}
+		if err != nil {
+			otel.Handle(fmt.Errorf("disk buffer: %w", err))
+			return false
+		}
+		if err := s.send(payload); err != nil {
+			var rejected *rejectedPayloadError
+			if !errors.As(err, &rejected) {
+				return false
+			}
+			// The endpoint will never accept this payload, so stop retrying it
+			otel.Handle(err)
+		}
+
+		s.mu.Lock()
+		err = os.Remove(file.path)
+		s.mu.Unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			otel.Handle(fmt.Errorf("disk buffer: %w", err))
+			return false
+		}
+	}
+	return true
+}
+
+// rejectedPayloadError is a non-retryable response to a replayed payload
+type rejectedPayloadError struct {
+	status string
+}
+
+func (e *rejectedPayloadError) Error() string {
+	return "disk buffer: dropping payload rejected with " + e.status
+}
+
+// send posts one payload to the OTLP/HTTP endpoint
+func (s *diskSpool) send(payload []byte) error {
+	if s.gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(payload); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		payload = buf.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if s.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return fmt.Errorf("disk buffer: replay failed with %s", resp.Status)
+	default:
+		return &rejectedPayloadError{status: resp.Status}
+	}
+}
+
+// close stops replaying. Buffered payloads stay on disk for the next run.
+func (s *diskSpool) close(ctx context.Context) error {
+	s.once.Do(func() { close(s.stop) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// otlpHTTPURL builds the OTLP/HTTP URL for the signal path, e.g. /v1/traces
+func otlpHTTPURL(ep endpoint, signalPath string) string {
+	scheme := "https"
+	if ep.insecure {
+		scheme = "http"
+	}
+	return scheme + "://" + ep.host + ep.path + signalPath
+}
+
+// spoolingSpanExporter buffers spans to disk when the wrapped exporter fails
+type spoolingSpanExporter struct {
+	trace.SpanExporter
+	spool *diskSpool
+}
+
+func (e *spoolingSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err == nil {
+		e.spool.notify()
+		return nil
+	}
+	payload, encErr := encodeSpans(spans)
+	if encErr == nil {
+		encErr = e.spool.write(payload)
+	}
+	if encErr != nil {
+		return errors.Join(err, encErr)
+	}
+	// The batch is not lost, so the export is reported without failing it
+	otel.Handle(fmt.Errorf("%w (buffered to disk)", err))
+	return nil
+}
+
+func (e *spoolingSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.spool.close(ctx), e.SpanExporter.Shutdown(ctx))
+}
+
+// spoolingLogExporter buffers log records to disk when the wrapped exporter fails
+type spoolingLogExporter struct {
+	log.Exporter
+	spool *diskSpool
+}
+
+func (e *spoolingLogExporter) Export(ctx context.Context, records []log.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	if err == nil {
+		e.spool.notify()
+		return nil
+	}
+	payload, encErr := encodeLogs(records)
+	if encErr == nil {
+		encErr = e.spool.write(payload)
+	}
+	if encErr != nil {
+		return errors.Join(err, encErr)
+	}
+	// The batch is not lost, so the export is reported without failing it
+	otel.Handle(fmt.Errorf("%w (buffered to disk)", err))
+	return nil
+}
+
+func (e *spoolingLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.spool.close(ctx), e.Exporter.Shutdown(ctx))
+}
+
+// newDiskSpoolFor creates the spool of one signal for the IUDEX endpoint, or nil when disk buffering is off
+func newDiskSpoolFor(config InstrumentationConfig, headers *map[string]string, signal string) (*diskSpool, error) {
+	if config.DiskBuffer == nil || isDebug(config) {
+		return nil, nil
+	}
+	protocol, err := getProtocol(config)
+	if err != nil {
+		return nil, err
+	}
+	// Spooled batches are replayed over OTLP/HTTP, so over gRPC the buffer is left off
+	if protocol != ProtocolHTTP {
+		otel.Handle(fmt.Errorf("disk buffer requires the %q protocol, %s is exported without it", ProtocolHTTP, signal))
+		return nil, nil
+	}
+	ep, err := getEndpoint(config)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := getProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	return newDiskSpool(
+		filepath.Join(config.DiskBuffer.Dir, signal),
+		config.DiskBuffer.MaxBytes,
+		otlpHTTPURL(ep, "/v1/"+signal),
+		*headers,
+		isGzip(config),
+		proxy,
+	)
+}
+
+// withTraceDiskBuffer wraps exp with a disk buffer when one is configured
+func withTraceDiskBuffer(config InstrumentationConfig, headers *map[string]string, exp trace.SpanExporter) (trace.SpanExporter, error) {
+	spool, err := newDiskSpoolFor(config, headers, "traces")
+	if err != nil || spool == nil {
+		return exp, err
+	}
+	return &spoolingSpanExporter{SpanExporter: exp, spool: spool}, nil
+}
+
+// withLogDiskBuffer wraps exp with a disk buffer when one is configured
+func withLogDiskBuffer(config InstrumentationConfig, headers *map[string]string, exp log.Exporter) (log.Exporter, error) {
+	spool, err := newDiskSpoolFor(config, headers, "logs")
+	if err != nil || spool == nil {
+		return exp, err
+	}
+	return &spoolingLogExporter{Exporter: exp, spool: spool}, nil
+}
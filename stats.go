@@ -0,0 +1,304 @@
+package iudex
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Export counters, across all exporters
+var (
+	exportedSpans     atomic.Int64
+	droppedSpans      atomic.Int64
+	spanExports       atomic.Int64
+	failedSpanExports atomic.Int64
+	exportedLogs      atomic.Int64
+	droppedLogs       atomic.Int64
+	logExports        atomic.Int64
+	failedLogExports  atomic.Int64
+)
+
+// The queues of the batch processors of the current providers, replaced on every setup
+var (
+	spanQueues atomic.Pointer[[]*exportQueue]
+	logQueues  atomic.Pointer[[]*exportQueue]
+)
+
+// Default queue and batch sizes of the OpenTelemetry batch processors
+const (
+	defaultMaxQueueSize       = 2048
+	defaultMaxExportBatchSize = 512
+)
+
+// TelemetryStats reports the health of the export pipeline, to detect telemetry that is silently lost
+type TelemetryStats struct {
+	// ExportedSpans and ExportedLogs were accepted by an exporter
+	ExportedSpans int64
+	ExportedLogs  int64
+	// DroppedSpans and DroppedLogs were lost to failed exports or full queues
+	DroppedSpans int64
+	DroppedLogs  int64
+
+	// SpanExports and LogExports count export calls, of which FailedSpanExports and FailedLogExports failed
+	SpanExports       int64
+	FailedSpanExports int64
+	LogExports        int64
+	FailedLogExports  int64
+
+	// QueuedSpans and QueuedLogs are waiting in batch processors to be exported
+	QueuedSpans int64
+	QueuedLogs  int64
+	// SpanQueueSaturation and LogQueueSaturation are the fill level of the fullest queue, from 0 to 1.
+	// New records are dropped at 1.
+	SpanQueueSaturation float64
+	LogQueueSaturation  float64
+
+	// NormalizedSpanNames and NormalizedAttributeKeys were normalized by the cardinality guard, whose
+	// CardinalityOffenders are the normalized forms of the current and previous windows, e.g.
+	// "span name GET /users/{n}"
+	NormalizedSpanNames     int64
+	NormalizedAttributeKeys int64
+	CardinalityOffenders    []string
+}
+
+// SpanExportErrorRate returns the fraction of span exports that failed
+func (s TelemetryStats) SpanExportErrorRate() float64 {
+	return ratio(s.FailedSpanExports, s.SpanExports)
+}
+
+// LogExportErrorRate returns the fraction of log exports that failed
+func (s TelemetryStats) LogExportErrorRate() float64 {
+	return ratio(s.FailedLogExports, s.LogExports)
+}
+
+func ratio(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// Stats returns the counters of the export pipeline since the process started. The queue
+// figures are estimates, since the batch processors do not expose their queues.
+func Stats() TelemetryStats {
+	stats := TelemetryStats{
+		ExportedSpans:     exportedSpans.Load(),
+		ExportedLogs:      exportedLogs.Load(),
+		DroppedSpans:      droppedSpans.Load(),
+		DroppedLogs:       droppedLogs.Load(),
+		SpanExports:       spanExports.Load(),
+		FailedSpanExports: failedSpanExports.Load(),
+		LogExports:        logExports.Load(),
+		FailedLogExports:  failedLogExports.Load(),
+
+		NormalizedSpanNames:     normalizedSpanNames.Load(),
+		NormalizedAttributeKeys: normalizedAttributeKeys.Load(),
+	}
+	if guard := cardinalityGuards.Load(); guard != nil {
+		stats.CardinalityOffenders = guard.recentOffenders()
+	}
+	stats.QueuedSpans, stats.SpanQueueSaturation = queueStats(spanQueues.Load())
+	stats.QueuedLogs, stats.LogQueueSaturation = queueStats(logQueues.Load())
+	return stats
+}
+
+func queueStats(queues *[]*exportQueue) (queued int64, saturation float64) {
+	if queues == nil {
+		return 0, 0
+	}
+	for _, q := range *queues {
+		pending := q.pending.Load()
+		queued += pending
+		saturation = max(saturation, min(float64(pending)/float64(q.size), 1))
+	}
+	return queued, saturation
+}
+
+// exportQueue estimates the fill level of a batch processor from the records handed to it and
+// the records its exporter received
+type exportQueue struct {
+	// size is the queue size; capacity adds the batch being exported, which has left the queue
+	size     int64
+	capacity int64
+	pending  atomic.Int64
+	dropped  *atomic.Int64
+}
+
+func newExportQueue(batch *BatchConfig, dropped *atomic.Int64) *exportQueue {
+	size, batchSize := int64(defaultMaxQueueSize), int64(defaultMaxExportBatchSize)
+	if batch != nil && batch.MaxQueueSize > 0 {
+		size = int64(batch.MaxQueueSize)
+	}
+	if batch != nil && batch.MaxExportBatchSize > 0 {
+		batchSize = int64(batch.MaxExportBatchSize)
+	}
+	return &exportQueue{size: size, capacity: size + min(batchSize, size), dropped: dropped}
+}
+
+// enqueue counts a record handed to the batch processor, or a dropped one when the queue is full
+func (q *exportQueue) enqueue() {
+	if q == nil {
+		return
+	}
+	if q.pending.Add(1) > q.capacity {
+		q.pending.Add(-1)
+		q.dropped.Add(1)
+	}
+}
+
+// done counts n records that reached the exporter
+func (q *exportQueue) done(n int) {
+	if q == nil {
+		return
+	}
+	if q.pending.Add(-int64(n)) < 0 {
+		q.pending.Store(0)
+	}
+}
+
+// countingSpanExporter counts exported spans and the spans of failed exports
+type countingSpanExporter struct {
+	trace.SpanExporter
+	queue *exportQueue
+}
+
+func (e *countingSpanExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	e.queue.done(len(spans))
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	spanExports.Add(1)
+	if err != nil {
+		failedSpanExports.Add(1)
+		droppedSpans.Add(int64(len(spans)))
+	} else {
+		exportedSpans.Add(int64(len(spans)))
+	}
+	return err
+}
+
+// queueSpanProcessor counts the sampled spans handed to a batch processor
+type queueSpanProcessor struct {
+	trace.SpanProcessor
+	queue *exportQueue
+}
+
+func (p *queueSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.queue.enqueue()
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// countingLogExporter counts exported log records and the records of failed exports
+type countingLogExporter struct {
+	log.Exporter
+	queue *exportQueue
+}
+
+func (e *countingLogExporter) Export(ctx context.Context, records []log.Record) error {
+	e.queue.done(len(records))
+	err := e.Exporter.Export(ctx, records)
+	logExports.Add(1)
+	if err != nil {
+		failedLogExports.Add(1)
+		droppedLogs.Add(int64(len(records)))
+	} else {
+		exportedLogs.Add(int64(len(records)))
+	}
+	return err
+}
+
+// queueLogProcessor counts the log records handed to a batch processor
+type queueLogProcessor struct {
+	log.Processor
+	queue *exportQueue
+}
+
+func (p *queueLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	p.queue.enqueue()
+	return p.Processor.OnEmit(ctx, record)
+}
+
+// SignalKey tells the self-telemetry metrics of traces and logs apart
+const SignalKey = attribute.Key("signal")
+
+// CardinalityKindKey tells normalized span names and attribute keys apart
+const CardinalityKindKey = attribute.Key("kind")
+
+// registerSelfTelemetry reports Stats as the iudex.sdk.* metrics on meter
+func registerSelfTelemetry(meter metric.Meter) error {
+	exported, err := meter.Int64ObservableCounter("iudex.sdk.exported",
+		metric.WithDescription("Spans and log records accepted by exporters"),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		return err
+	}
+	dropped, err := meter.Int64ObservableCounter("iudex.sdk.dropped",
+		metric.WithDescription("Spans and log records lost to failed exports or full queues"),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		return err
+	}
+	exports, err := meter.Int64ObservableCounter("iudex.sdk.exports",
+		metric.WithDescription("Export calls"),
+		metric.WithUnit("{export}"),
+	)
+	if err != nil {
+		return err
+	}
+	failed, err := meter.Int64ObservableCounter("iudex.sdk.exports.failed",
+		metric.WithDescription("Failed export calls"),
+		metric.WithUnit("{export}"),
+	)
+	if err != nil {
+		return err
+	}
+	queued, err := meter.Int64ObservableGauge("iudex.sdk.queue.size",
+		metric.WithDescription("Spans and log records waiting to be exported"),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		return err
+	}
+	saturation, err := meter.Float64ObservableGauge("iudex.sdk.queue.saturation",
+		metric.WithDescription("Fill level of the fullest export queue, from 0 to 1"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return err
+	}
+	normalized, err := meter.Int64ObservableCounter("iudex.sdk.cardinality.normalized",
+		metric.WithDescription("Span names and attribute keys normalized by the cardinality guard"),
+		metric.WithUnit("{value}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	traces := metric.WithAttributes(SignalKey.String("traces"))
+	logs := metric.WithAttributes(SignalKey.String("logs"))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stats := Stats()
+		o.ObserveInt64(exported, stats.ExportedSpans, traces)
+		o.ObserveInt64(exported, stats.ExportedLogs, logs)
+		o.ObserveInt64(dropped, stats.DroppedSpans, traces)
+		o.ObserveInt64(dropped, stats.DroppedLogs, logs)
+		o.ObserveInt64(exports, stats.SpanExports, traces)
+		o.ObserveInt64(exports, stats.LogExports, logs)
+		o.ObserveInt64(failed, stats.FailedSpanExports, traces)
+		o.ObserveInt64(failed, stats.FailedLogExports, logs)
+		o.ObserveInt64(queued, stats.QueuedSpans, traces)
+		o.ObserveInt64(queued, stats.QueuedLogs, logs)
+		o.ObserveFloat64(saturation, stats.SpanQueueSaturation, traces)
+		o.ObserveFloat64(saturation, stats.LogQueueSaturation, logs)
+		o.ObserveInt64(normalized, stats.NormalizedSpanNames, metric.WithAttributes(CardinalityKindKey.String("span_name")))
+		o.ObserveInt64(normalized, stats.NormalizedAttributeKeys, metric.WithAttributes(CardinalityKindKey.String("attribute_key")))
+		return nil
+	}, exported, dropped, exports, failed, queued, saturation, normalized)
+	return err
+}
@@ -0,0 +1,79 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// NewStdLogger creates a standard library *log.Logger that emits each line as an OTel log record,
+// for legacy code and third-party libraries that only accept one, e.g. http.Server.ErrorLog.
+// Records have info severity unless set with WithStdLogSeverity. WithLogCaller records the caller
+// in code.filepath and code.lineno; WithSlogTee, the zap options, and WithLogCallerSkip do not apply.
+//
+//	server := &http.Server{
+//		ErrorLog: iudex.NewStdLogger("http", iudex.WithStdLogSeverity(otellog.SeverityError)),
+//	}
+func NewStdLogger(name string, opts ...LoggerOption) *log.Logger {
+	config := newLoggerConfig(opts)
+	flags := 0
+	if config.caller {
+		flags = log.Llongfile
+	}
+	writer := &stdLogWriter{
+		logger:   GetLoggerProvider().Logger(name),
+		severity: config.stdLevel,
+		caller:   config.caller,
+	}
+	return log.New(writer, "", flags)
+}
+
+// stdLogWriter parses the lines written by a *log.Logger into log records
+type stdLogWriter struct {
+	logger   otellog.Logger
+	severity otellog.Severity
+	caller   bool
+}
+
+// Write emits one log record, since a *log.Logger writes each entry in a single call
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	message := bytes.TrimSuffix(p, []byte("\n"))
+	record := otellog.Record{}
+	record.SetTimestamp(time.Now())
+	record.SetSeverity(w.severity)
+	record.SetSeverityText(w.severity.String())
+	if w.caller {
+		var attrs []otellog.KeyValue
+		message, attrs = stdLogCaller(message)
+		record.AddAttributes(attrs...)
+	}
+	record.SetBody(otellog.StringValue(string(message)))
+	w.logger.Emit(context.Background(), record)
+	return len(p), nil
+}
+
+// stdLogCaller splits the "/path/file.go:42: " prefix written with log.Llongfile off message
+func stdLogCaller(message []byte) ([]byte, []otellog.KeyValue) {
+	end := bytes.Index(message, []byte(": "))
+	if end < 0 {
+		return message, nil
+	}
+	location := message[:end]
+	colon := bytes.LastIndexByte(location, ':')
+	if colon < 0 {
+		return message, nil
+	}
+	line, err := strconv.Atoi(string(location[colon+1:]))
+	if err != nil {
+		return message, nil
+	}
+	return message[end+2:], []otellog.KeyValue{
+		otellog.String(string(semconv.CodeFilepathKey), string(location[:colon])),
+		otellog.Int(string(semconv.CodeLineNumberKey), line),
+	}
+}
@@ -0,0 +1,267 @@
+package iudex
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// TailSamplingConfig decides which traces to export once they are complete. Traces with an
+// error or a local root span slower than LatencyThreshold are always kept, and BaselineRatio
+// of the others. Zero values default to keeping no baseline, waiting 10s for a trace's root
+// span, and buffering at most 10000 traces.
+type TailSamplingConfig struct {
+	LatencyThreshold time.Duration
+	BaselineRatio    float64
+	// DecisionWait is how long spans are buffered when their local root span does not end,
+	// and how long the decision applies to spans ending late
+	DecisionWait time.Duration
+	MaxTraces    int
+}
+
+// pendingTrace holds the ended spans of a trace waiting for a decision
+type pendingTrace struct {
+	spans     []trace.ReadOnlySpan
+	firstSeen time.Time
+}
+
+type tailDecision struct {
+	keep      bool
+	decidedAt time.Time
+}
+
+// tailSamplingProcessor buffers spans per trace and hands the kept traces to the next processor
+type tailSamplingProcessor struct {
+	next   trace.SpanProcessor
+	config TailSamplingConfig
+
+	mu      sync.Mutex
+	pending map[oteltrace.TraceID]*pendingTrace
+	decided map[oteltrace.TraceID]tailDecision
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTailSamplingSpanProcessor wraps next so it only receives complete traces that are worth
+// keeping. Head sampling must keep every trace for tail sampling to see them.
+func NewTailSamplingSpanProcessor(next trace.SpanProcessor, config TailSamplingConfig) trace.SpanProcessor {
+	if config.DecisionWait <= 0 {
+		config.DecisionWait = 10 * time.Second
+	}
+	if config.MaxTraces <= 0 {
+		config.MaxTraces = 10000
+	}
+	p := &tailSamplingProcessor{
+		next:    next,
+		config:  config,
+		pending: map[oteltrace.TraceID]*pendingTrace{},
+		decided: map[oteltrace.TraceID]tailDecision{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.expireLoop()
+	return p
+}
+
+func (p *tailSamplingProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *tailSamplingProcessor) OnEnd(s trace.ReadOnlySpan) {
+	id := s.SpanContext().TraceID()
+
+	p.mu.Lock()
+	if d, ok := p.decided[id]; ok {
+		p.mu.Unlock()
+		if d.keep {
+			p.next.OnEnd(s)
+		}
+		return
+	}
+
+	var kept []trace.ReadOnlySpan
+	t, ok := p.pending[id]
+	if !ok {
+		if len(p.pending) >= p.config.MaxTraces {
+			kept = p.decideOldest()
+		}
+		t = &pendingTrace{firstSeen: time.Now()}
+		p.pending[id] = t
+	}
+	t.spans = append(t.spans, s)
+
+	// The trace is complete in this process once its local root ends
+	if parent := s.Parent(); !parent.IsValid() || parent.IsRemote() {
+		kept = append(kept, p.decide(id, s)...)
+	}
+	p.mu.Unlock()
+
+	for _, span := range kept {
+		p.next.OnEnd(span)
+	}
+}
+
+// decide makes the decision for a pending trace and returns its spans if it is kept. root is
+// the local root span, or nil when the trace is decided without it. Callers hold mu.
+func (p *tailSamplingProcessor) decide(id oteltrace.TraceID, root trace.ReadOnlySpan) []trace.ReadOnlySpan {
+	t := p.pending[id]
+	delete(p.pending, id)
+
+	keep := p.shouldKeep(id, t.spans, root)
+	p.decided[id] = tailDecision{keep: keep, decidedAt: time.Now()}
+	if !keep {
+		return nil
+	}
+	return t.spans
+}
+
+// decideOldest decides the trace buffered longest to make room for a new one. Callers hold mu.
+func (p *tailSamplingProcessor) decideOldest() []trace.ReadOnlySpan {
+	var oldestID oteltrace.TraceID
+	var oldest *pendingTrace
+	for id, t := range p.pending {
+		if oldest == nil || t.firstSeen.Before(oldest.firstSeen) {
+			oldestID, oldest = id, t
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	return p.decide(oldestID, nil)
+}
+
+func (p *tailSamplingProcessor) shouldKeep(id oteltrace.TraceID, spans []trace.ReadOnlySpan, root trace.ReadOnlySpan) bool {
+	for _, span := range spans {
+		if span.Status().Code == codes.Error {
+			return true
+		}
+	}
+
+	if p.config.LatencyThreshold > 0 {
+		// Without the root, the slowest span buffered so far stands in for it
+		var duration time.Duration
+		if root != nil {
+			duration = root.EndTime().Sub(root.StartTime())
+		} else {
+			for _, span := range spans {
+				duration = max(duration, span.EndTime().Sub(span.StartTime()))
+			}
+		}
+		if duration >= p.config.LatencyThreshold {
+			return true
+		}
+	}
+
+	// Same trace ID based decision as trace.TraceIDRatioBased, so services sampling at
+	// the same ratio keep the same traces
+	bound := uint64(p.config.BaselineRatio * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:16])>>1 < bound
+}
+
+// minTailSamplingTick bounds how often expireLoop runs for very short decision waits
+const minTailSamplingTick = time.Millisecond
+
+// expireLoop decides the traces whose root span did not end within DecisionWait and forgets old decisions
+func (p *tailSamplingProcessor) expireLoop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(max(p.config.DecisionWait/2, minTailSamplingTick))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		var kept []trace.ReadOnlySpan
+		deadline := time.Now().Add(-p.config.DecisionWait)
+		p.mu.Lock()
+		for id, t := range p.pending {
+			if t.firstSeen.Before(deadline) {
+				kept = append(kept, p.decide(id, nil)...)
+			}
+		}
+		for id, d := range p.decided {
+			if d.decidedAt.Before(deadline) {
+				delete(p.decided, id)
+			}
+		}
+		p.mu.Unlock()
+
+		for _, span := range kept {
+			p.next.OnEnd(span)
+		}
+	}
+}
+
+// decideAll decides every pending trace, e.g. before a flush
+func (p *tailSamplingProcessor) decideAll() {
+	var kept []trace.ReadOnlySpan
+	p.mu.Lock()
+	for id := range p.pending {
+		kept = append(kept, p.decide(id, nil)...)
+	}
+	p.mu.Unlock()
+
+	for _, span := range kept {
+		p.next.OnEnd(span)
+	}
+}
+
+func (p *tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+	p.decideAll()
+	return p.next.Shutdown(ctx)
+}
+
+func (p *tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	p.decideAll()
+	return p.next.ForceFlush(ctx)
+}
+
+// fanoutSpanProcessor hands every span to each of its processors
+type fanoutSpanProcessor struct {
+	processors []trace.SpanProcessor
+}
+
+func newFanoutSpanProcessor(processors ...trace.SpanProcessor) trace.SpanProcessor {
+	return &fanoutSpanProcessor{processors: processors}
+}
+
+func (p *fanoutSpanProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	for _, processor := range p.processors {
+		processor.OnStart(parent, s)
+	}
+}
+
+func (p *fanoutSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	for _, processor := range p.processors {
+		processor.OnEnd(s)
+	}
+}
+
+func (p *fanoutSpanProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.Shutdown(ctx))
+	}
+	return err
+}
+
+func (p *fanoutSpanProcessor) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.ForceFlush(ctx))
+	}
+	return err
+}
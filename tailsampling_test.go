@@ -0,0 +1,138 @@
+package iudex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// newTailSampledTracer returns a tracer whose spans go through tail sampling into the recorder
+func newTailSampledTracer(t *testing.T, config TailSamplingConfig) (oteltrace.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(NewTailSamplingSpanProcessor(recorder, config)))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return provider.Tracer("test"), recorder
+}
+
+func TestTailSamplingKeepsErrors(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+	if ended := recorder.Ended(); len(ended) != 0 {
+		t.Fatalf("got %d spans before the root ended", len(ended))
+	}
+	root.End()
+
+	if ended := recorder.Ended(); len(ended) != 2 {
+		t.Fatalf("got %d spans, want the whole trace", len(ended))
+	}
+}
+
+func TestTailSamplingDropsFastTraces(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{LatencyThreshold: time.Hour})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	if ended := recorder.Ended(); len(ended) != 0 {
+		t.Fatalf("got %d spans, want none", len(ended))
+	}
+}
+
+func TestTailSamplingKeepsSlowTraces(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{LatencyThreshold: time.Second})
+
+	start := time.Now()
+	_, root := tracer.Start(context.Background(), "root", oteltrace.WithTimestamp(start))
+	root.End(oteltrace.WithTimestamp(start.Add(2 * time.Second)))
+
+	if ended := recorder.Ended(); len(ended) != 1 {
+		t.Fatalf("got %d spans, want the slow root", len(ended))
+	}
+}
+
+func TestTailSamplingBaselineRatio(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{BaselineRatio: 1})
+
+	_, root := tracer.Start(context.Background(), "root")
+	root.End()
+
+	if ended := recorder.Ended(); len(ended) != 1 {
+		t.Fatalf("got %d spans, want the root", len(ended))
+	}
+}
+
+func TestTailSamplingLateSpansFollowDecision(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, late := tracer.Start(ctx, "late")
+	root.SetStatus(codes.Error, "failed")
+	root.End()
+	late.End()
+
+	if ended := recorder.Ended(); len(ended) != 2 {
+		t.Fatalf("got %d spans, want the late span too", len(ended))
+	}
+}
+
+func TestTailSamplingDecisionWait(t *testing.T) {
+	// A wait below the ticker resolution must not panic
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{DecisionWait: time.Nanosecond, BaselineRatio: 1})
+
+	ctx, _ := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+
+	deadline := time.Now().Add(time.Second)
+	for len(recorder.Ended()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("trace without an ended root was never decided")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTailSamplingMaxTraces(t *testing.T) {
+	tracer, recorder := newTailSampledTracer(t, TailSamplingConfig{MaxTraces: 1, BaselineRatio: 1})
+
+	for _, name := range []string{"first", "second"} {
+		ctx, _ := tracer.Start(context.Background(), "root")
+		_, child := tracer.Start(ctx, name)
+		child.End()
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Name() != "first" {
+		t.Fatalf("got %d spans, want the first trace decided to make room", len(ended))
+	}
+}
+
+func TestTailSamplingForceFlush(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	processor := NewTailSamplingSpanProcessor(recorder, TailSamplingConfig{BaselineRatio: 1})
+	provider := trace.NewTracerProvider(trace.WithSpanProcessor(processor))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	ctx, _ := provider.Tracer("test").Start(context.Background(), "root")
+	_, child := provider.Tracer("test").Start(ctx, "child")
+	child.End()
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ended := recorder.Ended(); len(ended) != 1 {
+		t.Fatalf("got %d spans, want the pending trace flushed", len(ended))
+	}
+}
@@ -0,0 +1,178 @@
+package iudex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// maxTenantLimiters bounds the number of tenants tracked by a limiter. Past it, all buckets start
+// over full, so an unbounded number of tenant IDs cannot exhaust memory.
+const maxTenantLimiters = 10000
+
+// TenantLimitConfig caps the telemetry of each tenant, so one customer's traffic cannot consume
+// the whole telemetry budget. Telemetry without a tenant is not limited.
+type TenantLimitConfig struct {
+	// SpansPerSecond caps the traces each tenant starts in this service per second. Zero is unlimited.
+	SpansPerSecond float64
+	// LogsPerSecond caps the log records of each tenant per second. Zero is unlimited.
+	LogsPerSecond float64
+}
+
+// activeTenantLimiter caps the traces of each tenant, nil without TenantLimitConfig.SpansPerSecond
+var activeTenantLimiter atomic.Pointer[tenantLimiter]
+
+// tenantMetrics adds tenant.id to the span and HTTP server metrics
+var tenantMetrics atomic.Bool
+
+// tenantDecisionKey holds whether the tenant set by WithTenant was within its trace limit
+type tenantDecisionKey struct{}
+
+// tenantCountedTraceState marks, in the trace state, traces already counted towards the trace
+// limit of their tenant, here or in an upstream service
+const tenantCountedTraceState = "iudex-tenant"
+
+// tenantCounted reports whether the trace of span was already counted towards its tenant's limit
+func tenantCounted(span oteltrace.SpanContext) bool {
+	return span.TraceState().Get(tenantCountedTraceState) != ""
+}
+
+// WithTenant attributes all telemetry created from the returned context to the tenant: spans
+// and log records carry tenant.id, as do, with WithTenantMetrics, the span metrics and the
+// metrics of the HTTP server request in ctx. The tenant travels as baggage, so downstream
+// services attribute their telemetry to it too.
+//
+// Each trace counts once towards TenantLimitConfig.SpansPerSecond: the first WithTenant call in
+// a trace that was not already counted where it entered the service, or upstream, takes the
+// decision. Over the limit, spans started from the returned context are dropped, while the span
+// already in ctx is kept.
+//
+//	ctx = iudex.WithTenant(ctx, org.ID)
+func WithTenant(ctx context.Context, id string) context.Context {
+	ctx = SetTenantID(ctx, id)
+	if labeler, ok := otelhttp.LabelerFromContext(ctx); ok && tenantMetrics.Load() {
+		labeler.Add(TenantIDKey.String(id))
+	}
+	limiter := activeTenantLimiter.Load()
+	if limiter == nil || tenantCounted(oteltrace.SpanContextFromContext(ctx)) {
+		return ctx
+	}
+	if _, decided := ctx.Value(tenantDecisionKey{}).(bool); decided {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantDecisionKey{}, limiter.allow(id))
+}
+
+// TenantFromContext returns the tenant set with WithTenant or SetTenantID, or an empty string
+func TenantFromContext(ctx context.Context) string {
+	return baggage.FromContext(ctx).Member(string(TenantIDKey)).Value()
+}
+
+// tenantLimiter keeps a token bucket for each tenant
+type tenantLimiter struct {
+	perSecond float64
+
+	mu      sync.Mutex
+	buckets map[string]*rateLimitedSampler
+}
+
+func newTenantLimiter(perSecond float64) *tenantLimiter {
+	return &tenantLimiter{perSecond: perSecond, buckets: map[string]*rateLimitedSampler{}}
+}
+
+// allow reports whether the tenant is within its limit, taking a token when it is
+func (l *tenantLimiter) allow(tenant string) bool {
+	if tenant == "" {
+		return true
+	}
+	l.mu.Lock()
+	bucket, ok := l.buckets[tenant]
+	if !ok {
+		if len(l.buckets) >= maxTenantLimiters {
+			l.buckets = map[string]*rateLimitedSampler{}
+		}
+		bucket = newRateLimitedSampler(l.perSecond)
+		l.buckets[tenant] = bucket
+	}
+	l.mu.Unlock()
+	return bucket.take()
+}
+
+// tenantLimitSampler drops spans of tenants over their limit. Spans below WithTenant follow the
+// decision it made. Other spans are limited where they enter the service, as roots or children of
+// remote spans, when the tenant arrived as baggage, so traces are not cut in the middle. Sampled
+// spans of a counted trace carry tenantCountedTraceState, so the trace counts only once, also in
+// downstream services.
+type tenantLimitSampler struct {
+	next    trace.Sampler
+	limiter *tenantLimiter
+}
+
+// newTenantLimitSampler wraps next so each tenant starts at most perSecond traces per second, and
+// installs its limiter for WithTenant
+func newTenantLimitSampler(next trace.Sampler, perSecond float64) trace.Sampler {
+	s := &tenantLimitSampler{next: next, limiter: newTenantLimiter(perSecond)}
+	activeTenantLimiter.Store(s.limiter)
+	return s
+}
+
+func (s *tenantLimitSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	result := s.next.ShouldSample(p)
+	if result.Decision != trace.RecordAndSample {
+		return result
+	}
+	parent := oteltrace.SpanContextFromContext(p.ParentContext)
+	if tenantCounted(parent) {
+		return result
+	}
+	allowed, decided := p.ParentContext.Value(tenantDecisionKey{}).(bool)
+	if !decided {
+		if parent.IsValid() && !parent.IsRemote() {
+			return result
+		}
+		tenant := TenantFromContext(p.ParentContext)
+		if tenant == "" {
+			return result
+		}
+		allowed = s.limiter.allow(tenant)
+	}
+	if !allowed {
+		result.Decision = trace.Drop
+		result.Attributes = nil
+		return result
+	}
+	if state, err := result.Tracestate.Insert(tenantCountedTraceState, "1"); err == nil {
+		result.Tracestate = state
+	}
+	return result
+}
+
+func (s *tenantLimitSampler) Description() string {
+	return fmt.Sprintf("TenantLimitSampler{%g,%s}", s.limiter.perSecond, s.next.Description())
+}
+
+// tenantLimitLogProcessor drops log records of tenants over their limit before they reach the
+// next processor
+type tenantLimitLogProcessor struct {
+	log.Processor
+	limiter *tenantLimiter
+}
+
+// newTenantLimitLogProcessor wraps next so it sees at most perSecond records of each tenant per second
+func newTenantLimitLogProcessor(next log.Processor, perSecond float64) log.Processor {
+	return &tenantLimitLogProcessor{Processor: next, limiter: newTenantLimiter(perSecond)}
+}
+
+func (p *tenantLimitLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if !keepsRecords(ctx) && !p.limiter.allow(TenantFromContext(ctx)) {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
@@ -0,0 +1,155 @@
+package iudex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// truncate shortens s to at most maxLength bytes, ending with a marker holding the original length
+// and a hash of the whole value, e.g. "SELECT … [truncated len=52311 sha256=9f86d081884c7d65]",
+// so truncated values can still be told apart and deduplicated. The marker is kept whole even
+// when maxLength is shorter than it.
+func truncate(maxLength int, s string) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	sum := sha256.Sum256([]byte(s))
+	marker := fmt.Sprintf("…[truncated len=%d sha256=%s]", len(s), hex.EncodeToString(sum[:8]))
+	keep := max(maxLength-len(marker), 0)
+	// Do not cut a multi-byte character in half
+	for keep > 0 && !utf8.RuneStart(s[keep]) {
+		keep--
+	}
+	return s[:keep] + marker
+}
+
+// truncateAttributes returns attrs with long string values truncated
+func truncateAttributes(maxLength int, attrs []attribute.KeyValue) []attribute.KeyValue {
+	truncated := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		switch attr.Value.Type() {
+		case attribute.STRING:
+			truncated[i] = attribute.String(string(attr.Key), truncate(maxLength, attr.Value.AsString()))
+		case attribute.STRINGSLICE:
+			values := attr.Value.AsStringSlice()
+			for j, v := range values {
+				values[j] = truncate(maxLength, v)
+			}
+			truncated[i] = attribute.StringSlice(string(attr.Key), values)
+		default:
+			truncated[i] = attr
+		}
+	}
+	return truncated
+}
+
+// truncateLogValue returns v with long string values truncated, recursing into slices and maps
+func truncateLogValue(maxLength int, v otellog.Value) otellog.Value {
+	switch v.Kind() {
+	case otellog.KindString:
+		return otellog.StringValue(truncate(maxLength, v.AsString()))
+	case otellog.KindSlice:
+		values := v.AsSlice()
+		truncated := make([]otellog.Value, len(values))
+		for i, item := range values {
+			truncated[i] = truncateLogValue(maxLength, item)
+		}
+		return otellog.SliceValue(truncated...)
+	case otellog.KindMap:
+		kvs := v.AsMap()
+		truncated := make([]otellog.KeyValue, len(kvs))
+		for i, kv := range kvs {
+			truncated[i] = otellog.KeyValue{Key: kv.Key, Value: truncateLogValue(maxLength, kv.Value)}
+		}
+		return otellog.MapValue(truncated...)
+	default:
+		return v
+	}
+}
+
+// truncatingSpanProcessor truncates long span attribute values before handing spans to the next processor
+type truncatingSpanProcessor struct {
+	trace.SpanProcessor
+	maxLength int
+}
+
+// NewTruncatingSpanProcessor wraps next so string attribute values of the spans, events, and links
+// it exports are at most maxLength bytes long. Truncated values end with a marker holding their
+// original length and a hash of the whole value.
+func NewTruncatingSpanProcessor(next trace.SpanProcessor, maxLength int) trace.SpanProcessor {
+	return &truncatingSpanProcessor{SpanProcessor: next, maxLength: maxLength}
+}
+
+func (p *truncatingSpanProcessor) OnEnd(s trace.ReadOnlySpan) {
+	p.SpanProcessor.OnEnd(&truncatedSpan{ReadOnlySpan: s, maxLength: p.maxLength})
+}
+
+// truncatedSpan overrides the attribute carrying parts of a ReadOnlySpan
+type truncatedSpan struct {
+	trace.ReadOnlySpan
+	maxLength int
+}
+
+func (s *truncatedSpan) Attributes() []attribute.KeyValue {
+	return truncateAttributes(s.maxLength, s.ReadOnlySpan.Attributes())
+}
+
+func (s *truncatedSpan) Events() []trace.Event {
+	events := s.ReadOnlySpan.Events()
+	truncated := make([]trace.Event, len(events))
+	for i, event := range events {
+		event.Attributes = truncateAttributes(s.maxLength, event.Attributes)
+		truncated[i] = event
+	}
+	return truncated
+}
+
+func (s *truncatedSpan) Links() []trace.Link {
+	links := s.ReadOnlySpan.Links()
+	truncated := make([]trace.Link, len(links))
+	for i, link := range links {
+		link.Attributes = truncateAttributes(s.maxLength, link.Attributes)
+		truncated[i] = link
+	}
+	return truncated
+}
+
+// truncatingLogProcessor truncates long log bodies and attribute values in place.
+// It must be registered before the exporting processor.
+type truncatingLogProcessor struct {
+	maxLength int
+}
+
+// NewTruncatingLogProcessor creates a log processor that truncates string bodies and attribute
+// values of records to maxLength bytes like NewTruncatingSpanProcessor
+func NewTruncatingLogProcessor(maxLength int) log.Processor {
+	return &truncatingLogProcessor{maxLength: maxLength}
+}
+
+func (p *truncatingLogProcessor) OnEmit(_ context.Context, record *log.Record) error {
+	record.SetBody(truncateLogValue(p.maxLength, record.Body()))
+
+	attrs := make([]otellog.KeyValue, 0, record.AttributesLen())
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs = append(attrs, otellog.KeyValue{Key: kv.Key, Value: truncateLogValue(p.maxLength, kv.Value)})
+		return true
+	})
+	record.SetAttributes(attrs...)
+	return nil
+}
+
+func (p *truncatingLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *truncatingLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package iudex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// User attributes set by SetUser and copied onto spans and log records by default
+const (
+	UserEmailKey = attribute.Key("user.email")
+	UserHashKey  = attribute.Key("user.hash")
+	UserRolesKey = attribute.Key("user.roles")
+)
+
+// sendUserEmail sends the emails given to SetUser as is instead of hashing them
+var sendUserEmail atomic.Bool
+
+// userHashSecret keys the HMAC of the emails given to SetUser, nil when none is configured
+var userHashSecret atomic.Pointer[[]byte]
+
+// setUserHashSecret sets the secret of user.hash, or removes it when secret is empty
+func setUserHashSecret(secret string) {
+	if secret == "" {
+		userHashSecret.Store(nil)
+		return
+	}
+	key := []byte(secret)
+	userHashSecret.Store(&key)
+}
+
+// userEmailKey holds the email given to SetUser with WithUserEmails, which is kept out of the
+// baggage so it is not sent to the services the request calls
+type userEmailKey struct{}
+
+// User identifies the user telemetry is attributed to. Empty fields are left out.
+type User struct {
+	ID    string
+	Email string
+	Role  string
+}
+
+// SetUser attributes all telemetry created from the returned context to the user, so errors show
+// who was affected. The ID is set as user.id and the role as user.roles, and like SetUserID they
+// travel as baggage to downstream services.
+//
+// The email is trimmed and lowercased and set as user.hash, an HMAC-SHA256 keyed with the secret
+// set by WithUserHashSecret, so users can be counted and matched without sending their address.
+// The hash is stable only as long as the secret is, and without a secret the email is left out.
+// WithUserEmails sends the email as user.email instead, on the spans and log records of this
+// service only, as it is not added to the baggage.
+func SetUser(ctx context.Context, user User) context.Context {
+	if user.ID != "" {
+		ctx = SetAttribute(ctx, string(UserIDKey), user.ID)
+	}
+	if email := strings.ToLower(strings.TrimSpace(user.Email)); email != "" {
+		if sendUserEmail.Load() {
+			oteltrace.SpanFromContext(ctx).SetAttributes(UserEmailKey.String(email))
+			ctx = context.WithValue(ctx, userEmailKey{}, email)
+		} else if secret := userHashSecret.Load(); secret != nil {
+			mac := hmac.New(sha256.New, *secret)
+			mac.Write([]byte(email))
+			ctx = SetAttribute(ctx, string(UserHashKey), hex.EncodeToString(mac.Sum(nil)))
+		}
+	}
+	if user.Role != "" {
+		ctx = SetAttribute(ctx, string(UserRolesKey), user.Role)
+	}
+	return ctx
+}
+
+// userEmailFromContext returns the email set by SetUser with WithUserEmails, or an empty string
+func userEmailFromContext(ctx context.Context) string {
+	email, _ := ctx.Value(userEmailKey{}).(string)
+	return email
+}
+
+// userEmailSpanProcessor sets the email given to SetUser on spans as they start
+type userEmailSpanProcessor struct{}
+
+func (userEmailSpanProcessor) OnStart(parent context.Context, s trace.ReadWriteSpan) {
+	if email := userEmailFromContext(parent); email != "" {
+		s.SetAttributes(UserEmailKey.String(email))
+	}
+}
+
+func (userEmailSpanProcessor) OnEnd(trace.ReadOnlySpan) {}
+
+func (userEmailSpanProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (userEmailSpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// userEmailLogProcessor adds the email given to SetUser to log records in place.
+// It must be registered before the exporting processor.
+type userEmailLogProcessor struct{}
+
+func (userEmailLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if email := userEmailFromContext(ctx); email != "" {
+		record.AddAttributes(otellog.String(string(UserEmailKey), email))
+	}
+	return nil
+}
+
+func (userEmailLogProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (userEmailLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
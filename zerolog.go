@@ -0,0 +1,207 @@
+package iudex
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog"
+	otellog "go.opentelemetry.io/otel/log"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// ZerologWriter is an io.Writer that forwards zerolog JSON events to the global LoggerProvider
+type ZerologWriter struct {
+	logger otellog.Logger
+}
+
+// NewZerologWriter creates a writer that parses zerolog JSON events into OTel log records.
+// Use it as a zerolog output with zerolog.New(iudex.NewZerologWriter("main")).
+// Events carrying trace_id and span_id fields are correlated with that span, sampled unless a
+// trace_flags field says otherwise.
+func NewZerologWriter(name string) *ZerologWriter {
+	return &ZerologWriter{
+		logger: GetLoggerProvider().Logger(name),
+	}
+}
+
+// Write parses one or more newline separated zerolog events and emits them as log records
+func (w *ZerologWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		w.emit(line)
+	}
+	return len(p), nil
+}
+
+func (w *ZerologWriter) emit(line []byte) {
+	record := otellog.Record{}
+	ctx := context.Background()
+
+	var fields map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		// Not a JSON event, forward the raw line
+		record.SetTimestamp(time.Now())
+		record.SetBody(otellog.StringValue(string(bytes.TrimSpace(line))))
+		w.logger.Emit(ctx, record)
+		return
+	}
+
+	record.SetTimestamp(zerologTime(fields["time"]))
+	delete(fields, "time")
+
+	if message, ok := fields["message"].(string); ok {
+		record.SetBody(otellog.StringValue(message))
+		delete(fields, "message")
+	}
+
+	if level, ok := fields["level"].(string); ok {
+		record.SetSeverity(zerologSeverity(level))
+		record.SetSeverityText(level)
+		delete(fields, "level")
+	}
+
+	traceID, _ := fields[TraceIDField].(string)
+	spanID, _ := fields[SpanIDField].(string)
+	traceFlags, _ := fields[TraceFlagsField].(string)
+	if spanContext, ok := zerologSpanContext(traceID, spanID, traceFlags); ok {
+		ctx = oteltrace.ContextWithSpanContext(ctx, spanContext)
+		delete(fields, TraceIDField)
+		delete(fields, SpanIDField)
+		delete(fields, TraceFlagsField)
+	}
+
+	attrs := make([]otellog.KeyValue, 0, len(fields))
+	for key, value := range fields {
+		attrs = append(attrs, otellog.KeyValue{Key: key, Value: jsonLogValue(value)})
+	}
+	record.AddAttributes(attrs...)
+
+	w.logger.Emit(ctx, record)
+}
+
+// ZerologTraceHook stamps trace_id, span_id, and trace_flags from the event context so ZerologWriter can correlate records.
+// Register it with logger.Hook(iudex.ZerologTraceHook{}) and pass the context with logger.Info().Ctx(ctx).
+type ZerologTraceHook struct{}
+
+// Run adds the span context of the event to its fields
+func (ZerologTraceHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	spanContext := oteltrace.SpanContextFromContext(e.GetCtx())
+	if !spanContext.IsValid() {
+		return
+	}
+	e.Str(TraceIDField, spanContext.TraceID().String())
+	e.Str(SpanIDField, spanContext.SpanID().String())
+	e.Str(TraceFlagsField, spanContext.TraceFlags().String())
+}
+
+// zerologTime parses the zerolog time field, which is formatted with the layout or as a unix
+// timestamp in the unit of zerolog.TimeFieldFormat
+func zerologTime(v any) time.Time {
+	switch v := v.(type) {
+	case string:
+		layout := zerolog.TimeFieldFormat
+		if layout == "" || layout == zerolog.TimeFormatUnixMs || layout == zerolog.TimeFormatUnixMicro || layout == zerolog.TimeFormatUnixNano {
+			layout = time.RFC3339Nano
+		}
+		if t, err := time.Parse(layout, v); err == nil {
+			return t
+		}
+	case json.Number:
+		unit := time.Second
+		switch zerolog.TimeFieldFormat {
+		case zerolog.TimeFormatUnixMs:
+			unit = time.Millisecond
+		case zerolog.TimeFormatUnixMicro:
+			unit = time.Microsecond
+		case zerolog.TimeFormatUnixNano:
+			unit = time.Nanosecond
+		}
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(0, 0).Add(time.Duration(n) * unit)
+		}
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(0, int64(f*float64(unit)))
+		}
+	}
+	return time.Now()
+}
+
+// zerologSeverity maps zerolog level names to OTel severities
+func zerologSeverity(level string) otellog.Severity {
+	switch level {
+	case "trace":
+		return otellog.SeverityTrace
+	case "debug":
+		return otellog.SeverityDebug
+	case "info":
+		return otellog.SeverityInfo
+	case "warn":
+		return otellog.SeverityWarn
+	case "error":
+		return otellog.SeverityError
+	case "fatal":
+		return otellog.SeverityFatal
+	case "panic":
+		return otellog.SeverityFatal4
+	default:
+		return otellog.SeverityUndefined
+	}
+}
+
+// zerologSpanContext rebuilds the span context from hex encoded trace and span IDs and trace flags.
+// Events written before trace flags were stamped are assumed to be sampled.
+func zerologSpanContext(traceID, spanID, traceFlags string) (oteltrace.SpanContext, bool) {
+	tid, err := oteltrace.TraceIDFromHex(traceID)
+	if err != nil {
+		return oteltrace.SpanContext{}, false
+	}
+	sid, err := oteltrace.SpanIDFromHex(spanID)
+	if err != nil {
+		return oteltrace.SpanContext{}, false
+	}
+	flags := oteltrace.FlagsSampled
+	if b, err := hex.DecodeString(traceFlags); err == nil && len(b) == 1 {
+		flags = oteltrace.TraceFlags(b[0])
+	}
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: flags,
+		Remote:     true,
+	}), true
+}
+
+// jsonLogValue converts a decoded JSON value into an OTel log value
+func jsonLogValue(v any) otellog.Value {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return otellog.Int64Value(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return otellog.Float64Value(f)
+		}
+		return otellog.StringValue(v.String())
+	case []any:
+		values := make([]otellog.Value, 0, len(v))
+		for _, item := range v {
+			values = append(values, jsonLogValue(item))
+		}
+		return otellog.SliceValue(values...)
+	case map[string]any:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for key, item := range v {
+			kvs = append(kvs, otellog.KeyValue{Key: key, Value: jsonLogValue(item)})
+		}
+		return otellog.MapValue(kvs...)
+	default:
+		return toLogValue(v)
+	}
+}
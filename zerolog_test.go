@@ -0,0 +1,78 @@
+package iudex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// setTimeFieldFormat changes zerolog.TimeFieldFormat until the test ends
+func setTimeFieldFormat(t *testing.T, format string) {
+	t.Helper()
+	prev := zerolog.TimeFieldFormat
+	zerolog.TimeFieldFormat = format
+	t.Cleanup(func() { zerolog.TimeFieldFormat = prev })
+}
+
+func TestZerologTime(t *testing.T) {
+	want := time.Date(2024, 5, 17, 10, 30, 15, 123456789, time.UTC)
+	tests := []struct {
+		name   string
+		format string
+		value  any
+		want   time.Time
+	}{
+		{"RFC3339", time.RFC3339, want.Format(time.RFC3339), want.Truncate(time.Second)},
+		{"RFC3339Nano", time.RFC3339Nano, want.Format(time.RFC3339Nano), want},
+		{"custom layout", "2006-01-02 15:04:05.000", want.Format("2006-01-02 15:04:05.000"), want.Truncate(time.Millisecond)},
+		{"unix seconds", zerolog.TimeFormatUnix, json.Number("1715941815"), want.Truncate(time.Second)},
+		{"unix milliseconds", zerolog.TimeFormatUnixMs, json.Number("1715941815123"), want.Truncate(time.Millisecond)},
+		{"unix microseconds", zerolog.TimeFormatUnixMicro, json.Number("1715941815123456"), want.Truncate(time.Microsecond)},
+		{"unix nanoseconds", zerolog.TimeFormatUnixNano, json.Number("1715941815123456789"), want},
+		{"fractional seconds", zerolog.TimeFormatUnix, json.Number("1715941815.5"), want.Truncate(time.Second).Add(500 * time.Millisecond)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTimeFieldFormat(t, tt.format)
+			if got := zerologTime(tt.value); !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZerologTimeInvalid(t *testing.T) {
+	setTimeFieldFormat(t, time.RFC3339)
+	before := time.Now()
+	for _, value := range []any{nil, "yesterday", json.Number("soon")} {
+		if got := zerologTime(value); got.Before(before) {
+			t.Errorf("zerologTime(%v) = %v, want the current time", value, got)
+		}
+	}
+}
+
+func TestZerologWriterTimestamp(t *testing.T) {
+	setTimeFieldFormat(t, zerolog.TimeFormatUnixMs)
+	collector := &collectingLogProcessor{}
+	provider := log.NewLoggerProvider(log.WithProcessor(collector))
+	w := &ZerologWriter{logger: provider.Logger("test")}
+
+	ts := time.Date(2024, 5, 17, 10, 30, 15, 123000000, time.UTC)
+	logger := zerolog.New(w)
+	logger.Info().Time("time", ts).Msg("hello")
+
+	if len(collector.records) != 1 {
+		t.Fatalf("got %d records", len(collector.records))
+	}
+	record := collector.records[0]
+	if !record.Timestamp().Equal(ts) {
+		t.Errorf("got timestamp %v, want %v", record.Timestamp(), ts)
+	}
+	if record.Severity() != otellog.SeverityInfo || record.Body().AsString() != "hello" {
+		t.Errorf("got %v %q", record.Severity(), record.Body().AsString())
+	}
+}